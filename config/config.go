@@ -16,9 +16,27 @@ type App struct {
 }
 
 type Handler struct {
+	// sets Secure attribute on the session cookie (disable for plain http only)
+	CookieSecure bool `env:"COOKIE_SECURE" env-default:"true"`
+	// max nesting level of fields in a graphql query (0 doesn't limit it)
+	MaxQueryDepth int `env:"MAX_QUERY_DEPTH" env-default:"10"`
+	// max estimated cost of a graphql query (0 doesn't limit it)
+	MaxQueryCost int `env:"MAX_QUERY_COST" env-default:"10000"`
+	// assumed number of items in a list, whose size isn't set by the query
+	QueryListSize int `env:"QUERY_LIST_SIZE" env-default:"20"`
+	// number of automatic persisted queries kept in memory (0 disables them)
+	PersistedQueryCacheSize int `env:"PERSISTED_QUERY_CACHE_SIZE" env-default:"1000"`
+	// json file with the only queries, which are allowed to be executed (sha256 hash -> query)
+	PersistedQueryAllowList string `env:"PERSISTED_QUERY_ALLOW_LIST" env-default:""`
 }
 
 type Service struct {
+	PageSize    int `env:"PAGE_SIZE" env-default:"20"`
+	MaxPageSize int `env:"MAX_PAGE_SIZE" env-default:"100"`
+	// administrator, who is registered on start unless there's one already (empty name skips it)
+	// roles aren't chosen on registration, so this is the only way to get the first one
+	AdminName     string `env:"ADMIN_NAME" env-default:""`
+	AdminPassword string `env:"ADMIN_PASSWORD" env-default:""`
 }
 
 type Storage struct {
@@ -32,11 +50,28 @@ type PostStorage struct {
 	DumpDestination string        `env:"DUMP_DESTINATION" env-default:"dump"`
 	DumpEnabled     bool          `env:"DUMP_ENABLED" env-default:"true"`
 	DumpInterval    time.Duration `env:"DUMP_INTERVAL" env-default:"5s"`
+	// delay before the first retry of a failed dump, doubled on every consecutive failure
+	DumpRetryDelay time.Duration `env:"DUMP_RETRY_DELAY" env-default:"1s"`
+	// consecutive dump failures, after which the server is shut down (0 never shuts it down)
+	DumpMaxFailures int `env:"DUMP_MAX_FAILURES" env-default:"5"`
+	// directory, which holds the write-ahead log of the in-memory storage
+	WALDestination string `env:"WAL_DESTINATION" env-default:"wal"`
+	// how often the wal is synced to disk (0 syncs it on every write)
+	WALSyncInterval time.Duration `env:"WAL_SYNC_INTERVAL" env-default:"0s"`
+	// how long deleted content is kept before being purged (0 keeps it forever)
+	Retention time.Duration `env:"RETENTION_PERIOD" env-default:"720h"`
+	// how often deleted content is purged
+	PurgeInterval time.Duration `env:"PURGE_INTERVAL" env-default:"1h"`
+	// max nesting level of comments, top-level ones included (0 doesn't limit it)
+	MaxCommentDepth int `env:"MAX_COMMENT_DEPTH" env-default:"10"`
+	Postgres
 }
 
 type UserStorage struct {
 	Type            string        `env:"USER_STORAGE_TYPE" env-default:"mock"`
 	SessionDuration time.Duration `env:"SESSION_DURATION" env-default:"24h"`
+	// how often expired sessions are deleted (0 disables the reaper)
+	ReapInterval time.Duration `env:"SESSION_REAP_INTERVAL" env-default:"10m"`
 	Postgres
 }
 
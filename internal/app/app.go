@@ -1,11 +1,12 @@
 package app
 
 import (
+	"context"
 	"fmt"
 	"log"
-	"os"
 
 	"github.com/cutlery47/posts/config"
+	"github.com/cutlery47/posts/internal/events"
 	v1 "github.com/cutlery47/posts/internal/handlers/http/v1"
 	"github.com/cutlery47/posts/internal/service"
 	post "github.com/cutlery47/posts/internal/storage/post-storage"
@@ -27,6 +28,15 @@ func Run(conf config.App) error {
 		return fmt.Errorf("[SETUP ERROR] error when setting up post storage: %v", err)
 	}
 
+	// state is flushed after the server has stopped accepting requests (or the setup has failed)
+	defer func() {
+		log.Println("[SHUTDOWN] closing post storage")
+
+		if cerr := ps.Close(); cerr != nil {
+			log.Println("[SHUTDOWN] error when closing post storage:", cerr)
+		}
+	}()
+
 	log.Println("[SETUP] setting up user storage...")
 
 	us, err := getUserStorage(conf.UserStorage)
@@ -34,13 +44,29 @@ func Run(conf config.App) error {
 		return fmt.Errorf("[SETUP ERROR] error when setting up user storage: %v", err)
 	}
 
+	defer func() {
+		log.Println("[SHUTDOWN] closing user storage")
+
+		if cerr := us.Close(); cerr != nil {
+			log.Println("[SHUTDOWN] error when closing user storage:", cerr)
+		}
+	}()
+
 	log.Println("[SETUP] setting up service...")
 
-	svc, err := service.New(conf.Service, ps, us)
+	svc, err := service.New(conf.Service, ps, us, events.NewBus())
 	if err != nil {
 		return fmt.Errorf("[SETUP ERROR] error when setting up service: %v", err)
 	}
 
+	if conf.Service.AdminName != "" {
+		log.Println("[SETUP] setting up administrator...")
+
+		if _, err := svc.EnsureAdmin(context.Background(), conf.Service.AdminName, conf.Service.AdminPassword); err != nil {
+			return fmt.Errorf("[SETUP ERROR] error when setting up administrator: %v", err)
+		}
+	}
+
 	log.Println("[SETUP] setting up graphql handler...")
 
 	h, err := v1.New(conf.Handler, svc)
@@ -58,22 +84,18 @@ func Run(conf config.App) error {
 func getPostStorage(conf config.PostStorage, errChan chan<- error) (post.Storage, error) {
 	switch conf.Type {
 	case "mem":
-		fd, err := os.OpenFile(conf.DumpDestination, os.O_CREATE|os.O_RDWR, 0777)
-		if err != nil {
-			return nil, err
-		}
-		return mem.NewStorage(conf, fd, fd, errChan)
+		return mem.NewStorage(conf, errChan)
 	case "pg":
-		return pgpost.NewStorage()
+		return pgpost.NewStorage(conf)
 	default:
-		return nil, fmt.Errorf("post storage type undefined. supported types: \"pg\" (not impl), \"mem\"")
+		return nil, fmt.Errorf("post storage type undefined. supported types: \"pg\", \"mem\"")
 	}
 }
 
 func getUserStorage(conf config.UserStorage) (user.Storage, error) {
 	switch conf.Type {
 	case "mock":
-		return mock.NewStorage(), nil
+		return mock.NewStorage(conf), nil
 	case "pg":
 		pg, err := pg.NewStorage(conf)
 		if err != nil {
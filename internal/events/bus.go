@@ -0,0 +1,143 @@
+package events
+
+import (
+	"sync"
+
+	storage "github.com/cutlery47/posts/internal/storage/post-storage"
+	"github.com/google/uuid"
+)
+
+const (
+	// number of events, which a subscriber may fall behind by before getting dropped
+	subscriptionBuffer = 64
+	// number of locks, which sequence events of different posts
+	stripes = 64
+)
+
+type Kind string
+
+const (
+	PostCreated    Kind = "postCreated"
+	PostUpdated    Kind = "postUpdated"
+	CommentAdded   Kind = "commentAdded"
+	CommentUpdated Kind = "commentUpdated"
+)
+
+type Event struct {
+	Kind Kind
+	// post, which the event concerns
+	PostId uuid.UUID
+
+	// set for post events
+	Post *storage.Post
+	// set for comment events
+	Comment *storage.Comment
+}
+
+// in-process publish / subscribe bus
+// events are delivered to every subscriber in the order they were published
+type Bus struct {
+	mu   *sync.Mutex
+	subs map[*Subscription]struct{}
+
+	// per-post locks, which keep writes and their events in the same order
+	seq [stripes]sync.Mutex
+}
+
+func NewBus() *Bus {
+	return &Bus{
+		mu:   &sync.Mutex{},
+		subs: make(map[*Subscription]struct{}),
+	}
+}
+
+type Subscription struct {
+	// delivers matching events, closed once the subscription is over
+	C <-chan Event
+
+	c      chan Event
+	filter func(e Event) bool
+	bus    *Bus
+}
+
+// subscribes to events, which satisfy the filter
+func (b *Bus) Subscribe(filter func(e Event) bool) *Subscription {
+	c := make(chan Event, subscriptionBuffer)
+
+	sub := &Subscription{
+		C:      c,
+		c:      c,
+		filter: filter,
+		bus:    b,
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.subs[sub] = struct{}{}
+
+	return sub
+}
+
+// unsubscribes and closes the channel (safe to call multiple times)
+func (s *Subscription) Close() {
+	s.bus.mu.Lock()
+	defer s.bus.mu.Unlock()
+
+	s.bus.drop(s)
+}
+
+// delivers event to all of the matching subscribers without blocking
+// subscribers, which can't keep up, are dropped instead of silently losing events
+func (b *Bus) Publish(e Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for sub := range b.subs {
+		if sub.filter != nil && !sub.filter(e) {
+			continue
+		}
+
+		select {
+		case sub.c <- e:
+		default:
+			b.drop(sub)
+		}
+	}
+}
+
+// runs write and publishes its event, while holding the lock of given post
+// so that events of a single post are published in the same order as their writes
+func (b *Bus) Sequence(postId uuid.UUID, write func() (*Event, error)) error {
+	mu := &b.seq[stripe(postId)]
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	e, err := write()
+	if err != nil {
+		return err
+	}
+
+	b.Publish(*e)
+
+	return nil
+}
+
+// not concurrent-safe by itself!
+func (b *Bus) drop(sub *Subscription) {
+	if _, ok := b.subs[sub]; !ok {
+		return
+	}
+
+	delete(b.subs, sub)
+	close(sub.c)
+}
+
+func stripe(id uuid.UUID) int {
+	var h uint32
+	for _, b := range id {
+		h = h*31 + uint32(b)
+	}
+	return int(h % stripes)
+}
@@ -9,6 +9,7 @@ import (
 	"github.com/cutlery47/posts/config"
 	"github.com/cutlery47/posts/internal/service"
 	storage "github.com/cutlery47/posts/internal/storage/user-storage"
+	"github.com/google/uuid"
 )
 
 type authRoutes struct {
@@ -65,6 +66,8 @@ func (ar *authRoutes) handleLogin(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	ar.setCookie(w, sesh)
+
 	err = json.NewEncoder(w).Encode(sesh)
 	if err != nil {
 		log.Println("[REQUEST] internal server error: ", err)
@@ -75,31 +78,125 @@ func (ar *authRoutes) handleLogin(w http.ResponseWriter, r *http.Request) {
 }
 
 func (ar *authRoutes) handleLogout(w http.ResponseWriter, r *http.Request) {
-	var (
-		sesh storage.Session
-	)
-
-	err := json.NewDecoder(r.Body).Decode(&sesh)
+	seshId, err := ar.requestSession(r)
 	if err != nil {
 		log.Println(fmt.Sprintf("[REQUEST] bad session data: %v", err))
-		w.Write([]byte("bad request"))
 		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte("bad request"))
 		return
 	}
 
+	sesh := storage.Session{Id: seshId}
+
 	err = ar.svc.Logout(r.Context(), sesh)
 	if err != nil {
-		log.Println("[REQUEST] error when loging user in: ", err)
-		w.Write([]byte("couldn't log you in"))
+		log.Println("[REQUEST] error when logging user out: ", err)
 		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte("couldn't log you out"))
 		return
 	}
 
+	ar.clearCookie(w)
+
 	err = json.NewEncoder(w).Encode(sesh)
 	if err != nil {
 		log.Println("[REQUEST] internal server error: ", err)
+		w.WriteHeader(http.StatusInternalServerError)
 		w.Write([]byte("internal server error"))
+		return
+	}
+}
+
+func (ar *authRoutes) handleRefresh(w http.ResponseWriter, r *http.Request) {
+	seshId, err := ar.requestSession(r)
+	if err != nil {
+		log.Println(fmt.Sprintf("[REQUEST] bad session data: %v", err))
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte("bad request"))
+		return
+	}
+
+	refreshed, err := ar.svc.RefreshSession(r.Context(), seshId)
+	if err != nil {
+		log.Println("[REQUEST] error when refreshing session: ", err)
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte("couldn't refresh your session"))
+		return
+	}
+
+	ar.setCookie(w, refreshed)
+
+	err = json.NewEncoder(w).Encode(refreshed)
+	if err != nil {
+		log.Println("[REQUEST] internal server error: ", err)
 		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte("internal server error"))
 		return
 	}
 }
+
+func (ar *authRoutes) handleLogoutEverywhere(w http.ResponseWriter, r *http.Request) {
+	seshId, err := ar.requestSession(r)
+	if err != nil {
+		log.Println(fmt.Sprintf("[REQUEST] bad session data: %v", err))
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte("bad request"))
+		return
+	}
+
+	err = ar.svc.LogoutEverywhere(r.Context(), seshId)
+	if err != nil {
+		log.Println("[REQUEST] error when logging user out everywhere: ", err)
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte("couldn't log you out"))
+		return
+	}
+
+	ar.clearCookie(w)
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// returns id of the session, which the request is made with
+// sessions, passed in the request body, are deprecated in favor of the header / cookie
+func (ar *authRoutes) requestSession(r *http.Request) (uuid.UUID, error) {
+	if id, ok := FromContext(r.Context()); ok {
+		return id.SessionId, nil
+	}
+
+	var (
+		sesh storage.Session
+	)
+
+	if err := json.NewDecoder(r.Body).Decode(&sesh); err != nil {
+		return uuid.UUID{}, ErrNoSession
+	}
+
+	log.Println("[DEPRECATED] session was passed in request body")
+
+	return sesh.Id, nil
+}
+
+func (ar *authRoutes) setCookie(w http.ResponseWriter, sesh *storage.Session) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     CookieName,
+		Value:    sesh.Id.String(),
+		Path:     "/",
+		Expires:  sesh.ExpiresAt,
+		Secure:   ar.conf.CookieSecure,
+		HttpOnly: true,
+		SameSite: http.SameSiteStrictMode,
+	})
+}
+
+func (ar *authRoutes) clearCookie(w http.ResponseWriter) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     CookieName,
+		Value:    "",
+		Path:     "/",
+		MaxAge:   -1,
+		Secure:   ar.conf.CookieSecure,
+		HttpOnly: true,
+		SameSite: http.SameSiteStrictMode,
+	})
+}
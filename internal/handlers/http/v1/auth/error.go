@@ -0,0 +1,8 @@
+package auth
+
+import "errors"
+
+var (
+	ErrBadAuthHeader = errors.New("authorization header should look like \"Bearer <session>\"")
+	ErrNoSession     = errors.New("valid session wasn't provided")
+)
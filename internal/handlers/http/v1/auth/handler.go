@@ -20,6 +20,8 @@ func New(conf config.Handler, svc *service.Service) *chi.Mux {
 		r.Get("/register", auth.handleRegister)
 		r.Get("/login", auth.handleLogin)
 		r.Get("/logout", auth.handleLogout)
+		r.Get("/logout/all", auth.handleLogoutEverywhere)
+		r.Get("/refresh", auth.handleRefresh)
 	})
 
 	return mux
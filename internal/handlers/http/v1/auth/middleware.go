@@ -0,0 +1,131 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/cutlery47/posts/internal/service"
+	storage "github.com/cutlery47/posts/internal/storage/user-storage"
+	"github.com/google/uuid"
+)
+
+const (
+	// name of the cookie, which carries session id
+	CookieName = "sesh_id"
+
+	bearerPrefix = "Bearer "
+)
+
+// identity of the user, who made the request
+type Identity struct {
+	SessionId uuid.UUID
+	UserId    uuid.UUID
+}
+
+type identityKey struct{}
+
+// returns identity, resolved by the middleware
+func FromContext(ctx context.Context) (Identity, bool) {
+	id, ok := ctx.Value(identityKey{}).(Identity)
+	return id, ok
+}
+
+// resolves session from "Authorization: Bearer <session>" header or session cookie
+// and puts the user's identity on the request context
+// requests without a valid session pass through anonymously, requests with a malformed one are rejected
+func Middleware(svc *service.Service) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			seshId, ok, err := sessionFromRequest(r)
+			if err != nil {
+				w.WriteHeader(http.StatusUnauthorized)
+				w.Write([]byte("malformed session"))
+				return
+			}
+
+			if !ok {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			userId, err := svc.GetSessionUser(r.Context(), seshId)
+			if err != nil {
+				// stale sessions are treated as no session, so that the user is still able to log in
+				if errors.Is(err, storage.ErrSessionNotFound) || errors.Is(err, storage.ErrSessionExpired) {
+					next.ServeHTTP(w, r)
+					return
+				}
+
+				log.Println("[REQUEST] error when resolving session: ", err)
+				w.WriteHeader(http.StatusInternalServerError)
+				w.Write([]byte("internal server error"))
+				return
+			}
+
+			ctx := context.WithValue(r.Context(), identityKey{}, Identity{
+				SessionId: seshId,
+				UserId:    userId,
+			})
+
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// lets through only moderators and administrators
+// should be preceded by Middleware, which resolves the identity
+func StaffOnly(svc *service.Service) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			id, ok := FromContext(r.Context())
+			if !ok {
+				w.WriteHeader(http.StatusUnauthorized)
+				w.Write([]byte(ErrNoSession.Error()))
+				return
+			}
+
+			if err := svc.RequireStaff(r.Context(), id.UserId); err != nil {
+				if errors.Is(err, service.ErrAccessDenied) {
+					w.WriteHeader(http.StatusForbidden)
+					w.Write([]byte(err.Error()))
+					return
+				}
+
+				log.Println("[REQUEST] error when checking user's role: ", err)
+				w.WriteHeader(http.StatusInternalServerError)
+				w.Write([]byte("internal server error"))
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// header takes precedence over the cookie
+func sessionFromRequest(r *http.Request) (uuid.UUID, bool, error) {
+	var (
+		token string
+	)
+
+	if header := r.Header.Get("Authorization"); header != "" {
+		if !strings.HasPrefix(header, bearerPrefix) {
+			return uuid.UUID{}, false, ErrBadAuthHeader
+		}
+		token = strings.TrimPrefix(header, bearerPrefix)
+	} else if cookie, err := r.Cookie(CookieName); err == nil {
+		token = cookie.Value
+	} else {
+		return uuid.UUID{}, false, nil
+	}
+
+	id, err := uuid.Parse(strings.TrimSpace(token))
+	if err != nil {
+		return uuid.UUID{}, false, err
+	}
+
+	return id, true, nil
+}
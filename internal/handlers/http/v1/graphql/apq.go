@@ -0,0 +1,227 @@
+package gql
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/cutlery47/posts/internal/metrics"
+	"github.com/graphql-go/graphql/gqlerrors"
+	"github.com/graphql-go/graphql/language/ast"
+)
+
+// automatic persisted queries: https://github.com/apollographql/apollo-link-persisted-queries#protocol
+const persistedQueryVersion = 1
+
+var (
+	ErrPersistedQueryNotFound     = errors.New("PersistedQueryNotFound")
+	ErrPersistedQueryNotSupported = errors.New("PersistedQueryNotSupported")
+	ErrPersistedQueryBadVersion   = errors.New("unsupported persisted query version")
+	ErrPersistedQueryBadHash      = errors.New("provided sha does not match query")
+	ErrQueryNotAllowed            = errors.New("query is not in the allow-list")
+)
+
+// codes of the errors, which are reported in extensions
+var persistedCodes = map[error]string{
+	ErrPersistedQueryNotFound:     "PERSISTED_QUERY_NOT_FOUND",
+	ErrPersistedQueryNotSupported: "PERSISTED_QUERY_NOT_SUPPORTED",
+	ErrPersistedQueryBadVersion:   "BAD_REQUEST",
+	ErrPersistedQueryBadHash:      "BAD_REQUEST",
+	ErrQueryNotAllowed:            "QUERY_NOT_ALLOWED",
+}
+
+// validated documents of persisted queries, keyed by sha256 of their text
+type persisted struct {
+	// documents, registered by clients (nil if apq is disabled)
+	cache *queryCache
+	// documents of the allow-list (nil unless only the listed queries are allowed)
+	allowed map[string]*ast.Document
+}
+
+// returns document of the request, taking it from persisted queries when possible
+func (gh *gqlHandler) document(req *request) (*ast.Document, []gqlerrors.FormattedError) {
+	hash, ok, err := persistedHash(req.Extensions)
+	if err != nil {
+		return nil, persistedError(err)
+	}
+
+	// full queries are accepted in allow-list mode too, as long as they're listed
+	if gh.queries.allowed != nil {
+		if !ok {
+			hash = queryHash(req.Query)
+		}
+
+		doc, ok := gh.queries.allowed[hash]
+		if !ok {
+			return nil, persistedError(ErrQueryNotAllowed)
+		}
+
+		return doc, nil
+	}
+
+	if !ok {
+		return gh.parse(req.Query)
+	}
+
+	if gh.queries.cache == nil {
+		return nil, persistedError(ErrPersistedQueryNotSupported)
+	}
+
+	// the query, sent along with the hash, should match it even if the hash is already known
+	if req.Query != "" && queryHash(req.Query) != hash {
+		return nil, persistedError(ErrPersistedQueryBadHash)
+	}
+
+	if doc, ok := gh.queries.cache.get(hash); ok {
+		metrics.PersistedQueryHits.Add(1)
+		return doc, nil
+	}
+
+	metrics.PersistedQueryMisses.Add(1)
+
+	// client is expected to retry with the full query
+	if req.Query == "" {
+		return nil, persistedError(ErrPersistedQueryNotFound)
+	}
+
+	doc, errs := gh.parse(req.Query)
+	if errs != nil {
+		return nil, errs
+	}
+
+	gh.queries.cache.add(hash, doc)
+
+	return doc, nil
+}
+
+// reads allow-list of queries from json file (sha256 hash -> query)
+func (gh *gqlHandler) loadAllowList(path string) error {
+	var (
+		queries map[string]string
+	)
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	if err := json.Unmarshal(data, &queries); err != nil {
+		return err
+	}
+
+	gh.queries.allowed = make(map[string]*ast.Document, len(queries))
+
+	for hash, query := range queries {
+		if queryHash(query) != hash {
+			return fmt.Errorf("%v: %v", ErrPersistedQueryBadHash, hash)
+		}
+
+		doc, errs := gh.parse(query)
+		if errs != nil {
+			return fmt.Errorf("query %v is invalid: %v", hash, errs[0])
+		}
+
+		gh.queries.allowed[hash] = doc
+	}
+
+	return nil
+}
+
+// returns hash of the persisted query, if the request refers to one
+func persistedHash(ext map[string]interface{}) (string, bool, error) {
+	raw, ok := ext["persistedQuery"]
+	if !ok {
+		return "", false, nil
+	}
+
+	pq, ok := raw.(map[string]interface{})
+	if !ok {
+		return "", false, ErrBadParams
+	}
+
+	if version, _ := pq["version"].(float64); version != persistedQueryVersion {
+		return "", false, ErrPersistedQueryBadVersion
+	}
+
+	hash, _ := pq["sha256Hash"].(string)
+	if hash == "" {
+		return "", false, ErrPersistedQueryBadHash
+	}
+
+	return hash, true, nil
+}
+
+func queryHash(query string) string {
+	sum := sha256.Sum256([]byte(query))
+	return hex.EncodeToString(sum[:])
+}
+
+func persistedError(err error) []gqlerrors.FormattedError {
+	code, ok := persistedCodes[err]
+	if !ok {
+		code = "BAD_REQUEST"
+	}
+
+	return []gqlerrors.FormattedError{queryError(code, err.Error(), map[string]any{})}
+}
+
+// bounded cache, which evicts least recently used documents
+type queryCache struct {
+	mu sync.Mutex
+
+	size  int
+	order *list.List
+	// hash -> element of order
+	items map[string]*list.Element
+}
+
+type cacheEntry struct {
+	hash string
+	doc  *ast.Document
+}
+
+func newQueryCache(size int) *queryCache {
+	return &queryCache{
+		size:  size,
+		order: list.New(),
+		items: make(map[string]*list.Element, size),
+	}
+}
+
+func (c *queryCache) get(hash string) (*ast.Document, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.items[hash]
+	if !ok {
+		return nil, false
+	}
+
+	c.order.MoveToFront(el)
+
+	return el.Value.(*cacheEntry).doc, true
+}
+
+func (c *queryCache) add(hash string, doc *ast.Document) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.items[hash]; ok {
+		c.order.MoveToFront(el)
+		return
+	}
+
+	c.items[hash] = c.order.PushFront(&cacheEntry{hash: hash, doc: doc})
+
+	for c.order.Len() > c.size {
+		el := c.order.Back()
+
+		c.order.Remove(el)
+		delete(c.items, el.Value.(*cacheEntry).hash)
+	}
+}
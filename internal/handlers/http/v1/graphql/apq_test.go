@@ -0,0 +1,160 @@
+package gql
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cutlery47/posts/config"
+	"github.com/graphql-go/graphql/gqlerrors"
+	"github.com/graphql-go/graphql/language/ast"
+)
+
+func persistedRequest(query, hash string) *request {
+	return &request{
+		Query: query,
+		Extensions: map[string]interface{}{
+			"persistedQuery": map[string]interface{}{
+				"version":    float64(persistedQueryVersion),
+				"sha256Hash": hash,
+			},
+		},
+	}
+}
+
+func errorCode(errs []gqlerrors.FormattedError) any {
+	if len(errs) == 0 {
+		return nil
+	}
+
+	return errs[0].Extensions["code"]
+}
+
+func TestPersistedQuery(t *testing.T) {
+	var (
+		gh    = newHandler(t, config.Handler{PersistedQueryCacheSize: 10}, nil)
+		query = `{ posts(sort_by: NEWEST) { id } }`
+		hash  = queryHash(query)
+	)
+
+	// client sends the hash alone first
+	if _, errs := gh.document(persistedRequest("", hash)); errorCode(errs) != "PERSISTED_QUERY_NOT_FOUND" {
+		t.Fatalf("unknown query was found: %v", errs)
+	}
+
+	// and retries with the full query
+	doc, errs := gh.document(persistedRequest(query, hash))
+	if errs != nil {
+		t.Fatalf("error: %v", errs[0])
+	}
+
+	cached, errs := gh.document(persistedRequest("", hash))
+	if errs != nil {
+		t.Fatalf("error: %v", errs[0])
+	}
+
+	if cached != doc {
+		t.Fatalf("query wasn't taken from the cache")
+	}
+
+	if _, errs := gh.document(persistedRequest(`{ posts(sort_by: TOP) { id } }`, hash)); errorCode(errs) != "BAD_REQUEST" {
+		t.Fatalf("query, which doesn't match the hash, was accepted")
+	}
+
+	// invalid queries aren't cached
+	invalid := `{ nothing }`
+	if _, errs := gh.document(persistedRequest(invalid, queryHash(invalid))); errs == nil {
+		t.Fatalf("invalid query was accepted")
+	}
+
+	if _, ok := gh.queries.cache.get(queryHash(invalid)); ok {
+		t.Fatalf("invalid query was cached")
+	}
+}
+
+func TestPersistedQueryBadExtension(t *testing.T) {
+	gh := newHandler(t, config.Handler{PersistedQueryCacheSize: 10}, nil)
+
+	req := persistedRequest("", queryHash("{ __typename }"))
+	req.Extensions["persistedQuery"].(map[string]interface{})["version"] = float64(2)
+
+	if _, errs := gh.document(req); errorCode(errs) != "BAD_REQUEST" {
+		t.Fatalf("unsupported version was accepted")
+	}
+
+	if _, errs := gh.document(persistedRequest("", "")); errorCode(errs) != "BAD_REQUEST" {
+		t.Fatalf("empty hash was accepted")
+	}
+}
+
+func TestPersistedQueryDisabled(t *testing.T) {
+	gh := newHandler(t, config.Handler{}, nil)
+
+	query := `{ __typename }`
+	if _, errs := gh.document(persistedRequest(query, queryHash(query))); errorCode(errs) != "PERSISTED_QUERY_NOT_SUPPORTED" {
+		t.Fatalf("persisted query was accepted: %v", errs)
+	}
+
+	// plain queries are still accepted
+	if _, errs := gh.document(&request{Query: query}); errs != nil {
+		t.Fatalf("error: %v", errs[0])
+	}
+}
+
+func TestPersistedQueryAllowList(t *testing.T) {
+	var (
+		allowed = `{ posts(sort_by: NEWEST) { id } }`
+		path    = filepath.Join(t.TempDir(), "queries.json")
+	)
+
+	data, err := json.Marshal(map[string]string{queryHash(allowed): allowed})
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	gh := newHandler(t, config.Handler{PersistedQueryAllowList: path}, nil)
+
+	if _, errs := gh.document(persistedRequest("", queryHash(allowed))); errs != nil {
+		t.Fatalf("error: %v", errs[0])
+	}
+
+	if _, errs := gh.document(&request{Query: allowed}); errs != nil {
+		t.Fatalf("error: %v", errs[0])
+	}
+
+	if _, errs := gh.document(&request{Query: `{ __typename }`}); errorCode(errs) != "QUERY_NOT_ALLOWED" {
+		t.Fatalf("query out of the allow-list was accepted")
+	}
+}
+
+func TestQueryCacheEviction(t *testing.T) {
+	var (
+		c    = newQueryCache(2)
+		docs = []*ast.Document{{}, {}, {}}
+	)
+
+	c.add("a", docs[0])
+	c.add("b", docs[1])
+
+	// a becomes the most recently used one
+	if doc, ok := c.get("a"); !ok || doc != docs[0] {
+		t.Fatalf("a wasn't cached")
+	}
+
+	c.add("c", docs[2])
+
+	if _, ok := c.get("b"); ok {
+		t.Fatalf("least recently used document wasn't evicted")
+	}
+
+	for _, hash := range []string{"a", "c"} {
+		if _, ok := c.get(hash); !ok {
+			t.Fatalf("%v was evicted", hash)
+		}
+	}
+}
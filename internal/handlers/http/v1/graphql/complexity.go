@@ -0,0 +1,361 @@
+package gql
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/cutlery47/posts/config"
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/gqlerrors"
+	"github.com/graphql-go/graphql/language/ast"
+)
+
+// cost of resolving a single object field, unless it's overridden in fieldCosts
+// scalar fields are free, since they're read off an already resolved object
+const defaultFieldCost = 1
+
+// fields, which are heavier to resolve than the rest
+// "Type.field" -> cost
+var fieldCosts = map[string]int{
+	"Query.search":        10,
+	"Query.trash":         5,
+	"Query.moderationLog": 5,
+	"User.karma":          5,
+}
+
+// arguments, which bound the number of items in a list
+var sizeArgs = []string{"first", "limit"}
+
+// limits, which are checked before a query is executed
+type limits struct {
+	// max nesting level of fields (0 doesn't limit it)
+	maxDepth int
+	// max estimated cost (0 doesn't limit it)
+	maxCost int
+	// assumed number of items in a list, which isn't bounded by the query
+	listSize int
+}
+
+func newLimits(conf config.Handler) limits {
+	return limits{
+		maxDepth: conf.MaxQueryDepth,
+		maxCost:  conf.MaxQueryCost,
+		listSize: max(conf.QueryListSize, 1),
+	}
+}
+
+// estimates depth and cost of the requested operation and reports the exceeded limits
+// document should be already validated, unknown operations aren't reported
+func (l limits) check(schema graphql.Schema, doc *ast.Document, operationName string, vars map[string]any) []gqlerrors.FormattedError {
+	var (
+		op = operation(doc, operationName)
+		e  = estimator{
+			limits:    l,
+			schema:    schema,
+			vars:      vars,
+			fragments: fragments(doc),
+		}
+	)
+
+	if op == nil || (l.maxDepth <= 0 && l.maxCost <= 0) {
+		return nil
+	}
+
+	var root *graphql.Object
+
+	switch op.Operation {
+	case ast.OperationTypeQuery:
+		root = schema.QueryType()
+	case ast.OperationTypeMutation:
+		root = schema.MutationType()
+	case ast.OperationTypeSubscription:
+		root = schema.SubscriptionType()
+	}
+
+	if root == nil {
+		return nil
+	}
+
+	depth, cost := e.selections(op.SelectionSet, root, false)
+
+	var (
+		errs []gqlerrors.FormattedError
+	)
+
+	if l.maxDepth > 0 && depth > l.maxDepth {
+		errs = append(errs, queryError("QUERY_TOO_DEEP", fmt.Sprintf("query depth %v exceeds the limit of %v", depth, l.maxDepth), map[string]any{
+			"depth":    depth,
+			"maxDepth": l.maxDepth,
+		}))
+	}
+
+	if l.maxCost > 0 && cost > l.maxCost {
+		errs = append(errs, queryError("QUERY_TOO_COMPLEX", fmt.Sprintf("query cost %v exceeds the limit of %v", cost, l.maxCost), map[string]any{
+			"cost":    cost,
+			"maxCost": l.maxCost,
+		}))
+	}
+
+	return errs
+}
+
+// builds a graphql error, which carries its code in extensions
+func queryError(code, msg string, ext map[string]any) gqlerrors.FormattedError {
+	err := gqlerrors.NewFormattedError(msg)
+
+	err.Extensions = ext
+	err.Extensions["code"] = code
+
+	return err
+}
+
+// walks selections of a single operation
+type estimator struct {
+	limits
+
+	schema graphql.Schema
+	vars   map[string]any
+
+	// fragments are known to be acyclic
+	fragments map[string]*ast.FragmentDefinition
+}
+
+// returns depth and cost of the selection set on a value of given type
+// sized reports whether the enclosing field has already accounted for the number of items (as connections do)
+func (e *estimator) selections(set *ast.SelectionSet, parent graphql.Type, sized bool) (int, int) {
+	var (
+		depth, cost int
+	)
+
+	if set == nil {
+		return 0, 0
+	}
+
+	for _, sel := range set.Selections {
+		var (
+			d, c int
+		)
+
+		switch v := sel.(type) {
+		case *ast.Field:
+			d, c = e.field(v, parent, sized)
+		case *ast.InlineFragment:
+			d, c = e.selections(v.SelectionSet, e.condition(v.TypeCondition, parent), sized)
+		case *ast.FragmentSpread:
+			frag, ok := e.fragments[v.Name.Value]
+			if !ok {
+				continue
+			}
+
+			d, c = e.selections(frag.SelectionSet, e.condition(frag.TypeCondition, parent), sized)
+		}
+
+		depth = max(depth, d)
+		cost = addCost(cost, c)
+	}
+
+	return depth, cost
+}
+
+func (e *estimator) field(f *ast.Field, parent graphql.Type, sized bool) (int, int) {
+	name := f.Name.Value
+
+	// introspection isn't limited
+	if strings.HasPrefix(name, "__") {
+		return 0, 0
+	}
+
+	def := fieldDef(parent, name)
+	if def == nil {
+		return 1, 0
+	}
+
+	var (
+		named, _    = graphql.GetNamed(def.Type).(graphql.Type)
+		_, isList   = graphql.GetNullable(def.Type).(*graphql.List)
+		size, bound = e.size(f)
+
+		cost = 0
+		// number of times the selections are resolved
+		mult = 1
+	)
+
+	switch named.(type) {
+	case *graphql.Object, *graphql.Interface, *graphql.Union:
+		cost = defaultFieldCost
+	}
+
+	if c, ok := fieldCosts[parentName(parent)+"."+name]; ok {
+		cost = c
+	}
+
+	switch {
+	case bound:
+		mult = size
+	case isList && !sized:
+		mult = e.listSize
+	}
+
+	// lists within a bounded field (edges of a connection) are covered by its size
+	depth, sub := e.selections(f.SelectionSet, named, bound && !isList)
+
+	return depth + 1, mulCost(addCost(cost, sub), mult)
+}
+
+// returns value of the size argument of the field, if there's one
+func (e *estimator) size(f *ast.Field) (int, bool) {
+	for _, arg := range f.Arguments {
+		for _, name := range sizeArgs {
+			if arg.Name.Value != name {
+				continue
+			}
+
+			switch v := arg.Value.(type) {
+			case *ast.IntValue:
+				if n, err := strconv.Atoi(v.Value); err == nil && n > 0 {
+					return n, true
+				}
+			case *ast.Variable:
+				switch n := e.vars[v.Name.Value].(type) {
+				case int:
+					if n > 0 {
+						return n, true
+					}
+				case float64:
+					if n > 0 {
+						return int(min(n, float64(costCeiling))), true
+					}
+				}
+			}
+
+			// size is omitted or malformed: it's up to the service to pick it
+			return e.listSize, true
+		}
+	}
+
+	return 0, false
+}
+
+// resolves type condition of a fragment (fragments without one apply to the enclosing type)
+func (e *estimator) condition(cond *ast.Named, parent graphql.Type) graphql.Type {
+	if cond == nil {
+		return parent
+	}
+
+	if t := e.schema.Type(cond.Name.Value); t != nil {
+		return t
+	}
+
+	return parent
+}
+
+// fragment name -> fragment
+func fragments(doc *ast.Document) map[string]*ast.FragmentDefinition {
+	frags := make(map[string]*ast.FragmentDefinition)
+
+	for _, def := range doc.Definitions {
+		if frag, ok := def.(*ast.FragmentDefinition); ok {
+			frags[frag.Name.Value] = frag
+		}
+	}
+
+	return frags
+}
+
+// returns name of a fragment, which (directly or not) spreads itself
+func fragmentCycle(fragments map[string]*ast.FragmentDefinition) (string, bool) {
+	const (
+		visiting = iota + 1
+		visited
+	)
+
+	var (
+		state = make(map[string]int, len(fragments))
+		cycle string
+		visit func(name string) bool
+	)
+
+	visit = func(name string) bool {
+		frag, ok := fragments[name]
+		if !ok || state[name] == visited {
+			return false
+		}
+
+		if state[name] == visiting {
+			cycle = name
+			return true
+		}
+
+		state[name] = visiting
+
+		found := false
+		spreads(frag.SelectionSet, func(name string) {
+			found = found || visit(name)
+		})
+
+		state[name] = visited
+
+		return found
+	}
+
+	for name := range fragments {
+		if visit(name) {
+			return cycle, true
+		}
+	}
+
+	return "", false
+}
+
+// calls fn with names of all of the fragments, spread within the selection set
+func spreads(set *ast.SelectionSet, fn func(name string)) {
+	if set == nil {
+		return
+	}
+
+	for _, sel := range set.Selections {
+		switch v := sel.(type) {
+		case *ast.Field:
+			spreads(v.SelectionSet, fn)
+		case *ast.InlineFragment:
+			spreads(v.SelectionSet, fn)
+		case *ast.FragmentSpread:
+			fn(v.Name.Value)
+		}
+	}
+}
+
+func fieldDef(parent graphql.Type, name string) *graphql.FieldDefinition {
+	switch v := parent.(type) {
+	case *graphql.Object:
+		return v.Fields()[name]
+	case *graphql.Interface:
+		return v.Fields()[name]
+	}
+
+	return nil
+}
+
+func parentName(parent graphql.Type) string {
+	if parent == nil {
+		return ""
+	}
+
+	return parent.Name()
+}
+
+// costs are saturated instead of overflowing on absurdly wide queries
+const costCeiling = 1 << 40
+
+func addCost(a, b int) int {
+	return min(a+b, costCeiling)
+}
+
+func mulCost(a, b int) int {
+	if a != 0 && b > costCeiling/a {
+		return costCeiling
+	}
+
+	return min(a*b, costCeiling)
+}
@@ -0,0 +1,127 @@
+package gql
+
+import (
+	"testing"
+
+	"github.com/cutlery47/posts/config"
+	"github.com/cutlery47/posts/internal/events"
+	"github.com/cutlery47/posts/internal/service"
+	"github.com/cutlery47/posts/internal/storage/post-storage/mem"
+	user "github.com/cutlery47/posts/internal/storage/user-storage"
+	"github.com/cutlery47/posts/internal/storage/user-storage/mock"
+)
+
+// builds handler on top of in-memory storages
+func newHandler(t *testing.T, conf config.Handler, us user.Storage) *gqlHandler {
+	ps, err := mem.NewStorage(config.PostStorage{}, nil)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	t.Cleanup(func() { ps.Close() })
+
+	if us == nil {
+		us = mock.NewStorage(config.UserStorage{})
+	}
+
+	svc, err := service.New(config.Service{PageSize: 20, MaxPageSize: 100}, ps, us, events.NewBus())
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	gh, err := New(conf, svc)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	return gh
+}
+
+// returns depth and cost of the only operation of the query
+func estimate(t *testing.T, gh *gqlHandler, query string, vars map[string]any) (int, int) {
+	doc, errs := gh.parse(query)
+	if errs != nil {
+		t.Fatalf("error: %v", errs[0])
+	}
+
+	e := estimator{
+		limits:    gh.limits,
+		schema:    gh.schema,
+		vars:      vars,
+		fragments: fragments(doc),
+	}
+
+	return e.selections(operation(doc, "").SelectionSet, gh.schema.QueryType(), false)
+}
+
+func TestEstimateCost(t *testing.T) {
+	gh := newHandler(t, config.Handler{QueryListSize: 20}, nil)
+
+	tests := []struct {
+		query string
+		vars  map[string]any
+		cost  int
+	}{
+		// lists without size are assumed to hold QueryListSize items
+		{`{ posts(sort_by: NEWEST) { id } }`, nil, 20},
+		{`{ posts(sort_by: NEWEST, limit: 5) { id } }`, nil, 5},
+		{`query($n: Int) { posts(sort_by: NEWEST, limit: $n) { id } }`, map[string]any{"n": float64(7)}, 7},
+		// omitted variable is up to the service
+		{`query($n: Int) { posts(sort_by: NEWEST, limit: $n) { id } }`, nil, 20},
+		{`{ posts(sort_by: NEWEST, limit: 5) { comments(first: 3) { id } } }`, nil, (1 + 3) * 5},
+		// edges are covered by the size of the connection
+		{`{ postsConnection(sort_by: NEWEST, first: 4) { edges { node { id } } } }`, nil, (1 + 1 + 1) * 4},
+		{`{ search(query: "q", limit: 2) { edges { node { rank } } } }`, nil, (10 + 1 + 1) * 2},
+		{`{ posts(sort_by: NEWEST, limit: 2) { ...f } } fragment f on Post { comments(first: 2) { id } }`, nil, (1 + 2) * 2},
+		{`{ __schema { types { name } } }`, nil, 0},
+		// cost saturates instead of overflowing
+		{`{ posts(sort_by: NEWEST, limit: 1000000) { comments(first: 1000000) { replies(first: 1000000) { replies(first: 1000000) { id } } } } }`, nil, costCeiling},
+	}
+
+	for _, test := range tests {
+		if _, cost := estimate(t, gh, test.query, test.vars); cost != test.cost {
+			t.Fatalf("%v: cost %v, expected %v", test.query, cost, test.cost)
+		}
+	}
+}
+
+func TestEstimateDepth(t *testing.T) {
+	gh := newHandler(t, config.Handler{}, nil)
+
+	depth, _ := estimate(t, gh, `{ posts(sort_by: NEWEST) { comments { replies { id } } } }`, nil)
+	if depth != 4 {
+		t.Fatalf("depth %v, expected 4", depth)
+	}
+}
+
+func TestCheckLimits(t *testing.T) {
+	gh := newHandler(t, config.Handler{MaxQueryDepth: 3, MaxQueryCost: 50, QueryListSize: 20}, nil)
+
+	tests := []struct {
+		query string
+		codes []string
+	}{
+		{`{ posts(sort_by: NEWEST) { id } }`, nil},
+		{`{ posts(sort_by: NEWEST) { comments(first: 2) { id } } }`, []string{"QUERY_TOO_COMPLEX"}},
+		{`{ posts(sort_by: NEWEST, limit: 1) { comments(first: 1) { replies(first: 1) { id } } } }`, []string{"QUERY_TOO_DEEP"}},
+		{`{ posts(sort_by: NEWEST) { comments { replies { id } } } }`, []string{"QUERY_TOO_DEEP", "QUERY_TOO_COMPLEX"}},
+	}
+
+	for _, test := range tests {
+		doc, errs := gh.parse(test.query)
+		if errs != nil {
+			t.Fatalf("error: %v", errs[0])
+		}
+
+		errs = gh.limits.check(gh.schema, doc, "", nil)
+		if len(errs) != len(test.codes) {
+			t.Fatalf("%v: %v errors, expected %v", test.query, len(errs), len(test.codes))
+		}
+
+		for i, err := range errs {
+			if err.Extensions["code"] != test.codes[i] {
+				t.Fatalf("%v: code %v, expected %v", test.query, err.Extensions["code"], test.codes[i])
+			}
+		}
+	}
+}
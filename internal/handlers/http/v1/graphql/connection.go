@@ -0,0 +1,285 @@
+package gql
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+
+	storage "github.com/cutlery47/posts/internal/storage/post-storage"
+	"github.com/graphql-go/graphql"
+)
+
+var (
+	ErrBadCursor = errors.New("bad cursor")
+)
+
+// relay-style connection
+type connection struct {
+	Edges    []edge   `json:"edges"`
+	PageInfo pageInfo `json:"pageInfo"`
+}
+
+type edge struct {
+	Cursor string `json:"cursor"`
+	Node   any    `json:"node"`
+}
+
+type pageInfo struct {
+	HasNextPage bool    `json:"hasNextPage"`
+	EndCursor   *string `json:"endCursor"`
+}
+
+// contents of an opaque cursor of a sorted item
+type cursor struct {
+	SortBy storage.SortKey `json:"sort_by"`
+
+	storage.Cursor
+}
+
+func encodeCursor(v any) (string, error) {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(raw), nil
+}
+
+func decodeCursor(s string, v any) error {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return ErrBadCursor
+	}
+
+	if err := json.Unmarshal(raw, v); err != nil {
+		return ErrBadCursor
+	}
+
+	return nil
+}
+
+// encodes cursors of items, sorted by given key
+func sortedCursor[T interface{ Cursor() storage.Cursor }](sortBy storage.SortKey) func(v T) (string, error) {
+	return func(v T) (string, error) {
+		return encodeCursor(cursor{SortBy: sortBy, Cursor: v.Cursor()})
+	}
+}
+
+func searchCursor(v storage.SearchHit) (string, error) {
+	return encodeCursor(v.Cursor())
+}
+
+func toConnection[T any](page *storage.Page[T], cursorOf func(v T) (string, error)) (*connection, error) {
+	conn := &connection{
+		Edges: make([]edge, 0, len(page.Items)),
+		PageInfo: pageInfo{
+			HasNextPage: page.HasNextPage,
+		},
+	}
+
+	for _, v := range page.Items {
+		c, err := cursorOf(v)
+		if err != nil {
+			return nil, err
+		}
+
+		conn.Edges = append(conn.Edges, edge{
+			Cursor: c,
+			Node:   v,
+		})
+	}
+
+	if len(conn.Edges) != 0 {
+		conn.PageInfo.EndCursor = &conn.Edges[len(conn.Edges)-1].Cursor
+	}
+
+	return conn, nil
+}
+
+// parses first / after / sort_by / window arguments
+func pageQueryFromArgs(args map[string]any) (*storage.PageQuery, error) {
+	sortBy, ok := args["sort_by"].(string)
+	if !ok {
+		return nil, ErrBadArgType
+	}
+
+	q := &storage.PageQuery{
+		SortBy: storage.SortKey(sortBy),
+	}
+
+	if firstArg, ok := args["first"]; ok {
+		first, ok := firstArg.(int)
+		if !ok {
+			return nil, ErrBadArgType
+		}
+		q.First = first
+	}
+
+	if windowArg, ok := args["window"]; ok {
+		window, ok := windowArg.(string)
+		if !ok {
+			return nil, ErrBadArgType
+		}
+		q.Window = storage.Window(window)
+	}
+
+	if afterArg, ok := args["after"]; ok {
+		afterStr, ok := afterArg.(string)
+		if !ok {
+			return nil, ErrBadArgType
+		}
+
+		var after cursor
+
+		if err := decodeCursor(afterStr, &after); err != nil {
+			return nil, err
+		}
+
+		// cursors can't be mixed between orderings
+		if after.SortBy != q.SortBy {
+			return nil, ErrBadCursor
+		}
+
+		q.After = &after.Cursor
+	}
+
+	return q, nil
+}
+
+// parses query / kind / limit / after arguments
+func searchQueryFromArgs(args map[string]any) (*storage.SearchQuery, error) {
+	query, ok := args["query"].(string)
+	if !ok {
+		return nil, ErrBadArgType
+	}
+
+	q := &storage.SearchQuery{
+		Query: query,
+	}
+
+	if kindArg, ok := args["kind"]; ok {
+		kind, ok := kindArg.(storage.SearchKind)
+		if !ok {
+			return nil, ErrBadArgType
+		}
+		q.Kind = kind
+	}
+
+	if limitArg, ok := args["limit"]; ok {
+		limit, ok := limitArg.(int)
+		if !ok {
+			return nil, ErrBadArgType
+		}
+		q.Limit = limit
+	}
+
+	if afterArg, ok := args["after"]; ok {
+		afterStr, ok := afterArg.(string)
+		if !ok {
+			return nil, ErrBadArgType
+		}
+
+		var after storage.SearchCursor
+
+		if err := decodeCursor(afterStr, &after); err != nil {
+			return nil, err
+		}
+
+		q.After = &after
+	}
+
+	return q, nil
+}
+
+var pageInfoType = graphql.NewObject(
+	graphql.ObjectConfig{
+		Name: "PageInfo",
+		Fields: graphql.Fields{
+			"hasNextPage": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.Boolean),
+			},
+			"endCursor": &graphql.Field{
+				Type: graphql.String,
+			},
+		},
+	},
+)
+
+// builds connection type and its edge type for given node type
+func connectionType(node *graphql.Object) *graphql.Object {
+	edgeType := graphql.NewObject(
+		graphql.ObjectConfig{
+			Name: node.Name() + "Edge",
+			Fields: graphql.Fields{
+				"cursor": &graphql.Field{
+					Type: graphql.NewNonNull(graphql.String),
+				},
+				"node": &graphql.Field{
+					Type: graphql.NewNonNull(node),
+				},
+			},
+		},
+	)
+
+	return graphql.NewObject(
+		graphql.ObjectConfig{
+			Name: node.Name() + "Connection",
+			Fields: graphql.Fields{
+				"edges": &graphql.Field{
+					Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(edgeType))),
+				},
+				"pageInfo": &graphql.Field{
+					Type: graphql.NewNonNull(pageInfoType),
+				},
+			},
+		},
+	)
+}
+
+var windowEnum = graphql.NewEnum(
+	graphql.EnumConfig{
+		Name:        "WindowEnum",
+		Description: "limits items to the ones, created within the period",
+		Values: graphql.EnumValueConfigMap{
+			"DAY": &graphql.EnumValueConfig{
+				Value: "day",
+			},
+			"WEEK": &graphql.EnumValueConfig{
+				Value: "week",
+			},
+			"ALL": &graphql.EnumValueConfig{
+				Value: "all",
+			},
+		},
+	},
+)
+
+// builds first / after / sort_by / window arguments
+func connectionArgs(sortEnum *graphql.Enum) graphql.FieldConfigArgument {
+	return graphql.FieldConfigArgument{
+		"first": &graphql.ArgumentConfig{
+			Type: graphql.Int,
+		},
+		"after": &graphql.ArgumentConfig{
+			Type: graphql.String,
+		},
+		"sort_by": &graphql.ArgumentConfig{
+			Type: graphql.NewNonNull(sortEnum),
+		},
+		"window": &graphql.ArgumentConfig{
+			Type: windowEnum,
+		},
+	}
+}
+
+// builds first / after / sort_by / window arguments of a plain list, which is sorted by given key unless specified
+func listArgs(sortEnum *graphql.Enum, sortBy storage.SortKey) graphql.FieldConfigArgument {
+	args := connectionArgs(sortEnum)
+
+	args["sort_by"] = &graphql.ArgumentConfig{
+		Type:         sortEnum,
+		DefaultValue: string(sortBy),
+	}
+
+	return args
+}
@@ -3,63 +3,286 @@ package gql
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
+	"io"
+	"mime"
 	"net/http"
+	"net/url"
+	"strings"
 
+	"github.com/cutlery47/posts/config"
 	"github.com/cutlery47/posts/internal/service"
-	errh "github.com/cutlery47/posts/pkg/errhandle"
 	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/gqlerrors"
+	"github.com/graphql-go/graphql/language/ast"
+	"github.com/graphql-go/graphql/language/parser"
+	"github.com/graphql-go/graphql/language/source"
 )
 
+// graphql-over-http: https://graphql.github.io/graphql-over-http/draft/
+const (
+	mediaJSON            = "application/json"
+	mediaGraphQL         = "application/graphql"
+	mediaGraphQLResponse = "application/graphql-response+json"
+
+	// max size of a request body
+	maxBodySize = 1 << 20
+)
+
+var (
+	ErrNoQuery          = errors.New("query was not provided")
+	ErrBadBody          = errors.New("request body is malformed")
+	ErrBodyTooLarge     = errors.New("request body is too large")
+	ErrBadParams        = errors.New("variables and extensions should be json objects")
+	ErrBadMediaType     = errors.New("content type should be either application/json or application/graphql")
+	ErrMethodNotAllowed = errors.New("method not allowed")
+	ErrGetNotQuery      = errors.New("only queries can be sent with GET")
+)
+
+// graphql request, as it's sent over http or graphql-ws
+type request struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName"`
+	Variables     map[string]interface{} `json:"variables"`
+	Extensions    map[string]interface{} `json:"extensions"`
+}
+
+// response to a request, which wasn't executed (it holds no data at all, unlike graphql.Result)
+type requestError struct {
+	Errors []gqlerrors.FormattedError `json:"errors"`
+}
+
 type gqlHandler struct {
 	svc *service.Service
 
-	schema graphql.Schema
+	schema  graphql.Schema
+	limits  limits
+	queries persisted
 }
 
-func New(svc *service.Service) (*gqlHandler, error) {
+func New(conf config.Handler, svc *service.Service) (*gqlHandler, error) {
 	gh := &gqlHandler{
-		svc: svc,
+		svc:    svc,
+		limits: newLimits(conf),
 	}
 
 	if err := gh.initSchema(); err != nil {
 		return nil, err
 	}
 
+	if conf.PersistedQueryCacheSize > 0 {
+		gh.queries.cache = newQueryCache(conf.PersistedQueryCacheSize)
+	}
+
+	if conf.PersistedQueryAllowList != "" {
+		if err := gh.loadAllowList(conf.PersistedQueryAllowList); err != nil {
+			return nil, fmt.Errorf("can't load allow-list of queries: %v", err)
+		}
+	}
+
 	return gh, nil
 }
 
 func (gh *gqlHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		w.WriteHeader(http.StatusMethodNotAllowed)
-		w.Write([]byte("method not allowed"))
-		return
-	}
-
-	queryJson := make(map[string]interface{})
+	var (
+		media = responseType(r)
+	)
 
-	err := json.NewDecoder(r.Body).Decode(&queryJson)
+	req, status, err := readRequest(w, r)
 	if err != nil {
-		errh.Handle(err, w)
+		if status == http.StatusMethodNotAllowed {
+			w.Header().Set("Allow", "GET, POST")
+		}
+		writeResult(w, media, status, requestError{Errors: gqlerrors.FormatErrors(err)})
 		return
 	}
 
-	queryField, ok := queryJson["query"]
-	if !ok {
-		errh.Handle(errors.New("query was not provided"), w)
+	doc, errs := gh.prepare(req)
+	if errs != nil {
+		// the operation wasn't executed, which is reported with 400 only to the clients, that follow the spec
+		status := http.StatusOK
+		if media == mediaGraphQLResponse {
+			status = http.StatusBadRequest
+		}
+
+		writeResult(w, media, status, requestError{Errors: errs})
 		return
 	}
 
-	queryString, ok := queryField.(string)
-	if !ok {
-		errh.Handle(errors.New("query can't be converted to string"), w)
-		return
+	// GET requests may be issued by links or prefetching, so they can't change anything
+	if r.Method == http.MethodGet {
+		if op := operation(doc, req.OperationName); op != nil && op.Operation != ast.OperationTypeQuery {
+			w.Header().Set("Allow", "POST")
+			writeResult(w, media, http.StatusMethodNotAllowed, requestError{Errors: gqlerrors.FormatErrors(ErrGetNotQuery)})
+			return
+		}
 	}
 
-	res := graphql.Do(graphql.Params{
-		Context:       r.Context(),
+	res := graphql.Execute(graphql.ExecuteParams{
+		Context:       withLoader(r.Context(), gh.svc),
 		Schema:        gh.schema,
-		RequestString: queryString,
+		AST:           doc,
+		OperationName: req.OperationName,
+		Args:          req.Variables,
+	})
+
+	writeResult(w, media, http.StatusOK, res)
+}
+
+// returns validated document of the request, rejecting operations over the limits
+// returned errors mean that the operation can't be executed at all
+func (gh *gqlHandler) prepare(req *request) (*ast.Document, []gqlerrors.FormattedError) {
+	doc, errs := gh.document(req)
+	if errs != nil {
+		return nil, errs
+	}
+
+	if errs := gh.limits.check(gh.schema, doc, req.OperationName, req.Variables); errs != nil {
+		return nil, errs
+	}
+
+	return doc, nil
+}
+
+// parses and validates the query
+func (gh *gqlHandler) parse(query string) (*ast.Document, []gqlerrors.FormattedError) {
+	doc, err := parser.Parse(parser.ParseParams{
+		Source: source.NewSource(&source.Source{
+			Body: []byte(query),
+			Name: "GraphQL request",
+		}),
 	})
+	if err != nil {
+		return nil, gqlerrors.FormatErrors(err)
+	}
+
+	// graphql-go overflows the stack on cyclic fragments instead of reporting them
+	if name, ok := fragmentCycle(fragments(doc)); ok {
+		return nil, []gqlerrors.FormattedError{
+			queryError("FRAGMENT_CYCLE", fmt.Sprintf("fragment %q spreads itself", name), map[string]any{}),
+		}
+	}
+
+	if res := graphql.ValidateDocument(&gh.schema, doc, nil); !res.IsValid {
+		return nil, res.Errors
+	}
+
+	return doc, nil
+}
+
+// reads request from the url (GET) or the body (POST)
+// returns status, which should be replied with, if the request is malformed
+func readRequest(w http.ResponseWriter, r *http.Request) (*request, int, error) {
+	var (
+		req = &request{}
+	)
+
+	switch r.Method {
+	case http.MethodGet:
+		if err := req.fromParams(r.URL.Query()); err != nil {
+			return nil, http.StatusBadRequest, err
+		}
+	case http.MethodPost:
+		media := mediaJSON
+
+		// body is assumed to be json, if its type isn't set
+		if ct := r.Header.Get("Content-Type"); ct != "" {
+			var err error
+
+			media, _, err = mime.ParseMediaType(ct)
+			if err != nil {
+				return nil, http.StatusUnsupportedMediaType, ErrBadMediaType
+			}
+		}
+
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBodySize))
+		if err != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				return nil, http.StatusRequestEntityTooLarge, ErrBodyTooLarge
+			}
+			return nil, http.StatusBadRequest, ErrBadBody
+		}
+
+		switch media {
+		case mediaJSON:
+			if err := json.Unmarshal(body, req); err != nil {
+				return nil, http.StatusBadRequest, ErrBadBody
+			}
+		case mediaGraphQL:
+			// the rest of the request is passed in the url
+			if err := req.fromParams(r.URL.Query()); err != nil {
+				return nil, http.StatusBadRequest, err
+			}
+			req.Query = string(body)
+		default:
+			return nil, http.StatusUnsupportedMediaType, ErrBadMediaType
+		}
+	default:
+		return nil, http.StatusMethodNotAllowed, ErrMethodNotAllowed
+	}
+
+	// persisted queries may be sent without their text
+	if _, ok := req.Extensions["persistedQuery"]; !ok && strings.TrimSpace(req.Query) == "" {
+		return nil, http.StatusBadRequest, ErrNoQuery
+	}
+
+	return req, 0, nil
+}
+
+// fills the request from url parameters, where variables and extensions are json-encoded
+func (req *request) fromParams(params url.Values) error {
+	req.Query = params.Get("query")
+	req.OperationName = params.Get("operationName")
+
+	for name, dst := range map[string]*map[string]interface{}{
+		"variables":  &req.Variables,
+		"extensions": &req.Extensions,
+	} {
+		if v := params.Get(name); v != "" {
+			if err := json.Unmarshal([]byte(v), dst); err != nil {
+				return ErrBadParams
+			}
+		}
+	}
+
+	return nil
+}
+
+// picks media type of the response: the one from the spec, if the client accepts it, or plain json otherwise
+func responseType(r *http.Request) string {
+	for _, v := range strings.Split(r.Header.Get("Accept"), ",") {
+		if media, _, err := mime.ParseMediaType(v); err == nil && media == mediaGraphQLResponse {
+			return mediaGraphQLResponse
+		}
+	}
+
+	return mediaJSON
+}
+
+func writeResult(w http.ResponseWriter, media string, status int, res any) {
+	w.Header().Set("Content-Type", media+"; charset=utf-8")
+	w.WriteHeader(status)
 
 	json.NewEncoder(w).Encode(res)
 }
+
+// returns operation, which is requested by its name (or the only one, if the name is empty)
+func operation(doc *ast.Document, operationName string) *ast.OperationDefinition {
+	if doc == nil {
+		return nil
+	}
+
+	for _, def := range doc.Definitions {
+		op, ok := def.(*ast.OperationDefinition)
+		if !ok {
+			continue
+		}
+
+		if operationName == "" || (op.Name != nil && op.Name.Value == operationName) {
+			return op
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,115 @@
+package gql
+
+import (
+	"context"
+	"sync"
+
+	"github.com/cutlery47/posts/internal/service"
+	user "github.com/cutlery47/posts/internal/storage/user-storage"
+	"github.com/google/uuid"
+)
+
+type loaderKey struct{}
+
+// batches lookups of users, requested while a single operation is resolved
+// graphql-go resolves deferred fields (thunks) only after the rest of the result is built,
+// so every user, requested by then, is fetched at once
+type userLoader struct {
+	ctx context.Context
+	svc *service.Service
+
+	mu sync.Mutex
+	// ids, which are yet to be fetched
+	pending map[uuid.UUID]bool
+	// fetched users (nil for the unknown ones)
+	users map[uuid.UUID]*user.User
+	// errors of the failed lookups
+	errs map[uuid.UUID]error
+}
+
+// returns context, which carries a loader for a single operation
+func withLoader(ctx context.Context, svc *service.Service) context.Context {
+	return context.WithValue(ctx, loaderKey{}, &userLoader{
+		ctx:     ctx,
+		svc:     svc,
+		pending: make(map[uuid.UUID]bool),
+		users:   make(map[uuid.UUID]*user.User),
+		errs:    make(map[uuid.UUID]error),
+	})
+}
+
+func loaderFromContext(ctx context.Context) (*userLoader, bool) {
+	l, ok := ctx.Value(loaderKey{}).(*userLoader)
+	return l, ok
+}
+
+// queues the user to be fetched and returns a thunk, which resolves it
+func (l *userLoader) load(id uuid.UUID) func() (interface{}, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if !l.known(id) {
+		l.pending[id] = true
+	}
+
+	return func() (interface{}, error) {
+		return l.get(id)
+	}
+}
+
+func (l *userLoader) get(id uuid.UUID) (*user.User, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if !l.known(id) {
+		l.flush()
+	}
+
+	if err, ok := l.errs[id]; ok {
+		return nil, err
+	}
+
+	u := l.users[id]
+	if u == nil {
+		return nil, user.ErrUserNotFound
+	}
+
+	return u, nil
+}
+
+// fetches all of the queued users with a single lookup
+func (l *userLoader) flush() {
+	var (
+		ids = make([]uuid.UUID, 0, len(l.pending))
+	)
+
+	for id := range l.pending {
+		ids = append(ids, id)
+	}
+
+	clear(l.pending)
+
+	users, err := l.svc.GetUsers(l.ctx, ids)
+	if err != nil {
+		for _, id := range ids {
+			l.errs[id] = err
+		}
+		return
+	}
+
+	for _, id := range ids {
+		l.users[id] = nil
+	}
+
+	for i := range users {
+		l.users[users[i].Id] = &users[i]
+	}
+}
+
+// reports whether the user has already been looked up
+func (l *userLoader) known(id uuid.UUID) bool {
+	_, fetched := l.users[id]
+	_, failed := l.errs[id]
+
+	return fetched || failed
+}
@@ -0,0 +1,153 @@
+package gql
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/cutlery47/posts/config"
+	post "github.com/cutlery47/posts/internal/storage/post-storage"
+	user "github.com/cutlery47/posts/internal/storage/user-storage"
+	"github.com/cutlery47/posts/internal/storage/user-storage/mock"
+	"github.com/google/uuid"
+	"github.com/graphql-go/graphql"
+)
+
+// counts batched lookups of users
+type countingUsers struct {
+	user.Storage
+
+	calls int
+	err   error
+}
+
+func (cu *countingUsers) GetUsers(ctx context.Context, ids []uuid.UUID) ([]user.User, error) {
+	cu.calls++
+
+	if cu.err != nil {
+		return nil, cu.err
+	}
+
+	return cu.Storage.GetUsers(ctx, ids)
+}
+
+func register(t *testing.T, us user.Storage, name string) *user.User {
+	u, err := us.Register(context.Background(), user.InUser{Name: name, Role: user.UserRole, Password: "password"})
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	return u
+}
+
+func TestLoaderBatch(t *testing.T) {
+	var (
+		us = &countingUsers{Storage: mock.NewStorage(config.UserStorage{})}
+		gh = newHandler(t, config.Handler{}, us)
+
+		alice = register(t, us, "alice")
+		bob   = register(t, us, "bob")
+	)
+
+	l, _ := loaderFromContext(withLoader(context.Background(), gh.svc))
+
+	var (
+		thunks = []func() (interface{}, error){
+			l.load(alice.Id),
+			l.load(bob.Id),
+			l.load(alice.Id),
+		}
+		unknown = l.load(uuid.New())
+	)
+
+	for i, id := range []uuid.UUID{alice.Id, bob.Id, alice.Id} {
+		v, err := thunks[i]()
+		if err != nil {
+			t.Fatalf("error: %v", err)
+		}
+
+		if v.(*user.User).Id != id {
+			t.Fatalf("got user %v, expected %v", v.(*user.User).Id, id)
+		}
+	}
+
+	if _, err := unknown(); !errors.Is(err, user.ErrUserNotFound) {
+		t.Fatalf("unknown user was found")
+	}
+
+	if us.calls != 1 {
+		t.Fatalf("users were fetched %v times, expected once", us.calls)
+	}
+
+	// fetched users are reused
+	if _, err := l.load(bob.Id)(); err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	if us.calls != 1 {
+		t.Fatalf("fetched user was looked up again")
+	}
+}
+
+func TestLoaderError(t *testing.T) {
+	var (
+		us = &countingUsers{Storage: mock.NewStorage(config.UserStorage{}), err: errors.New("unavailable")}
+		gh = newHandler(t, config.Handler{}, us)
+	)
+
+	l, _ := loaderFromContext(withLoader(context.Background(), gh.svc))
+
+	var (
+		first  = l.load(uuid.New())
+		second = l.load(uuid.New())
+	)
+
+	for _, thunk := range []func() (interface{}, error){first, second} {
+		if _, err := thunk(); !errors.Is(err, us.err) {
+			t.Fatalf("lookup didn't fail: %v", err)
+		}
+	}
+
+	if us.calls != 1 {
+		t.Fatalf("users were fetched %v times, expected once", us.calls)
+	}
+}
+
+func TestLoaderQuery(t *testing.T) {
+	var (
+		ctx = context.Background()
+		us  = &countingUsers{Storage: mock.NewStorage(config.UserStorage{})}
+		gh  = newHandler(t, config.Handler{}, us)
+
+		authors = []*user.User{register(t, us, "alice"), register(t, us, "bob")}
+	)
+
+	for i := 0; i < 30; i++ {
+		if _, err := gh.svc.InsertPost(ctx, post.InPost{Content: "post"}, authors[i%len(authors)].Id); err != nil {
+			t.Fatalf("error: %v", err)
+		}
+	}
+
+	doc, errs := gh.parse(`{ posts(sort_by: NEWEST) { author { in_user { name } } } }`)
+	if errs != nil {
+		t.Fatalf("error: %v", errs[0])
+	}
+
+	res := graphql.Execute(graphql.ExecuteParams{
+		Context: withLoader(ctx, gh.svc),
+		Schema:  gh.schema,
+		AST:     doc,
+	})
+	if res.HasErrors() {
+		t.Fatalf("error: %v", res.Errors[0])
+	}
+
+	// posts without limit are capped by the page size
+	if posts := res.Data.(map[string]interface{})["posts"].([]interface{}); len(posts) != 20 {
+		t.Fatalf("got %v posts, expected 20", len(posts))
+	}
+
+	if us.calls != 1 {
+		t.Fatalf("authors were fetched %v times, expected once", us.calls)
+	}
+}
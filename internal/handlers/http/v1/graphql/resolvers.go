@@ -1,16 +1,33 @@
 package gql
 
 import (
+	"log"
+
+	"github.com/cutlery47/posts/internal/handlers/http/v1/auth"
+	storage "github.com/cutlery47/posts/internal/storage/post-storage"
 	"github.com/google/uuid"
 	"github.com/graphql-go/graphql"
 )
 
+// returns user, who made the request
 func (gh *gqlHandler) getSessionUser(p graphql.ResolveParams) (uuid.UUID, error) {
-	seshId, err := idFromArg(p.Args["sesh_id"])
+	if id, ok := auth.FromContext(p.Context); ok {
+		return id.UserId, nil
+	}
+
+	// fallback for clients, which still pass session as an argument
+	arg, ok := p.Args["sesh_id"]
+	if !ok || arg == nil {
+		return uuid.UUID{}, auth.ErrNoSession
+	}
+
+	seshId, err := idFromArg(arg)
 	if err != nil {
 		return uuid.UUID{}, err
 	}
 
+	log.Println("[DEPRECATED] session was passed as sesh_id argument")
+
 	return gh.svc.GetSessionUser(p.Context, *seshId)
 }
 
@@ -23,6 +40,168 @@ func (gh *gqlHandler) resolveQueryPost(p graphql.ResolveParams) (interface{}, er
 	return gh.svc.GetPost(p.Context, *id)
 }
 
+func (gh *gqlHandler) resolveQueryUser(p graphql.ResolveParams) (interface{}, error) {
+	var (
+		idArg   = p.Args["id"]
+		nameArg = p.Args["name"]
+	)
+
+	if (idArg == nil) == (nameArg == nil) {
+		return nil, ErrBadUserArgs
+	}
+
+	if nameArg != nil {
+		return gh.svc.GetUserByName(p.Context, stringFromArg(nameArg))
+	}
+
+	id, err := idFromArg(idArg)
+	if err != nil {
+		return nil, err
+	}
+
+	return gh.loadUser(p, *id)
+}
+
+func (gh *gqlHandler) resolveUserKarma(p graphql.ResolveParams) (interface{}, error) {
+	src, err := userFromSource(p.Source)
+	if err != nil {
+		return nil, err
+	}
+
+	return gh.svc.GetKarma(p.Context, src.Id)
+}
+
+func (gh *gqlHandler) resolveUserPosts(p graphql.ResolveParams) (interface{}, error) {
+	src, err := userFromSource(p.Source)
+	if err != nil {
+		return nil, err
+	}
+
+	q, err := pageQueryFromArgs(p.Args)
+	if err != nil {
+		return nil, err
+	}
+
+	page, err := gh.svc.GetPostsByAuthor(p.Context, src.Id, *q)
+	if err != nil {
+		return nil, err
+	}
+
+	return page.Items, nil
+}
+
+func (gh *gqlHandler) resolveUserPostsConnection(p graphql.ResolveParams) (interface{}, error) {
+	src, err := userFromSource(p.Source)
+	if err != nil {
+		return nil, err
+	}
+
+	q, err := pageQueryFromArgs(p.Args)
+	if err != nil {
+		return nil, err
+	}
+
+	page, err := gh.svc.GetPostsByAuthor(p.Context, src.Id, *q)
+	if err != nil {
+		return nil, err
+	}
+
+	return toConnection(page, sortedCursor[storage.Post](q.SortBy))
+}
+
+func (gh *gqlHandler) resolveUserComments(p graphql.ResolveParams) (interface{}, error) {
+	src, err := userFromSource(p.Source)
+	if err != nil {
+		return nil, err
+	}
+
+	q, err := pageQueryFromArgs(p.Args)
+	if err != nil {
+		return nil, err
+	}
+
+	page, err := gh.svc.GetCommentsByAuthor(p.Context, src.Id, *q)
+	if err != nil {
+		return nil, err
+	}
+
+	return page.Items, nil
+}
+
+func (gh *gqlHandler) resolveUserCommentsConnection(p graphql.ResolveParams) (interface{}, error) {
+	src, err := userFromSource(p.Source)
+	if err != nil {
+		return nil, err
+	}
+
+	q, err := pageQueryFromArgs(p.Args)
+	if err != nil {
+		return nil, err
+	}
+
+	page, err := gh.svc.GetCommentsByAuthor(p.Context, src.Id, *q)
+	if err != nil {
+		return nil, err
+	}
+
+	return toConnection(page, sortedCursor[storage.Comment](q.SortBy))
+}
+
+func (gh *gqlHandler) resolvePostAuthor(p graphql.ResolveParams) (interface{}, error) {
+	src, err := postFromSource(p.Source)
+	if err != nil {
+		return nil, err
+	}
+
+	return gh.loadUser(p, src.UserId)
+}
+
+func (gh *gqlHandler) resolveCommentAuthor(p graphql.ResolveParams) (interface{}, error) {
+	src, err := commentFromSource(p.Source)
+	if err != nil {
+		return nil, err
+	}
+
+	return gh.loadUser(p, src.UserId)
+}
+
+func (gh *gqlHandler) resolveRevisionEditor(p graphql.ResolveParams) (interface{}, error) {
+	src, err := revisionFromSource(p.Source)
+	if err != nil {
+		return nil, err
+	}
+
+	return gh.loadUser(p, src.EditorId)
+}
+
+func (gh *gqlHandler) resolvePostRevisions(p graphql.ResolveParams) (interface{}, error) {
+	src, err := postFromSource(p.Source)
+	if err != nil {
+		return nil, err
+	}
+
+	return gh.svc.GetPostRevisions(p.Context, src)
+}
+
+func (gh *gqlHandler) resolveCommentRevisions(p graphql.ResolveParams) (interface{}, error) {
+	src, err := commentFromSource(p.Source)
+	if err != nil {
+		return nil, err
+	}
+
+	return gh.svc.GetCommentRevisions(p.Context, src)
+}
+
+// defers the lookup of a user, so that it's batched with the rest of the operation
+func (gh *gqlHandler) loadUser(p graphql.ResolveParams, id uuid.UUID) (interface{}, error) {
+	if l, ok := loaderFromContext(p.Context); ok {
+		return l.load(id), nil
+	}
+
+	// subscriptions are resolved without a loader, since they outlive a single result
+	return gh.svc.GetUser(p.Context, id)
+}
+
 func (gh *gqlHandler) resolveQueryPosts(p graphql.ResolveParams) (interface{}, error) {
 	var (
 		limit  *int
@@ -44,7 +223,130 @@ func (gh *gqlHandler) resolveQueryPosts(p graphql.ResolveParams) (interface{}, e
 		offset = &v
 	}
 
-	return gh.svc.GetPosts(p.Context, limit, offset, sortBy)
+	return gh.svc.GetPosts(p.Context, limit, offset, storage.SortKey(sortBy))
+}
+
+func (gh *gqlHandler) resolveQueryPostsConnection(p graphql.ResolveParams) (interface{}, error) {
+	q, err := pageQueryFromArgs(p.Args)
+	if err != nil {
+		return nil, err
+	}
+
+	page, err := gh.svc.GetPostsPage(p.Context, *q)
+	if err != nil {
+		return nil, err
+	}
+
+	return toConnection(page, sortedCursor[storage.Post](q.SortBy))
+}
+
+func (gh *gqlHandler) resolvePostComments(p graphql.ResolveParams) (interface{}, error) {
+	src, err := postFromSource(p.Source)
+	if err != nil {
+		return nil, err
+	}
+
+	q, err := pageQueryFromArgs(p.Args)
+	if err != nil {
+		return nil, err
+	}
+
+	page, err := gh.svc.GetCommentsPage(p.Context, src.Id, nil, *q)
+	if err != nil {
+		return nil, err
+	}
+
+	return page.Items, nil
+}
+
+func (gh *gqlHandler) resolveCommentReplies(p graphql.ResolveParams) (interface{}, error) {
+	src, err := commentFromSource(p.Source)
+	if err != nil {
+		return nil, err
+	}
+
+	q, err := pageQueryFromArgs(p.Args)
+	if err != nil {
+		return nil, err
+	}
+
+	page, err := gh.svc.GetCommentsPage(p.Context, src.PostId, &src.Id, *q)
+	if err != nil {
+		return nil, err
+	}
+
+	return page.Items, nil
+}
+
+func (gh *gqlHandler) resolvePostCommentsConnection(p graphql.ResolveParams) (interface{}, error) {
+	src, err := postFromSource(p.Source)
+	if err != nil {
+		return nil, err
+	}
+
+	q, err := pageQueryFromArgs(p.Args)
+	if err != nil {
+		return nil, err
+	}
+
+	page, err := gh.svc.GetCommentsPage(p.Context, src.Id, nil, *q)
+	if err != nil {
+		return nil, err
+	}
+
+	return toConnection(page, sortedCursor[storage.Comment](q.SortBy))
+}
+
+func (gh *gqlHandler) resolveCommentRepliesConnection(p graphql.ResolveParams) (interface{}, error) {
+	src, err := commentFromSource(p.Source)
+	if err != nil {
+		return nil, err
+	}
+
+	q, err := pageQueryFromArgs(p.Args)
+	if err != nil {
+		return nil, err
+	}
+
+	page, err := gh.svc.GetCommentsPage(p.Context, src.PostId, &src.Id, *q)
+	if err != nil {
+		return nil, err
+	}
+
+	return toConnection(page, sortedCursor[storage.Comment](q.SortBy))
+}
+
+func (gh *gqlHandler) resolveQuerySearch(p graphql.ResolveParams) (interface{}, error) {
+	q, err := searchQueryFromArgs(p.Args)
+	if err != nil {
+		return nil, err
+	}
+
+	page, err := gh.svc.Search(p.Context, *q)
+	if err != nil {
+		return nil, err
+	}
+
+	return toConnection(page, searchCursor)
+}
+
+func (gh *gqlHandler) resolveQueryTrash(p graphql.ResolveParams) (interface{}, error) {
+	userId, err := gh.getSessionUser(p)
+	if err != nil {
+		return nil, err
+	}
+
+	ownerId := &userId
+
+	ownerIdArg, ok := p.Args["user_id"]
+	if ok {
+		ownerId, err = idFromArg(ownerIdArg)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return gh.svc.GetTrash(p.Context, userId, *ownerId)
 }
 
 func (gh *gqlHandler) resolveMutationInsertPost(p graphql.ResolveParams) (interface{}, error) {
@@ -72,7 +374,26 @@ func (gh *gqlHandler) resolveMutationDeletePost(p graphql.ResolveParams) (interf
 		return nil, err
 	}
 
-	return gh.svc.DeletePost(p.Context, *id, userId)
+	version, err := versionFromArg(p.Args["expected_version"])
+	if err != nil {
+		return nil, err
+	}
+
+	return withConflict(gh.svc.DeletePost(p.Context, *id, userId, stringFromArg(p.Args["reason"]), version))
+}
+
+func (gh *gqlHandler) resolveMutationRestorePost(p graphql.ResolveParams) (interface{}, error) {
+	userId, err := gh.getSessionUser(p)
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := idFromArg(p.Args["id"])
+	if err != nil {
+		return nil, err
+	}
+
+	return gh.svc.RestorePost(p.Context, *id, userId, stringFromArg(p.Args["reason"]))
 }
 
 func (gh *gqlHandler) resolveMutationUpdatePost(p graphql.ResolveParams) (interface{}, error) {
@@ -91,7 +412,31 @@ func (gh *gqlHandler) resolveMutationUpdatePost(p graphql.ResolveParams) (interf
 		return nil, err
 	}
 
-	return gh.svc.UpdatePost(p.Context, *id, userId, *in)
+	version, err := versionFromArg(p.Args["expected_version"])
+	if err != nil {
+		return nil, err
+	}
+
+	return withConflict(gh.svc.UpdatePost(p.Context, *id, userId, *in, version))
+}
+
+func (gh *gqlHandler) resolveMutationVotePost(p graphql.ResolveParams) (interface{}, error) {
+	userId, err := gh.getSessionUser(p)
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := idFromArg(p.Args["post_id"])
+	if err != nil {
+		return nil, err
+	}
+
+	vote, err := voteFromArg(p.Args["vote"])
+	if err != nil {
+		return nil, err
+	}
+
+	return gh.svc.VotePost(p.Context, *id, userId, vote)
 }
 
 func (gh *gqlHandler) resolveMutationInsertComment(p graphql.ResolveParams) (interface{}, error) {
@@ -143,7 +488,31 @@ func (gh *gqlHandler) resolveMutationDeleteComment(p graphql.ResolveParams) (int
 		return nil, err
 	}
 
-	return gh.svc.DeleteComment(p.Context, *postId, *commId, userId)
+	version, err := versionFromArg(p.Args["expected_version"])
+	if err != nil {
+		return nil, err
+	}
+
+	return withConflict(gh.svc.DeleteComment(p.Context, *postId, *commId, userId, stringFromArg(p.Args["reason"]), version))
+}
+
+func (gh *gqlHandler) resolveMutationRestoreComment(p graphql.ResolveParams) (interface{}, error) {
+	userId, err := gh.getSessionUser(p)
+	if err != nil {
+		return nil, err
+	}
+
+	postId, err := idFromArg(p.Args["post_id"])
+	if err != nil {
+		return nil, err
+	}
+
+	commId, err := idFromArg(p.Args["comm_id"])
+	if err != nil {
+		return nil, err
+	}
+
+	return gh.svc.RestoreComment(p.Context, *postId, *commId, userId, stringFromArg(p.Args["reason"]))
 }
 
 func (gh *gqlHandler) resolveMutationUpdateComment(p graphql.ResolveParams) (interface{}, error) {
@@ -167,5 +536,148 @@ func (gh *gqlHandler) resolveMutationUpdateComment(p graphql.ResolveParams) (int
 		return nil, err
 	}
 
-	return gh.svc.UpdateComment(p.Context, *postId, *commId, userId, *comm)
+	version, err := versionFromArg(p.Args["expected_version"])
+	if err != nil {
+		return nil, err
+	}
+
+	return withConflict(gh.svc.UpdateComment(p.Context, *postId, *commId, userId, *comm, version))
+}
+
+func (gh *gqlHandler) resolveMutationVoteComment(p graphql.ResolveParams) (interface{}, error) {
+	userId, err := gh.getSessionUser(p)
+	if err != nil {
+		return nil, err
+	}
+
+	postId, err := idFromArg(p.Args["post_id"])
+	if err != nil {
+		return nil, err
+	}
+
+	commId, err := idFromArg(p.Args["comm_id"])
+	if err != nil {
+		return nil, err
+	}
+
+	vote, err := voteFromArg(p.Args["vote"])
+	if err != nil {
+		return nil, err
+	}
+
+	return gh.svc.VoteComment(p.Context, *postId, *commId, userId, vote)
+}
+
+func (gh *gqlHandler) resolveQueryModerationLog(p graphql.ResolveParams) (interface{}, error) {
+	userId, err := gh.getSessionUser(p)
+	if err != nil {
+		return nil, err
+	}
+
+	postId, err := idFromArg(p.Args["post_id"])
+	if err != nil {
+		return nil, err
+	}
+
+	return gh.svc.GetModerationLog(p.Context, *postId, userId)
+}
+
+func (gh *gqlHandler) resolveMutationModeratePost(p graphql.ResolveParams) (interface{}, error) {
+	userId, err := gh.getSessionUser(p)
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := idFromArg(p.Args["post_id"])
+	if err != nil {
+		return nil, err
+	}
+
+	action, err := modActionFromArg(p.Args["action"])
+	if err != nil {
+		return nil, err
+	}
+
+	return gh.svc.ModeratePost(p.Context, *id, userId, action, stringFromArg(p.Args["reason"]))
+}
+
+func (gh *gqlHandler) resolveMutationModerateComment(p graphql.ResolveParams) (interface{}, error) {
+	userId, err := gh.getSessionUser(p)
+	if err != nil {
+		return nil, err
+	}
+
+	postId, err := idFromArg(p.Args["post_id"])
+	if err != nil {
+		return nil, err
+	}
+
+	commId, err := idFromArg(p.Args["comm_id"])
+	if err != nil {
+		return nil, err
+	}
+
+	action, err := modActionFromArg(p.Args["action"])
+	if err != nil {
+		return nil, err
+	}
+
+	return gh.svc.ModerateComment(p.Context, *postId, *commId, userId, action, stringFromArg(p.Args["reason"]))
+}
+
+func (gh *gqlHandler) resolveMutationRevertPost(p graphql.ResolveParams) (interface{}, error) {
+	userId, err := gh.getSessionUser(p)
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := idFromArg(p.Args["post_id"])
+	if err != nil {
+		return nil, err
+	}
+
+	revId, err := idFromArg(p.Args["revision_id"])
+	if err != nil {
+		return nil, err
+	}
+
+	return gh.svc.RevertPost(p.Context, *id, *revId, userId)
+}
+
+func (gh *gqlHandler) resolveMutationRevertComment(p graphql.ResolveParams) (interface{}, error) {
+	userId, err := gh.getSessionUser(p)
+	if err != nil {
+		return nil, err
+	}
+
+	postId, err := idFromArg(p.Args["post_id"])
+	if err != nil {
+		return nil, err
+	}
+
+	commId, err := idFromArg(p.Args["comm_id"])
+	if err != nil {
+		return nil, err
+	}
+
+	revId, err := idFromArg(p.Args["revision_id"])
+	if err != nil {
+		return nil, err
+	}
+
+	return gh.svc.RevertComment(p.Context, *postId, *commId, *revId, userId)
+}
+
+func (gh *gqlHandler) resolveMutationSetRole(p graphql.ResolveParams) (interface{}, error) {
+	actorId, err := gh.getSessionUser(p)
+	if err != nil {
+		return nil, err
+	}
+
+	userId, err := idFromArg(p.Args["user_id"])
+	if err != nil {
+		return nil, err
+	}
+
+	return gh.svc.SetRole(p.Context, actorId, *userId, stringFromArg(p.Args["role"]))
 }
@@ -2,6 +2,7 @@ package gql
 
 import (
 	storage "github.com/cutlery47/posts/internal/storage/post-storage"
+	user "github.com/cutlery47/posts/internal/storage/user-storage"
 	"github.com/graphql-go/graphql"
 )
 
@@ -10,9 +11,6 @@ func (gh *gqlHandler) initSchema() error {
 		graphql.InputObjectConfig{
 			Name: "InCommentInput",
 			Fields: graphql.InputObjectConfigFieldMap{
-				"user_id": &graphql.InputObjectFieldConfig{
-					Type: graphql.NewNonNull(graphql.ID),
-				},
 				"content": &graphql.InputObjectFieldConfig{
 					Type: graphql.NewNonNull(graphql.String),
 				},
@@ -24,12 +22,71 @@ func (gh *gqlHandler) initSchema() error {
 		graphql.ObjectConfig{
 			Name: "InComment",
 			Fields: graphql.Fields{
-				"user_id": &graphql.Field{
+				"content": &graphql.Field{
+					Type: graphql.NewNonNull(graphql.String),
+				},
+			},
+		},
+	)
+
+	var inUserType = graphql.NewObject(
+		graphql.ObjectConfig{
+			Name: "InUser",
+			Fields: graphql.Fields{
+				"name": &graphql.Field{
+					Type: graphql.NewNonNull(graphql.String),
+				},
+				"role": &graphql.Field{
+					Type: graphql.NewNonNull(graphql.String),
+				},
+			},
+		},
+	)
+
+	var userType = graphql.NewObject(
+		graphql.ObjectConfig{
+			Name: "User",
+			Fields: graphql.Fields{
+				"in_user": &graphql.Field{
+					Type: graphql.NewNonNull(inUserType),
+				},
+				"id": &graphql.Field{
 					Type: graphql.NewNonNull(graphql.ID),
 				},
+				"created_at": &graphql.Field{
+					Type: graphql.NewNonNull(graphql.DateTime),
+				},
+			},
+		},
+	)
+
+	var revisionType = graphql.NewObject(
+		graphql.ObjectConfig{
+			Name: "Revision",
+			Fields: graphql.Fields{
+				"id": &graphql.Field{
+					Type: graphql.NewNonNull(graphql.ID),
+				},
+				"post_id": &graphql.Field{
+					Type: graphql.NewNonNull(graphql.ID),
+				},
+				"comment_id": &graphql.Field{
+					Type: graphql.ID,
+				},
 				"content": &graphql.Field{
 					Type: graphql.NewNonNull(graphql.String),
 				},
+				"editor_id": &graphql.Field{
+					Type:        graphql.NewNonNull(graphql.ID),
+					Description: "id of the user, who made the change",
+				},
+				"editor": &graphql.Field{
+					Type:    userType,
+					Resolve: gh.resolveRevisionEditor,
+				},
+				"created_at": &graphql.Field{
+					Type: graphql.NewNonNull(graphql.DateTime),
+				},
 			},
 		},
 	)
@@ -44,12 +101,41 @@ func (gh *gqlHandler) initSchema() error {
 				"id": &graphql.Field{
 					Type: graphql.NewNonNull(graphql.ID),
 				},
+				"user_id": &graphql.Field{
+					Type:        graphql.NewNonNull(graphql.ID),
+					Description: "author id",
+				},
+				"author": &graphql.Field{
+					Type:    userType,
+					Resolve: gh.resolveCommentAuthor,
+				},
+				"post_id": &graphql.Field{
+					Type: graphql.NewNonNull(graphql.ID),
+				},
+				"parent_id": &graphql.Field{
+					Type: graphql.ID,
+				},
+				"depth": &graphql.Field{
+					Type:        graphql.NewNonNull(graphql.Int),
+					Description: "nesting level (1 for top-level comments)",
+				},
+				"reply_count": &graphql.Field{
+					Type:        graphql.NewNonNull(graphql.Int),
+					Description: "number of direct replies",
+				},
+				"is_pinned": &graphql.Field{
+					Type: graphql.NewNonNull(graphql.Boolean),
+				},
 				"upvotes": &graphql.Field{
 					Type: graphql.NewNonNull(graphql.Int),
 				},
 				"downvotes": &graphql.Field{
 					Type: graphql.NewNonNull(graphql.Int),
 				},
+				"version": &graphql.Field{
+					Type:        graphql.NewNonNull(graphql.Int),
+					Description: "incremented on every change, except for votes",
+				},
 				"created_at": &graphql.Field{
 					Type: graphql.NewNonNull(graphql.DateTime),
 				},
@@ -59,6 +145,31 @@ func (gh *gqlHandler) initSchema() error {
 				"deleted_at": &graphql.Field{
 					Type: graphql.DateTime,
 				},
+				"revisions": &graphql.Field{
+					Type: graphql.NewNonNull(&graphql.List{
+						OfType: graphql.NewNonNull(revisionType),
+					}),
+					Description: "edit history, oldest revisions first (empty for deleted comments)",
+					Resolve:     gh.resolveCommentRevisions,
+				},
+			},
+		},
+	)
+
+	var commentSortEnum = graphql.NewEnum(
+		graphql.EnumConfig{
+			Name: "CommentSortEnum",
+			Values: graphql.EnumValueConfigMap{
+				"NEWEST": &graphql.EnumValueConfig{
+					Value: "newest",
+				},
+				"OLDEST": &graphql.EnumValueConfig{
+					Value: "oldest",
+				},
+				"TOP": &graphql.EnumValueConfig{
+					Value:       "top",
+					Description: "highest difference between upvotes and downvotes first",
+				},
 			},
 		},
 	)
@@ -69,20 +180,9 @@ func (gh *gqlHandler) initSchema() error {
 			Type: graphql.NewNonNull(&graphql.List{
 				OfType: commentType,
 			}),
-			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
-				// convert reply map into slice
-				src := p.Source.(storage.Comment)
-
-				var (
-					repls = make([]storage.Comment, 0, len(src.Replies))
-				)
-
-				for _, v := range src.Replies {
-					repls = append(repls, v)
-				}
-
-				return repls, nil
-			},
+			Description: "get a page of direct replies",
+			Args:        listArgs(commentSortEnum, storage.SortNewest),
+			Resolve:     gh.resolveCommentReplies,
 		},
 	)
 
@@ -90,9 +190,6 @@ func (gh *gqlHandler) initSchema() error {
 		graphql.InputObjectConfig{
 			Name: "InPostInput",
 			Fields: graphql.InputObjectConfigFieldMap{
-				"user_id": &graphql.InputObjectFieldConfig{
-					Type: graphql.NewNonNull(graphql.ID),
-				},
 				"content": &graphql.InputObjectFieldConfig{
 					Type: graphql.NewNonNull(graphql.String),
 				},
@@ -107,9 +204,6 @@ func (gh *gqlHandler) initSchema() error {
 		graphql.ObjectConfig{
 			Name: "InPost",
 			Fields: graphql.Fields{
-				"user_id": &graphql.Field{
-					Type: graphql.NewNonNull(graphql.ID),
-				},
 				"content": &graphql.Field{
 					Type: graphql.NewNonNull(graphql.String),
 				},
@@ -130,12 +224,31 @@ func (gh *gqlHandler) initSchema() error {
 				"id": &graphql.Field{
 					Type: graphql.NewNonNull(graphql.ID),
 				},
+				"user_id": &graphql.Field{
+					Type:        graphql.NewNonNull(graphql.ID),
+					Description: "author id",
+				},
+				"author": &graphql.Field{
+					Type:    userType,
+					Resolve: gh.resolvePostAuthor,
+				},
+				"is_locked": &graphql.Field{
+					Type:        graphql.NewNonNull(graphql.Boolean),
+					Description: "locked posts can't be commented on",
+				},
+				"is_pinned": &graphql.Field{
+					Type: graphql.NewNonNull(graphql.Boolean),
+				},
 				"upvotes": &graphql.Field{
 					Type: graphql.NewNonNull(graphql.Int),
 				},
 				"downvotes": &graphql.Field{
 					Type: graphql.NewNonNull(graphql.Int),
 				},
+				"version": &graphql.Field{
+					Type:        graphql.NewNonNull(graphql.Int),
+					Description: "incremented on every change, except for votes",
+				},
 				"created_at": &graphql.Field{
 					Type: graphql.NewNonNull(graphql.DateTime),
 				},
@@ -145,6 +258,13 @@ func (gh *gqlHandler) initSchema() error {
 				"deleted_at": &graphql.Field{
 					Type: graphql.DateTime,
 				},
+				"revisions": &graphql.Field{
+					Type: graphql.NewNonNull(&graphql.List{
+						OfType: graphql.NewNonNull(revisionType),
+					}),
+					Description: "edit history, oldest revisions first (empty for deleted posts)",
+					Resolve:     gh.resolvePostRevisions,
+				},
 			},
 		},
 	)
@@ -155,20 +275,9 @@ func (gh *gqlHandler) initSchema() error {
 			Type: graphql.NewNonNull(&graphql.List{
 				OfType: commentType,
 			}),
-			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
-				// convert comment map into slice
-				src := p.Source.(storage.Post)
-
-				var (
-					comms = make([]storage.Comment, 0, len(src.Comments))
-				)
-
-				for _, v := range src.Comments {
-					comms = append(comms, v)
-				}
-
-				return comms, nil
-			},
+			Description: "get a page of top-level comments",
+			Args:        listArgs(commentSortEnum, storage.SortNewest),
+			Resolve:     gh.resolvePostComments,
 		},
 	)
 
@@ -188,6 +297,175 @@ func (gh *gqlHandler) initSchema() error {
 				"DOWNVOTED": &graphql.EnumValueConfig{
 					Value: "downvoted",
 				},
+				"TOP": &graphql.EnumValueConfig{
+					Value:       "top",
+					Description: "highest difference between upvotes and downvotes first, usually limited with window",
+				},
+				"HOT": &graphql.EnumValueConfig{
+					Value:       "hot",
+					Description: "highest score first, newer items need fewer votes to outrank the older ones",
+				},
+				"CONTROVERSIAL": &graphql.EnumValueConfig{
+					Value:       "controversial",
+					Description: "most votes, split evenly between upvotes and downvotes, first",
+				},
+				"BEST": &graphql.EnumValueConfig{
+					Value:       "best",
+					Description: "highest lower bound of the share of upvotes (wilson score) first",
+				},
+			},
+		},
+	)
+
+	var commentConnectionType = connectionType(commentType)
+
+	commentType.AddFieldConfig(
+		"repliesConnection",
+		&graphql.Field{
+			Type:        graphql.NewNonNull(commentConnectionType),
+			Description: "get sorted + paginated replies",
+			Args:        connectionArgs(sortEnum),
+			Resolve:     gh.resolveCommentRepliesConnection,
+		},
+	)
+
+	postType.AddFieldConfig(
+		"commentsConnection",
+		&graphql.Field{
+			Type:        graphql.NewNonNull(commentConnectionType),
+			Description: "get sorted + paginated top-level comments",
+			Args:        connectionArgs(sortEnum),
+			Resolve:     gh.resolvePostCommentsConnection,
+		},
+	)
+
+	var postConnectionType = connectionType(postType)
+
+	userType.AddFieldConfig(
+		"karma",
+		&graphql.Field{
+			Type:        graphql.NewNonNull(graphql.Int),
+			Description: "sum of vote scores (upvotes - downvotes) of the user's posts and comments",
+			Resolve:     gh.resolveUserKarma,
+		},
+	)
+
+	userType.AddFieldConfig(
+		"posts",
+		&graphql.Field{
+			Type: graphql.NewNonNull(&graphql.List{
+				OfType: postType,
+			}),
+			Description: "get a page of the user's posts",
+			Args:        listArgs(sortEnum, storage.SortNewest),
+			Resolve:     gh.resolveUserPosts,
+		},
+	)
+
+	userType.AddFieldConfig(
+		"postsConnection",
+		&graphql.Field{
+			Type:        graphql.NewNonNull(postConnectionType),
+			Description: "get sorted + paginated posts of the user",
+			Args:        connectionArgs(sortEnum),
+			Resolve:     gh.resolveUserPostsConnection,
+		},
+	)
+
+	userType.AddFieldConfig(
+		"comments",
+		&graphql.Field{
+			Type: graphql.NewNonNull(&graphql.List{
+				OfType: commentType,
+			}),
+			Description: "get a page of the user's comments and replies",
+			Args:        listArgs(sortEnum, storage.SortNewest),
+			Resolve:     gh.resolveUserComments,
+		},
+	)
+
+	userType.AddFieldConfig(
+		"commentsConnection",
+		&graphql.Field{
+			Type:        graphql.NewNonNull(commentConnectionType),
+			Description: "get sorted + paginated comments and replies of the user",
+			Args:        connectionArgs(sortEnum),
+			Resolve:     gh.resolveUserCommentsConnection,
+		},
+	)
+
+	var voteEnum = graphql.NewEnum(
+		graphql.EnumConfig{
+			Name: "VoteEnum",
+			Values: graphql.EnumValueConfigMap{
+				"UP": &graphql.EnumValueConfig{
+					Value: storage.VoteUp,
+				},
+				"DOWN": &graphql.EnumValueConfig{
+					Value: storage.VoteDown,
+				},
+				"NONE": &graphql.EnumValueConfig{
+					Value: storage.VoteNone,
+				},
+			},
+		},
+	)
+
+	var searchKindEnum = graphql.NewEnum(
+		graphql.EnumConfig{
+			Name: "SearchKindEnum",
+			Values: graphql.EnumValueConfigMap{
+				"ALL": &graphql.EnumValueConfig{
+					Value: storage.SearchAll,
+				},
+				"POST": &graphql.EnumValueConfig{
+					Value: storage.SearchPosts,
+				},
+				"COMMENT": &graphql.EnumValueConfig{
+					Value: storage.SearchComments,
+				},
+			},
+		},
+	)
+
+	var searchHitType = graphql.NewObject(
+		graphql.ObjectConfig{
+			Name: "SearchHit",
+			Fields: graphql.Fields{
+				"kind": &graphql.Field{
+					Type: graphql.NewNonNull(searchKindEnum),
+				},
+				"id": &graphql.Field{
+					Type: graphql.NewNonNull(graphql.ID),
+				},
+				"post_id": &graphql.Field{
+					Type: graphql.NewNonNull(graphql.ID),
+				},
+				"rank": &graphql.Field{
+					Type: graphql.NewNonNull(graphql.Float),
+				},
+				"snippet": &graphql.Field{
+					Type:        graphql.NewNonNull(graphql.String),
+					Description: "html-escaped matched fragment, where matched terms are wrapped into <b></b>",
+				},
+			},
+		},
+	)
+
+	var trashType = graphql.NewObject(
+		graphql.ObjectConfig{
+			Name: "Trash",
+			Fields: graphql.Fields{
+				"posts": &graphql.Field{
+					Type: graphql.NewNonNull(&graphql.List{
+						OfType: graphql.NewNonNull(postType),
+					}),
+				},
+				"comments": &graphql.Field{
+					Type: graphql.NewNonNull(&graphql.List{
+						OfType: graphql.NewNonNull(commentType),
+					}),
+				},
 			},
 		},
 	)
@@ -206,6 +484,19 @@ func (gh *gqlHandler) initSchema() error {
 					},
 					Resolve: gh.resolveQueryPost,
 				},
+				"user": &graphql.Field{
+					Type:        userType,
+					Description: "get user by either its id or name",
+					Args: graphql.FieldConfigArgument{
+						"id": &graphql.ArgumentConfig{
+							Type: graphql.ID,
+						},
+						"name": &graphql.ArgumentConfig{
+							Type: graphql.String,
+						},
+					},
+					Resolve: gh.resolveQueryUser,
+				},
 				"posts": &graphql.Field{
 					Type: graphql.NewNonNull(
 						&graphql.List{
@@ -215,7 +506,8 @@ func (gh *gqlHandler) initSchema() error {
 					Description: "get sorted + paginated posts",
 					Args: graphql.FieldConfigArgument{
 						"limit": &graphql.ArgumentConfig{
-							Type: graphql.Int,
+							Type:        graphql.Int,
+							Description: "defaults to the page size, capped by the max page size",
 						},
 						"offset": &graphql.ArgumentConfig{
 							Type: graphql.Int,
@@ -226,12 +518,153 @@ func (gh *gqlHandler) initSchema() error {
 					},
 					Resolve: gh.resolveQueryPosts,
 				},
+				"postsConnection": &graphql.Field{
+					Type:        graphql.NewNonNull(postConnectionType),
+					Description: "get sorted + cursor-paginated posts",
+					Args:        connectionArgs(sortEnum),
+					Resolve:     gh.resolveQueryPostsConnection,
+				},
+				"search": &graphql.Field{
+					Type:        graphql.NewNonNull(connectionType(searchHitType)),
+					Description: "full-text search over posts and comments, ordered by relevance",
+					Args: graphql.FieldConfigArgument{
+						"query": &graphql.ArgumentConfig{
+							Type: graphql.NewNonNull(graphql.String),
+						},
+						"kind": &graphql.ArgumentConfig{
+							Type: searchKindEnum,
+						},
+						"limit": &graphql.ArgumentConfig{
+							Type: graphql.Int,
+						},
+						"after": &graphql.ArgumentConfig{
+							Type: graphql.String,
+						},
+					},
+					Resolve: gh.resolveQuerySearch,
+				},
+				"trash": &graphql.Field{
+					Type:        graphql.NewNonNull(trashType),
+					Description: "get deleted posts and comments of the session user (or of any user for moderators)",
+					Args: graphql.FieldConfigArgument{
+						"user_id": &graphql.ArgumentConfig{
+							Type:        graphql.ID,
+							Description: "owner of the trash, defaults to the session user",
+						},
+					},
+					Resolve: gh.resolveQueryTrash,
+				},
+			},
+		},
+	)
+
+	var roleEnum = graphql.NewEnum(
+		graphql.EnumConfig{
+			Name: "RoleEnum",
+			Values: graphql.EnumValueConfigMap{
+				"USER": &graphql.EnumValueConfig{
+					Value: user.UserRole,
+				},
+				"MODERATOR": &graphql.EnumValueConfig{
+					Value: user.ModeratorRole,
+				},
+				"ADMIN": &graphql.EnumValueConfig{
+					Value: user.AdminRole,
+				},
 			},
 		},
 	)
 
+	var modActionEnum = graphql.NewEnum(
+		graphql.EnumConfig{
+			Name: "ModActionEnum",
+			Values: graphql.EnumValueConfigMap{
+				"DELETE": &graphql.EnumValueConfig{
+					Value: storage.ModDelete,
+				},
+				"RESTORE": &graphql.EnumValueConfig{
+					Value: storage.ModRestore,
+				},
+				"LOCK": &graphql.EnumValueConfig{
+					Value:       storage.ModLock,
+					Description: "posts only",
+				},
+				"UNLOCK": &graphql.EnumValueConfig{
+					Value:       storage.ModUnlock,
+					Description: "posts only",
+				},
+				"PIN": &graphql.EnumValueConfig{
+					Value: storage.ModPin,
+				},
+				"UNPIN": &graphql.EnumValueConfig{
+					Value: storage.ModUnpin,
+				},
+			},
+		},
+	)
+
+	var modEntryType = graphql.NewObject(
+		graphql.ObjectConfig{
+			Name: "ModerationEntry",
+			Fields: graphql.Fields{
+				"id": &graphql.Field{
+					Type: graphql.NewNonNull(graphql.ID),
+				},
+				"post_id": &graphql.Field{
+					Type: graphql.NewNonNull(graphql.ID),
+				},
+				"comment_id": &graphql.Field{
+					Type: graphql.ID,
+				},
+				"actor_id": &graphql.Field{
+					Type:        graphql.NewNonNull(graphql.ID),
+					Description: "moderator id",
+				},
+				"action": &graphql.Field{
+					Type: graphql.NewNonNull(modActionEnum),
+				},
+				"reason": &graphql.Field{
+					Type: graphql.NewNonNull(graphql.String),
+				},
+				"created_at": &graphql.Field{
+					Type: graphql.NewNonNull(graphql.DateTime),
+				},
+			},
+		},
+	)
+
+	rootQuery.AddFieldConfig(
+		"moderationLog",
+		&graphql.Field{
+			Type: graphql.NewNonNull(&graphql.List{
+				OfType: graphql.NewNonNull(modEntryType),
+			}),
+			Description: "get moderation audit log of a post (moderators only)",
+			Args: graphql.FieldConfigArgument{
+				"post_id": &graphql.ArgumentConfig{
+					Type: graphql.NewNonNull(graphql.ID),
+				},
+			},
+			Resolve: gh.resolveQueryModerationLog,
+		},
+	)
+
+	// required when content is deleted or restored by a moderator
+	var modReason = &graphql.ArgumentConfig{
+		Type:        graphql.String,
+		Description: "reason of the action, required for moderators",
+	}
+
+	// changes are rejected with VERSION_CONFLICT, if the item has been changed since the provided version
+	var expectedVersion = &graphql.ArgumentConfig{
+		Type:        graphql.Int,
+		Description: "version, which the change is based on",
+	}
+
+	// session is expected in the authorization header or cookie
 	var seshToken = &graphql.ArgumentConfig{
-		Type: graphql.NewNonNull(graphql.ID),
+		Type:        graphql.ID,
+		Description: "DEPRECATED: pass the session in \"Authorization: Bearer <session>\" header or cookie instead",
 	}
 
 	var rootMutation = graphql.NewObject(
@@ -254,10 +687,24 @@ func (gh *gqlHandler) initSchema() error {
 						"id": &graphql.ArgumentConfig{
 							Type: graphql.NewNonNull(graphql.ID),
 						},
-						"sesh_id": seshToken,
+						"reason":           modReason,
+						"expected_version": expectedVersion,
+						"sesh_id":          seshToken,
 					},
 					Resolve: gh.resolveMutationDeletePost,
 				},
+				"restorePost": &graphql.Field{
+					Type:        postType,
+					Description: "restore deleted post",
+					Args: graphql.FieldConfigArgument{
+						"id": &graphql.ArgumentConfig{
+							Type: graphql.NewNonNull(graphql.ID),
+						},
+						"reason":  modReason,
+						"sesh_id": seshToken,
+					},
+					Resolve: gh.resolveMutationRestorePost,
+				},
 				"updatePost": &graphql.Field{
 					Type: postType,
 					Args: graphql.FieldConfigArgument{
@@ -267,10 +714,24 @@ func (gh *gqlHandler) initSchema() error {
 						"in_post": &graphql.ArgumentConfig{
 							Type: graphql.NewNonNull(inPostInput),
 						},
-						"sesh_id": seshToken,
+						"expected_version": expectedVersion,
+						"sesh_id":          seshToken,
 					},
 					Resolve: gh.resolveMutationUpdatePost,
 				},
+				"votePost": &graphql.Field{
+					Type: postType,
+					Args: graphql.FieldConfigArgument{
+						"post_id": &graphql.ArgumentConfig{
+							Type: graphql.NewNonNull(graphql.ID),
+						},
+						"vote": &graphql.ArgumentConfig{
+							Type: graphql.NewNonNull(voteEnum),
+						},
+						"sesh_id": seshToken,
+					},
+					Resolve: gh.resolveMutationVotePost,
+				},
 				"insertComment": &graphql.Field{
 					Type: graphql.NewNonNull(commentType),
 					Args: graphql.FieldConfigArgument{
@@ -296,10 +757,27 @@ func (gh *gqlHandler) initSchema() error {
 						"comm_id": &graphql.ArgumentConfig{
 							Type: graphql.NewNonNull(graphql.ID),
 						},
-						"sesh_id": seshToken,
+						"reason":           modReason,
+						"expected_version": expectedVersion,
+						"sesh_id":          seshToken,
 					},
 					Resolve: gh.resolveMutationDeleteComment,
 				},
+				"restoreComment": &graphql.Field{
+					Type:        commentType,
+					Description: "restore deleted comment",
+					Args: graphql.FieldConfigArgument{
+						"post_id": &graphql.ArgumentConfig{
+							Type: graphql.NewNonNull(graphql.ID),
+						},
+						"comm_id": &graphql.ArgumentConfig{
+							Type: graphql.NewNonNull(graphql.ID),
+						},
+						"reason":  modReason,
+						"sesh_id": seshToken,
+					},
+					Resolve: gh.resolveMutationRestoreComment,
+				},
 				"updateComment": &graphql.Field{
 					Type: commentType,
 					Args: graphql.FieldConfigArgument{
@@ -312,17 +790,159 @@ func (gh *gqlHandler) initSchema() error {
 						"in_comm": &graphql.ArgumentConfig{
 							Type: graphql.NewNonNull(inCommentInput),
 						},
-						"sesh_id": seshToken,
+						"expected_version": expectedVersion,
+						"sesh_id":          seshToken,
 					},
 					Resolve: gh.resolveMutationUpdateComment,
 				},
+				"voteComment": &graphql.Field{
+					Type: commentType,
+					Args: graphql.FieldConfigArgument{
+						"post_id": &graphql.ArgumentConfig{
+							Type: graphql.NewNonNull(graphql.ID),
+						},
+						"comm_id": &graphql.ArgumentConfig{
+							Type: graphql.NewNonNull(graphql.ID),
+						},
+						"vote": &graphql.ArgumentConfig{
+							Type: graphql.NewNonNull(voteEnum),
+						},
+						"sesh_id": seshToken,
+					},
+					Resolve: gh.resolveMutationVoteComment,
+				},
+				"moderatePost": &graphql.Field{
+					Type:        postType,
+					Description: "delete, restore, lock or pin a post (moderators only)",
+					Args: graphql.FieldConfigArgument{
+						"post_id": &graphql.ArgumentConfig{
+							Type: graphql.NewNonNull(graphql.ID),
+						},
+						"action": &graphql.ArgumentConfig{
+							Type: graphql.NewNonNull(modActionEnum),
+						},
+						"reason": &graphql.ArgumentConfig{
+							Type: graphql.NewNonNull(graphql.String),
+						},
+					},
+					Resolve: gh.resolveMutationModeratePost,
+				},
+				"moderateComment": &graphql.Field{
+					Type:        commentType,
+					Description: "delete, restore or pin a comment (moderators only)",
+					Args: graphql.FieldConfigArgument{
+						"post_id": &graphql.ArgumentConfig{
+							Type: graphql.NewNonNull(graphql.ID),
+						},
+						"comm_id": &graphql.ArgumentConfig{
+							Type: graphql.NewNonNull(graphql.ID),
+						},
+						"action": &graphql.ArgumentConfig{
+							Type: graphql.NewNonNull(modActionEnum),
+						},
+						"reason": &graphql.ArgumentConfig{
+							Type: graphql.NewNonNull(graphql.String),
+						},
+					},
+					Resolve: gh.resolveMutationModerateComment,
+				},
+				"revertPost": &graphql.Field{
+					Type:        postType,
+					Description: "restore content of a post from its revision (author or admins only)",
+					Args: graphql.FieldConfigArgument{
+						"post_id": &graphql.ArgumentConfig{
+							Type: graphql.NewNonNull(graphql.ID),
+						},
+						"revision_id": &graphql.ArgumentConfig{
+							Type: graphql.NewNonNull(graphql.ID),
+						},
+					},
+					Resolve: gh.resolveMutationRevertPost,
+				},
+				"revertComment": &graphql.Field{
+					Type:        commentType,
+					Description: "restore content of a comment from its revision (author or admins only)",
+					Args: graphql.FieldConfigArgument{
+						"post_id": &graphql.ArgumentConfig{
+							Type: graphql.NewNonNull(graphql.ID),
+						},
+						"comm_id": &graphql.ArgumentConfig{
+							Type: graphql.NewNonNull(graphql.ID),
+						},
+						"revision_id": &graphql.ArgumentConfig{
+							Type: graphql.NewNonNull(graphql.ID),
+						},
+					},
+					Resolve: gh.resolveMutationRevertComment,
+				},
+				"setRole": &graphql.Field{
+					Type:        userType,
+					Description: "grant a role to a user (admins only)",
+					Args: graphql.FieldConfigArgument{
+						"user_id": &graphql.ArgumentConfig{
+							Type: graphql.NewNonNull(graphql.ID),
+						},
+						"role": &graphql.ArgumentConfig{
+							Type: graphql.NewNonNull(roleEnum),
+						},
+					},
+					Resolve: gh.resolveMutationSetRole,
+				},
+			},
+		},
+	)
+
+	var rootSubscription = graphql.NewObject(
+		graphql.ObjectConfig{
+			Name: "Subscription",
+			Fields: graphql.Fields{
+				"commentAdded": &graphql.Field{
+					Type:        graphql.NewNonNull(commentType),
+					Description: "receive comments and replies, added to a post",
+					Args: graphql.FieldConfigArgument{
+						"post_id": &graphql.ArgumentConfig{
+							Type: graphql.NewNonNull(graphql.ID),
+						},
+					},
+					Subscribe: gh.subscribeCommentAdded,
+					Resolve:   resolveEvent,
+				},
+				"commentUpdated": &graphql.Field{
+					Type:        graphql.NewNonNull(commentType),
+					Description: "receive updates of comments and replies of a post, including their moderation",
+					Args: graphql.FieldConfigArgument{
+						"post_id": &graphql.ArgumentConfig{
+							Type: graphql.NewNonNull(graphql.ID),
+						},
+					},
+					Subscribe: gh.subscribeCommentUpdated,
+					Resolve:   resolveEvent,
+				},
+				"postUpdated": &graphql.Field{
+					Type:        graphql.NewNonNull(postType),
+					Description: "receive updates of a post",
+					Args: graphql.FieldConfigArgument{
+						"post_id": &graphql.ArgumentConfig{
+							Type: graphql.NewNonNull(graphql.ID),
+						},
+					},
+					Subscribe: gh.subscribePostUpdated,
+					Resolve:   resolveEvent,
+				},
+				"postCreated": &graphql.Field{
+					Type:        graphql.NewNonNull(postType),
+					Description: "receive newly created posts",
+					Subscribe:   gh.subscribePostCreated,
+					Resolve:     resolveEvent,
+				},
 			},
 		},
 	)
 
 	var schemaConfig = graphql.SchemaConfig{
-		Query:    rootQuery,
-		Mutation: rootMutation,
+		Query:        rootQuery,
+		Mutation:     rootMutation,
+		Subscription: rootSubscription,
 	}
 
 	schema, err := graphql.NewSchema(schemaConfig)
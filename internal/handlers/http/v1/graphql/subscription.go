@@ -0,0 +1,99 @@
+package gql
+
+import (
+	"github.com/cutlery47/posts/internal/events"
+	"github.com/graphql-go/graphql"
+)
+
+// forwards events, which satisfy the filter, into a channel expected by graphql.Subscribe
+// the channel is closed once the subscription context is done or the subscriber falls behind
+func (gh *gqlHandler) subscribe(p graphql.ResolveParams, filter func(e events.Event) bool, payload func(e events.Event) any) (interface{}, error) {
+	var (
+		sub = gh.svc.Subscribe(filter)
+		c   = make(chan interface{})
+	)
+
+	go func() {
+		defer close(c)
+		defer sub.Close()
+
+		for {
+			select {
+			case <-p.Context.Done():
+				return
+			case e, ok := <-sub.C:
+				if !ok {
+					return
+				}
+
+				select {
+				case c <- payload(e):
+				case <-p.Context.Done():
+					return
+				}
+			}
+		}
+	}()
+
+	return c, nil
+}
+
+func (gh *gqlHandler) subscribeCommentAdded(p graphql.ResolveParams) (interface{}, error) {
+	postId, err := idFromArg(p.Args["post_id"])
+	if err != nil {
+		return nil, err
+	}
+
+	filter := func(e events.Event) bool {
+		return e.Kind == events.CommentAdded && e.PostId == *postId
+	}
+
+	return gh.subscribe(p, filter, func(e events.Event) any {
+		return *e.Comment
+	})
+}
+
+func (gh *gqlHandler) subscribeCommentUpdated(p graphql.ResolveParams) (interface{}, error) {
+	postId, err := idFromArg(p.Args["post_id"])
+	if err != nil {
+		return nil, err
+	}
+
+	filter := func(e events.Event) bool {
+		return e.Kind == events.CommentUpdated && e.PostId == *postId
+	}
+
+	return gh.subscribe(p, filter, func(e events.Event) any {
+		return *e.Comment
+	})
+}
+
+func (gh *gqlHandler) subscribePostUpdated(p graphql.ResolveParams) (interface{}, error) {
+	postId, err := idFromArg(p.Args["post_id"])
+	if err != nil {
+		return nil, err
+	}
+
+	filter := func(e events.Event) bool {
+		return e.Kind == events.PostUpdated && e.PostId == *postId
+	}
+
+	return gh.subscribe(p, filter, func(e events.Event) any {
+		return *e.Post
+	})
+}
+
+func (gh *gqlHandler) subscribePostCreated(p graphql.ResolveParams) (interface{}, error) {
+	filter := func(e events.Event) bool {
+		return e.Kind == events.PostCreated
+	}
+
+	return gh.subscribe(p, filter, func(e events.Event) any {
+		return *e.Post
+	})
+}
+
+// subscription payload is passed as the root value
+func resolveEvent(p graphql.ResolveParams) (interface{}, error) {
+	return p.Source, nil
+}
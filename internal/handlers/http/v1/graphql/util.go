@@ -5,13 +5,46 @@ import (
 	"errors"
 
 	storage "github.com/cutlery47/posts/internal/storage/post-storage"
+	user "github.com/cutlery47/posts/internal/storage/user-storage"
 	"github.com/google/uuid"
 )
 
 var (
-	ErrBadArgType = errors.New("bad argument type")
+	ErrBadArgType    = errors.New("bad argument type")
+	ErrBadSourceType = errors.New("bad source type")
+	ErrBadUserArgs   = errors.New("either id or name of the user should be provided")
+	ErrBadVersion    = errors.New("version should be non-negative")
 )
 
+// version conflict, which is reported alongside its code and the current version of the item
+type conflictError struct {
+	*storage.ConflictError
+}
+
+func (e conflictError) Extensions() map[string]interface{} {
+	return map[string]interface{}{
+		"code":    "VERSION_CONFLICT",
+		"version": e.Actual,
+	}
+}
+
+// exposes details of a version conflict to the client
+func withConflict[T any](v T, err error) (interface{}, error) {
+	var (
+		conflict *storage.ConflictError
+	)
+
+	if errors.As(err, &conflict) {
+		return nil, conflictError{conflict}
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return v, nil
+}
+
 func idFromArg(arg any) (*uuid.UUID, error) {
 	idStr, ok := arg.(string)
 	if !ok {
@@ -26,6 +59,95 @@ func idFromArg(arg any) (*uuid.UUID, error) {
 	return &v, err
 }
 
+// resolvers may receive either a value or a pointer as their source
+func postFromSource(src any) (storage.Post, error) {
+	switch v := src.(type) {
+	case storage.Post:
+		return v, nil
+	case *storage.Post:
+		return *v, nil
+	}
+
+	return storage.Post{}, ErrBadSourceType
+}
+
+func commentFromSource(src any) (storage.Comment, error) {
+	switch v := src.(type) {
+	case storage.Comment:
+		return v, nil
+	case *storage.Comment:
+		return *v, nil
+	}
+
+	return storage.Comment{}, ErrBadSourceType
+}
+
+func userFromSource(src any) (user.User, error) {
+	switch v := src.(type) {
+	case user.User:
+		return v, nil
+	case *user.User:
+		return *v, nil
+	}
+
+	return user.User{}, ErrBadSourceType
+}
+
+func revisionFromSource(src any) (storage.Revision, error) {
+	switch v := src.(type) {
+	case storage.Revision:
+		return v, nil
+	case *storage.Revision:
+		return *v, nil
+	}
+
+	return storage.Revision{}, ErrBadSourceType
+}
+
+// expected version of an item is optional
+func versionFromArg(arg any) (*uint64, error) {
+	if arg == nil {
+		return nil, nil
+	}
+
+	v, ok := arg.(int)
+	if !ok {
+		return nil, ErrBadArgType
+	}
+
+	if v < 0 {
+		return nil, ErrBadVersion
+	}
+
+	version := uint64(v)
+
+	return &version, nil
+}
+
+func voteFromArg(arg any) (storage.Vote, error) {
+	vote, ok := arg.(storage.Vote)
+	if !ok {
+		return storage.VoteNone, ErrBadArgType
+	}
+
+	return vote, nil
+}
+
+func modActionFromArg(arg any) (storage.ModAction, error) {
+	action, ok := arg.(storage.ModAction)
+	if !ok {
+		return "", ErrBadArgType
+	}
+
+	return action, nil
+}
+
+// returns empty string for omitted optional arguments
+func stringFromArg(arg any) string {
+	s, _ := arg.(string)
+	return s
+}
+
 func inCommentFromArg(arg any) (*storage.InComment, error) {
 	argJson, err := json.Marshal(arg)
 	if err != nil {
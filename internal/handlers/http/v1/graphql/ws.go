@@ -0,0 +1,320 @@
+package gql
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/language/ast"
+)
+
+// graphql-ws protocol: https://github.com/enisdenjo/graphql-ws/blob/master/PROTOCOL.md
+const (
+	wsProtocol = "graphql-transport-ws"
+
+	// time given to a client to send connection_init
+	wsInitTimeout = 10 * time.Second
+	// time given to a close frame to be written
+	wsCloseTimeout = time.Second
+)
+
+// message types
+const (
+	wsConnectionInit = "connection_init"
+	wsConnectionAck  = "connection_ack"
+	wsPing           = "ping"
+	wsPong           = "pong"
+	wsSubscribe      = "subscribe"
+	wsNext           = "next"
+	wsError          = "error"
+	wsComplete       = "complete"
+)
+
+// close codes
+const (
+	wsBadRequest          = 4400
+	wsUnauthorized        = 4401
+	wsNotAcceptable       = 4406
+	wsInitTimedOut        = 4408
+	wsSubscriberExists    = 4409
+	wsTooManyInitRequests = 4429
+)
+
+type wsMessage struct {
+	Id      string          `json:"id,omitempty"`
+	Type    string          `json:"type"`
+	Payload json.RawMessage `json:"payload,omitempty"`
+}
+
+type wsHandler struct {
+	gh       *gqlHandler
+	upgrader websocket.Upgrader
+}
+
+// returns handler, which serves subscriptions over graphql-ws protocol
+func (gh *gqlHandler) Subscriptions() http.Handler {
+	return &wsHandler{
+		gh: gh,
+		upgrader: websocket.Upgrader{
+			Subprotocols: []string{wsProtocol},
+			CheckOrigin:  sameOrigin,
+		},
+	}
+}
+
+// cors doesn't apply to websocket upgrades, so pages of other sites could otherwise subscribe
+// on behalf of the user, whose session cookie the browser attaches
+// requests without an origin don't come from browsers and are let through
+func sameOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+
+	return strings.EqualFold(u.Host, r.Host)
+}
+
+func (wh *wsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	conn, err := wh.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// upgrader has already replied with an error
+		return
+	}
+
+	wc := &wsConn{
+		conn: conn,
+		gh:   wh.gh,
+		subs: make(map[string]context.CancelFunc),
+	}
+
+	if conn.Subprotocol() != wsProtocol {
+		wc.close(wsNotAcceptable, "Subprotocol not acceptable")
+		return
+	}
+
+	wc.serve(r.Context())
+}
+
+// single graphql-ws connection
+type wsConn struct {
+	conn *websocket.Conn
+	// operations are prepared the same way as over http
+	gh *gqlHandler
+
+	// guards writes to the connection
+	wmu sync.Mutex
+
+	// guards subs and acked
+	mu    sync.Mutex
+	subs  map[string]context.CancelFunc
+	acked bool
+	// tracks running subscriptions
+	wg sync.WaitGroup
+}
+
+func (wc *wsConn) serve(ctx context.Context) {
+	ctx, cancel := context.WithCancel(ctx)
+
+	defer func() {
+		cancel()
+		wc.wg.Wait()
+		wc.conn.Close()
+	}()
+
+	timer := time.AfterFunc(wsInitTimeout, func() {
+		wc.mu.Lock()
+		defer wc.mu.Unlock()
+
+		if !wc.acked {
+			wc.close(wsInitTimedOut, "Connection initialisation timeout")
+		}
+	})
+	defer timer.Stop()
+
+	for {
+		_, data, err := wc.conn.ReadMessage()
+		if err != nil {
+			return
+		}
+
+		var msg wsMessage
+		if err := json.Unmarshal(data, &msg); err != nil {
+			wc.close(wsBadRequest, "Invalid message received")
+			return
+		}
+
+		if !wc.handle(ctx, msg) {
+			return
+		}
+	}
+}
+
+// handles a single client message
+// returns false if the connection should be closed
+func (wc *wsConn) handle(ctx context.Context, msg wsMessage) bool {
+	wc.mu.Lock()
+	defer wc.mu.Unlock()
+
+	switch msg.Type {
+	case wsConnectionInit:
+		if wc.acked {
+			wc.close(wsTooManyInitRequests, "Too many initialisation requests")
+			return false
+		}
+		wc.acked = true
+		wc.send(wsMessage{Type: wsConnectionAck})
+
+	case wsPing:
+		wc.send(wsMessage{Type: wsPong})
+
+	case wsPong:
+
+	case wsSubscribe:
+		if !wc.acked {
+			wc.close(wsUnauthorized, "Unauthorized")
+			return false
+		}
+
+		var payload request
+		if msg.Id == "" || json.Unmarshal(msg.Payload, &payload) != nil {
+			wc.close(wsBadRequest, "Invalid message received")
+			return false
+		}
+
+		if _, ok := wc.subs[msg.Id]; ok {
+			wc.close(wsSubscriberExists, fmt.Sprintf("Subscriber for %v already exists", msg.Id))
+			return false
+		}
+
+		subCtx, cancel := context.WithCancel(ctx)
+		wc.subs[msg.Id] = cancel
+
+		wc.wg.Add(1)
+		go wc.run(subCtx, msg.Id, payload)
+
+	case wsComplete:
+		if cancel, ok := wc.subs[msg.Id]; ok {
+			cancel()
+			delete(wc.subs, msg.Id)
+		}
+
+	default:
+		wc.close(wsBadRequest, "Invalid message received")
+		return false
+	}
+
+	return true
+}
+
+// executes a subscription and streams its results to the client
+func (wc *wsConn) run(ctx context.Context, id string, payload request) {
+	defer wc.wg.Done()
+
+	var (
+		results chan *graphql.Result
+	)
+
+	doc, errs := wc.gh.prepare(&payload)
+
+	switch {
+	case errs != nil:
+		// operation is rejected before it's executed
+		results = make(chan *graphql.Result, 1)
+		results <- &graphql.Result{Errors: errs}
+		close(results)
+	case isSubscription(doc, payload.OperationName):
+		results = graphql.ExecuteSubscription(wc.params(ctx, doc, payload))
+	default:
+		// queries and mutations produce a single result
+		results = make(chan *graphql.Result, 1)
+		results <- graphql.Execute(wc.params(withLoader(ctx, wc.gh.svc), doc, payload))
+		close(results)
+	}
+
+	failed := false
+
+	// the channel has to be drained till the end, since graphql-go blocks on sending results
+	for res := range results {
+		if ctx.Err() != nil || failed {
+			continue
+		}
+
+		// operation couldn't be executed at all
+		if res.Data == nil && res.HasErrors() {
+			errs, _ := json.Marshal(res.Errors)
+			wc.send(wsMessage{Id: id, Type: wsError, Payload: errs})
+			failed = true
+			continue
+		}
+
+		data, err := json.Marshal(res)
+		if err != nil {
+			log.Println("[WS] couldn't marshal result:", err)
+			continue
+		}
+		wc.send(wsMessage{Id: id, Type: wsNext, Payload: data})
+	}
+
+	wc.mu.Lock()
+	defer wc.mu.Unlock()
+
+	// subscription wasn't completed by the client
+	if cancel, ok := wc.subs[id]; ok && ctx.Err() == nil {
+		cancel()
+		delete(wc.subs, id)
+
+		if !failed {
+			wc.send(wsMessage{Id: id, Type: wsComplete})
+		}
+	}
+}
+
+func (wc *wsConn) params(ctx context.Context, doc *ast.Document, payload request) graphql.ExecuteParams {
+	return graphql.ExecuteParams{
+		Context:       ctx,
+		Schema:        wc.gh.schema,
+		AST:           doc,
+		OperationName: payload.OperationName,
+		Args:          payload.Variables,
+	}
+}
+
+// reports whether requested operation is a subscription
+func isSubscription(doc *ast.Document, operationName string) bool {
+	op := operation(doc, operationName)
+
+	return op != nil && op.Operation == ast.OperationTypeSubscription
+}
+
+func (wc *wsConn) send(msg wsMessage) {
+	wc.wmu.Lock()
+	defer wc.wmu.Unlock()
+
+	wc.conn.WriteJSON(msg)
+}
+
+// closes connection with provided code
+func (wc *wsConn) close(code int, reason string) {
+	wc.wmu.Lock()
+	defer wc.wmu.Unlock()
+
+	wc.conn.WriteControl(
+		websocket.CloseMessage,
+		websocket.FormatCloseMessage(code, reason),
+		time.Now().Add(wsCloseTimeout),
+	)
+	wc.conn.Close()
+}
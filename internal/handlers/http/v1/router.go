@@ -1,11 +1,13 @@
 package v1
 
 import (
+	"log"
 	"net/http"
 
 	"github.com/cutlery47/posts/config"
 	"github.com/cutlery47/posts/internal/handlers/http/v1/auth"
 	gql "github.com/cutlery47/posts/internal/handlers/http/v1/graphql"
+	"github.com/cutlery47/posts/internal/metrics"
 	"github.com/cutlery47/posts/internal/service"
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
@@ -17,7 +19,7 @@ func New(conf config.Handler, svc *service.Service) (*chi.Mux, error) {
 		mux = chi.NewMux()
 	)
 
-	gql, err := gql.New(svc)
+	gql, err := gql.New(conf, svc)
 	if err != nil {
 		return nil, err
 	}
@@ -31,10 +33,15 @@ func New(conf config.Handler, svc *service.Service) (*chi.Mux, error) {
 		MaxAge:           300,
 	}))
 
+	// runtime stats and the command line aren't meant for the public
+	mux.With(auth.Middleware(svc), auth.StaffOnly(svc)).Handle("/debug/vars", metrics.Handler())
+
 	mux.Route("/api/v1", func(r chi.Router) {
 		r.Use(middleware.Logger)
+		r.Use(auth.Middleware(svc))
 
 		r.Group(func(r chi.Router) {
+			r.Handle("/graphql/ws", gql.Subscriptions())
 			r.Mount("/graphql", gql)
 		})
 
@@ -43,6 +50,15 @@ func New(conf config.Handler, svc *service.Service) (*chi.Mux, error) {
 			r.Get("/ping", func(w http.ResponseWriter, r *http.Request) {
 				w.WriteHeader(http.StatusOK)
 			})
+			r.Get("/ready", func(w http.ResponseWriter, r *http.Request) {
+				// details might reveal paths of the dump, so they're only logged
+				if err := svc.Ready(r.Context()); err != nil {
+					log.Println("[READY] service isn't ready:", err)
+					http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+					return
+				}
+				w.WriteHeader(http.StatusOK)
+			})
 		})
 	})
 
@@ -0,0 +1,69 @@
+// runtime metrics of the service, exposed in expvar format
+package metrics
+
+import (
+	"expvar"
+	"net/http"
+	"time"
+)
+
+// purger of soft-deleted content
+var (
+	PurgeRuns      = expvar.NewInt("purge_runs")
+	PurgeErrors    = expvar.NewInt("purge_errors")
+	PurgedPosts    = expvar.NewInt("purged_posts")
+	PurgedComments = expvar.NewInt("purged_comments")
+	// total size of purged content
+	PurgedBytes = expvar.NewInt("purged_bytes")
+	// unix time of the last successful purge
+	PurgeLastRun = expvar.NewInt("purge_last_run")
+)
+
+// records results of a single purge
+func ObservePurge(posts, comments int, bytes int64, err error) {
+	PurgeRuns.Add(1)
+
+	if err != nil {
+		PurgeErrors.Add(1)
+		return
+	}
+
+	PurgedPosts.Add(int64(posts))
+	PurgedComments.Add(int64(comments))
+	PurgedBytes.Add(bytes)
+	PurgeLastRun.Set(time.Now().Unix())
+}
+
+// snapshots of the in-memory storage
+var (
+	DumpRuns   = expvar.NewInt("dump_runs")
+	DumpErrors = expvar.NewInt("dump_errors")
+	// failures since the last successful dump
+	DumpFailures = expvar.NewInt("dump_consecutive_failures")
+	// unix time of the last successful dump
+	DumpLastRun = expvar.NewInt("dump_last_run")
+)
+
+// records results of a single dump
+func ObserveDump(failures int, err error) {
+	DumpRuns.Add(1)
+	DumpFailures.Set(int64(failures))
+
+	if err != nil {
+		DumpErrors.Add(1)
+		return
+	}
+
+	DumpLastRun.Set(time.Now().Unix())
+}
+
+// persisted queries of the graphql endpoint
+var (
+	PersistedQueryHits   = expvar.NewInt("persisted_query_hits")
+	PersistedQueryMisses = expvar.NewInt("persisted_query_misses")
+)
+
+// serves all of the published metrics as json
+func Handler() http.Handler {
+	return expvar.Handler()
+}
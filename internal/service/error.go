@@ -4,6 +4,15 @@ import "errors"
 
 var (
 	ErrNotImplemented = errors.New("not implemented")
-	ErrWrongUserId    = errors.New("you pretending to be another user")
 	ErrAccessDenied   = errors.New("access denied")
+	ErrBadSortKey     = errors.New("undefined sort key")
+	ErrBadWindow      = errors.New("undefined time window")
+	ErrBadPageSize    = errors.New("page size should be positive")
+	ErrBadOffset      = errors.New("offset should not be negative")
+	ErrBadSearchKind  = errors.New("undefined search kind")
+	ErrEmptyQuery     = errors.New("search query is empty")
+	ErrBadModAction   = errors.New("undefined moderation action")
+	ErrNoReason       = errors.New("moderation action requires a reason")
+	ErrDeletedByStaff = errors.New("content has been deleted by a moderator")
+	ErrRoleNotAllowed = errors.New("role can't be chosen on registration")
 )
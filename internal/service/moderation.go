@@ -0,0 +1,78 @@
+package service
+
+import (
+	"context"
+	"strings"
+
+	post "github.com/cutlery47/posts/internal/storage/post-storage"
+	"github.com/google/uuid"
+)
+
+// applies moderation action to a post on behalf of a moderator
+func (s *Service) ModeratePost(ctx context.Context, id, userId uuid.UUID, action post.ModAction, reason string) (*post.Post, error) {
+	e, err := modEntry(id, nil, userId, action, reason)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.requireStaff(ctx, userId); err != nil {
+		return nil, err
+	}
+
+	return s.moderatePost(ctx, e, nil)
+}
+
+// applies moderation action to a comment on behalf of a moderator
+func (s *Service) ModerateComment(ctx context.Context, postId, commentId, userId uuid.UUID, action post.ModAction, reason string) (*post.Comment, error) {
+	e, err := modEntry(postId, &commentId, userId, action, reason)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.requireStaff(ctx, userId); err != nil {
+		return nil, err
+	}
+
+	return s.moderateComment(ctx, e, nil)
+}
+
+// retrieves moderation audit log of a post (available to moderators only)
+func (s *Service) GetModerationLog(ctx context.Context, postId, userId uuid.UUID) ([]post.ModEntry, error) {
+	if err := s.requireStaff(ctx, userId); err != nil {
+		return nil, err
+	}
+
+	return s.ps.GetModerationLog(ctx, postId)
+}
+
+func (s *Service) moderatePost(ctx context.Context, e post.ModEntry, version *uint64) (*post.Post, error) {
+	return s.publishPost(e.PostId, func() (*post.Post, error) {
+		return s.ps.ModeratePost(ctx, e, version)
+	})
+}
+
+func (s *Service) moderateComment(ctx context.Context, e post.ModEntry, version *uint64) (*post.Comment, error) {
+	return s.publishComment(e.PostId, func() (*post.Comment, error) {
+		return s.ps.ModerateComment(ctx, e, version)
+	})
+}
+
+// validates moderation request
+func modEntry(postId uuid.UUID, commentId *uuid.UUID, userId uuid.UUID, action post.ModAction, reason string) (post.ModEntry, error) {
+	if !action.Valid() {
+		return post.ModEntry{}, ErrBadModAction
+	}
+
+	reason = strings.TrimSpace(reason)
+	if reason == "" {
+		return post.ModEntry{}, ErrNoReason
+	}
+
+	return post.ModEntry{
+		PostId:    postId,
+		CommentId: commentId,
+		ActorId:   userId,
+		Action:    action,
+		Reason:    reason,
+	}, nil
+}
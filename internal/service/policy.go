@@ -0,0 +1,88 @@
+package service
+
+import (
+	"context"
+	"errors"
+
+	post "github.com/cutlery47/posts/internal/storage/post-storage"
+	user "github.com/cutlery47/posts/internal/storage/user-storage"
+	"github.com/google/uuid"
+)
+
+// roles, which may moderate content of other users
+var staffRoles = map[string]bool{
+	user.AdminRole:     true,
+	user.ModeratorRole: true,
+}
+
+// roles, which may revert content of other users to its earlier revisions
+var editorRoles = map[string]bool{
+	user.AdminRole: true,
+}
+
+// roles, which may grant roles to other users
+var adminRoles = map[string]bool{
+	user.AdminRole: true,
+}
+
+// actions, which authors may take on their own content without moderation
+var authorActions = map[post.ModAction]bool{
+	post.ModDelete:  true,
+	post.ModRestore: true,
+}
+
+type grant int
+
+const (
+	// action is taken by the author
+	grantAuthor grant = iota + 1
+	// action is taken by a moderator and has to be audited
+	grantStaff
+)
+
+// decides whether the user may take the action on content of the author
+func (s *Service) authorize(ctx context.Context, userId, authorId uuid.UUID, action post.ModAction) (grant, error) {
+	if userId == authorId && authorActions[action] {
+		return grantAuthor, nil
+	}
+
+	if err := s.requireStaff(ctx, userId); err != nil {
+		return 0, err
+	}
+
+	return grantStaff, nil
+}
+
+// reports ErrAccessDenied unless the user is a moderator or an administrator
+func (s *Service) RequireStaff(ctx context.Context, userId uuid.UUID) error {
+	return s.requireStaff(ctx, userId)
+}
+
+func (s *Service) requireStaff(ctx context.Context, userId uuid.UUID) error {
+	return s.requireRole(ctx, userId, staffRoles)
+}
+
+// decides whether the user may revert content of the author
+func (s *Service) requireEditor(ctx context.Context, userId, authorId uuid.UUID) error {
+	if userId == authorId {
+		return nil
+	}
+
+	return s.requireRole(ctx, userId, editorRoles)
+}
+
+func (s *Service) requireRole(ctx context.Context, userId uuid.UUID, roles map[string]bool) error {
+	u, err := s.us.GetUser(ctx, userId)
+	if err != nil {
+		if errors.Is(err, user.ErrUserNotFound) {
+			return ErrAccessDenied
+		}
+		return err
+	}
+
+	if !roles[u.Role] {
+		return ErrAccessDenied
+	}
+
+	return nil
+}
@@ -0,0 +1,60 @@
+package service
+
+import (
+	"context"
+
+	post "github.com/cutlery47/posts/internal/storage/post-storage"
+	"github.com/google/uuid"
+)
+
+// retrieves edit history of the post, oldest revisions first
+// history of a deleted post is hidden alongside its content
+func (s *Service) GetPostRevisions(ctx context.Context, p post.Post) ([]post.Revision, error) {
+	if p.DeletedAt != nil {
+		return []post.Revision{}, nil
+	}
+
+	return s.ps.GetRevisions(ctx, p.Id, nil)
+}
+
+// retrieves edit history of the comment, oldest revisions first
+// history of a deleted comment is hidden alongside its content
+func (s *Service) GetCommentRevisions(ctx context.Context, c post.Comment) ([]post.Revision, error) {
+	if c.DeletedAt != nil {
+		return []post.Revision{}, nil
+	}
+
+	return s.ps.GetRevisions(ctx, c.PostId, &c.Id)
+}
+
+// restores content of a post from its revision on behalf of the author or an admin
+func (s *Service) RevertPost(ctx context.Context, postId, revisionId, userId uuid.UUID) (*post.Post, error) {
+	p, err := s.ps.GetPost(ctx, postId)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.requireEditor(ctx, userId, p.UserId); err != nil {
+		return nil, err
+	}
+
+	return s.publishPost(postId, func() (*post.Post, error) {
+		return s.ps.RevertPost(ctx, postId, revisionId, userId)
+	})
+}
+
+// restores content of a comment from its revision on behalf of the author or an admin
+func (s *Service) RevertComment(ctx context.Context, postId, commentId, revisionId, userId uuid.UUID) (*post.Comment, error) {
+	comm, err := s.ps.GetComment(ctx, postId, commentId)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.requireEditor(ctx, userId, comm.UserId); err != nil {
+		return nil, err
+	}
+
+	return s.publishComment(postId, func() (*post.Comment, error) {
+		return s.ps.RevertComment(ctx, postId, commentId, revisionId, userId)
+	})
+}
@@ -4,48 +4,90 @@ import (
 	"context"
 	"errors"
 	"slices"
+	"strings"
+	"time"
 
 	"github.com/cutlery47/posts/config"
+	"github.com/cutlery47/posts/internal/events"
 	post "github.com/cutlery47/posts/internal/storage/post-storage"
 	storage "github.com/cutlery47/posts/internal/storage/post-storage"
 	user "github.com/cutlery47/posts/internal/storage/user-storage"
 	"github.com/google/uuid"
 )
 
-var (
-	SortNewest    = "newest"
-	SortOldest    = "oldest"
-	SortUpvotes   = "upvoted"
-	SortDownvotes = "downvoted"
-)
-
 type Service struct {
 	ps post.Storage
 	us user.Storage
 
+	bus *events.Bus
+
 	conf config.Service
 }
 
-func New(conf config.Service, ps post.Storage, us user.Storage) (*Service, error) {
+func New(conf config.Service, ps post.Storage, us user.Storage, bus *events.Bus) (*Service, error) {
 	return &Service{
 		ps:   ps,
 		us:   us,
+		bus:  bus,
 		conf: conf,
 	}, nil
 }
 
+// subscribes to events, which satisfy the filter
+func (s *Service) Subscribe(filter func(e events.Event) bool) *events.Subscription {
+	return s.bus.Subscribe(filter)
+}
+
+// reports an error if the service is unable to serve requests
+func (s *Service) Ready(ctx context.Context) error {
+	return s.ps.Ready(ctx)
+}
+
 func (s *Service) GetSessionUser(ctx context.Context, seshId uuid.UUID) (uuid.UUID, error) {
-	sesh, err := s.us.GetSession(ctx, seshId)
-	if err != nil && errors.Is(err, user.ErrSessionNotFound) {
+	sesh, err := s.getSession(ctx, seshId)
+	if err != nil {
 		return uuid.UUID{}, err
 	}
 	return sesh.UserId, nil
 }
 
+// registers an ordinary user, roles are granted by administrators only
 func (s *Service) Register(ctx context.Context, in user.InUser) (*user.User, error) {
+	if in.Role != "" {
+		return nil, ErrRoleNotAllowed
+	}
+
+	in.Role = user.UserRole
+
 	return s.us.Register(ctx, in)
 }
 
+// registers the administrator, unless there's a user with the same name already, who is then made one
+func (s *Service) EnsureAdmin(ctx context.Context, name, password string) (*user.User, error) {
+	u, err := s.us.GetUserByName(ctx, name)
+	if errors.Is(err, user.ErrUserNotFound) {
+		return s.us.Register(ctx, user.InUser{Name: name, Role: user.AdminRole, Password: password})
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if u.Role == user.AdminRole {
+		return u, nil
+	}
+
+	return s.us.SetRole(ctx, u.Id, user.AdminRole)
+}
+
+// grants the role to the user on behalf of an administrator
+func (s *Service) SetRole(ctx context.Context, actorId, userId uuid.UUID, role string) (*user.User, error) {
+	if err := s.requireRole(ctx, actorId, adminRoles); err != nil {
+		return nil, err
+	}
+
+	return s.us.SetRole(ctx, userId, role)
+}
+
 func (s *Service) Login(ctx context.Context, in user.InUser) (*user.Session, error) {
 	return s.us.Login(ctx, in)
 }
@@ -54,11 +96,80 @@ func (s *Service) Logout(ctx context.Context, sesh user.Session) error {
 	return s.us.Logout(ctx, sesh)
 }
 
+// issues a new session in place of the provided one
+func (s *Service) RefreshSession(ctx context.Context, seshId uuid.UUID) (*user.Session, error) {
+	if _, err := s.getSession(ctx, seshId); err != nil {
+		return nil, err
+	}
+
+	return s.us.RefreshSession(ctx, seshId)
+}
+
+// revokes every session of the provided session's user
+func (s *Service) LogoutEverywhere(ctx context.Context, seshId uuid.UUID) error {
+	sesh, err := s.getSession(ctx, seshId)
+	if err != nil {
+		return err
+	}
+
+	return s.us.RevokeSessions(ctx, sesh.UserId)
+}
+
+func (s *Service) GetUser(ctx context.Context, id uuid.UUID) (*user.User, error) {
+	return s.us.GetUser(ctx, id)
+}
+
+func (s *Service) GetUserByName(ctx context.Context, name string) (*user.User, error) {
+	return s.us.GetUserByName(ctx, name)
+}
+
+// retrieves users in a single lookup, unknown ids are skipped
+func (s *Service) GetUsers(ctx context.Context, ids []uuid.UUID) ([]user.User, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	return s.us.GetUsers(ctx, ids)
+}
+
+// returns session if it hasn't expired yet
+func (s *Service) getSession(ctx context.Context, seshId uuid.UUID) (*user.Session, error) {
+	sesh, err := s.us.GetSession(ctx, seshId)
+	if err != nil {
+		return nil, err
+	}
+
+	if sesh.Expired(time.Now()) {
+		return nil, user.ErrSessionExpired
+	}
+
+	return sesh, nil
+}
+
 func (s *Service) GetPost(ctx context.Context, id uuid.UUID) (*post.Post, error) {
-	return s.ps.GetPost(ctx, id)
+	return redact(s.ps.GetPost(ctx, id))
 }
 
-func (s *Service) GetPosts(ctx context.Context, limit *int, offset *int, sortBy string) ([]storage.Post, error) {
+// limit is clamped the same way, as the page size is, so that no query gets all of the posts at once
+func (s *Service) GetPosts(ctx context.Context, limit *int, offset *int, sortBy post.SortKey) ([]storage.Post, error) {
+	size := s.conf.PageSize
+
+	if limit != nil {
+		if *limit < 0 {
+			return nil, ErrBadPageSize
+		}
+
+		if *limit > 0 {
+			size = *limit
+		}
+	}
+
+	if offset != nil && *offset < 0 {
+		return nil, ErrBadOffset
+	}
+
+	size = min(size, s.conf.MaxPageSize)
+
 	posts, err := s.ps.GetPosts(ctx)
 	if err != nil {
 		return nil, err
@@ -73,35 +184,101 @@ func (s *Service) GetPosts(ctx context.Context, limit *int, offset *int, sortBy
 		posts = posts[min(*offset, len(posts)):]
 	}
 
-	if limit != nil {
-		posts = posts[:min(*limit, len(posts))]
+	posts = posts[:min(size, len(posts))]
+
+	return redactAll(posts), nil
+}
+
+func (s *Service) GetPostsPage(ctx context.Context, q post.PageQuery) (*post.Page[post.Post], error) {
+	q, err := s.pageQuery(q)
+	if err != nil {
+		return nil, err
 	}
 
-	return posts, nil
+	page, err := s.ps.GetPostsPage(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+
+	page.Items = redactAll(page.Items)
+
+	return page, nil
+}
+
+func (s *Service) Search(ctx context.Context, q post.SearchQuery) (*post.Page[post.SearchHit], error) {
+	if strings.TrimSpace(q.Query) == "" {
+		return nil, ErrEmptyQuery
+	}
+
+	if q.Kind == "" {
+		q.Kind = post.SearchAll
+	}
+
+	if !q.Kind.Valid() {
+		return nil, ErrBadSearchKind
+	}
+
+	if q.Limit < 0 {
+		return nil, ErrBadPageSize
+	}
+
+	if q.Limit == 0 {
+		q.Limit = s.conf.PageSize
+	}
+
+	q.Limit = min(q.Limit, s.conf.MaxPageSize)
+
+	return s.ps.Search(ctx, q)
 }
 
+// inserts post on behalf of the session user
 func (s *Service) InsertPost(ctx context.Context, in post.InPost, userId uuid.UUID) (*post.Post, error) {
-	if in.UserId != userId {
-		return nil, ErrWrongUserId
+	post, err := s.ps.InsertPost(ctx, userId, in)
+	if err != nil {
+		return nil, err
 	}
 
-	return s.ps.InsertPost(ctx, in)
+	s.bus.Publish(events.Event{
+		Kind:   events.PostCreated,
+		PostId: post.Id,
+		Post:   post,
+	})
+
+	return post, nil
 }
 
-func (s *Service) DeletePost(ctx context.Context, id uuid.UUID, userId uuid.UUID) (*uuid.UUID, error) {
-	post, err := s.ps.GetPost(ctx, id)
+// deletes post on behalf of its author or a moderator
+// reason is required only when the post is deleted by a moderator
+// version, if provided, should match the current version of the post
+func (s *Service) DeletePost(ctx context.Context, id uuid.UUID, userId uuid.UUID, reason string, version *uint64) (*uuid.UUID, error) {
+	p, err := s.ps.GetPost(ctx, id)
 	if err != nil {
 		return nil, err
 	}
 
-	if post.UserId != userId {
-		return nil, ErrAccessDenied
+	g, err := s.authorize(ctx, userId, p.UserId, post.ModDelete)
+	if err != nil {
+		return nil, err
+	}
+
+	if g == grantAuthor {
+		return s.ps.DeletePost(ctx, id, version)
 	}
 
-	return s.ps.DeletePost(ctx, id)
+	e, err := modEntry(id, nil, userId, post.ModDelete, reason)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := s.moderatePost(ctx, e, version); err != nil {
+		return nil, err
+	}
+
+	return &id, nil
 }
 
-func (s *Service) UpdatePost(ctx context.Context, id, userId uuid.UUID, in post.InPost) (*post.Post, error) {
+// version, if provided, should match the current version of the post
+func (s *Service) UpdatePost(ctx context.Context, id, userId uuid.UUID, in post.InPost, version *uint64) (*post.Post, error) {
 	post, err := s.ps.GetPost(ctx, id)
 	if err != nil {
 		return nil, err
@@ -111,35 +288,130 @@ func (s *Service) UpdatePost(ctx context.Context, id, userId uuid.UUID, in post.
 		return nil, ErrAccessDenied
 	}
 
-	if in.UserId != userId {
-		return nil, ErrWrongUserId
+	return s.publishPost(id, func() (*storage.Post, error) {
+		return s.ps.UpdatePost(ctx, id, in, version)
+	})
+}
+
+func (s *Service) VotePost(ctx context.Context, id, userId uuid.UUID, vote post.Vote) (*post.Post, error) {
+	if !vote.Valid() {
+		return nil, post.ErrBadVote
+	}
+
+	return redact(s.ps.VotePost(ctx, id, userId, vote))
+}
+
+func (s *Service) GetCommentsPage(ctx context.Context, postId uuid.UUID, parentId *uuid.UUID, q post.PageQuery) (*post.Page[post.Comment], error) {
+	q, err := s.pageQuery(q)
+	if err != nil {
+		return nil, err
+	}
+
+	page, err := s.ps.GetCommentsPage(ctx, postId, parentId, q)
+	if err != nil {
+		return nil, err
+	}
+
+	page.Items = redactAll(page.Items)
+
+	return page, nil
+}
+
+func (s *Service) GetPostsByAuthor(ctx context.Context, userId uuid.UUID, q post.PageQuery) (*post.Page[post.Post], error) {
+	q, err := s.pageQuery(q)
+	if err != nil {
+		return nil, err
+	}
+
+	page, err := s.ps.GetPostsByAuthor(ctx, userId, q)
+	if err != nil {
+		return nil, err
+	}
+
+	page.Items = redactAll(page.Items)
+
+	return page, nil
+}
+
+func (s *Service) GetCommentsByAuthor(ctx context.Context, userId uuid.UUID, q post.PageQuery) (*post.Page[post.Comment], error) {
+	q, err := s.pageQuery(q)
+	if err != nil {
+		return nil, err
+	}
+
+	page, err := s.ps.GetCommentsByAuthor(ctx, userId, q)
+	if err != nil {
+		return nil, err
 	}
 
-	return s.ps.UpdatePost(ctx, id, in)
+	page.Items = redactAll(page.Items)
+
+	return page, nil
 }
 
+// sums vote scores of the user's posts and comments
+func (s *Service) GetKarma(ctx context.Context, userId uuid.UUID) (int64, error) {
+	return s.ps.GetKarma(ctx, userId)
+}
+
+// inserts comment on behalf of the session user
 func (s *Service) InsertComment(ctx context.Context, postId, userId uuid.UUID, parentId *uuid.UUID, in post.InComment) (*post.Comment, error) {
-	if in.UserId != userId {
-		return nil, ErrWrongUserId
+	var (
+		comm *post.Comment
+		err  error
+	)
+
+	err = s.bus.Sequence(postId, func() (*events.Event, error) {
+		comm, err = s.ps.InsertComment(ctx, postId, parentId, userId, in)
+		if err != nil {
+			return nil, err
+		}
+
+		return &events.Event{
+			Kind:    events.CommentAdded,
+			PostId:  postId,
+			Comment: comm,
+		}, nil
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	return s.ps.InsertComment(ctx, postId, parentId, in)
+	return comm, nil
 }
 
-func (s *Service) DeleteComment(ctx context.Context, postId, commentId, userId uuid.UUID) (*uuid.UUID, error) {
+// deletes comment on behalf of its author or a moderator
+// reason is required only when the comment is deleted by a moderator
+// version, if provided, should match the current version of the comment
+func (s *Service) DeleteComment(ctx context.Context, postId, commentId, userId uuid.UUID, reason string, version *uint64) (*uuid.UUID, error) {
 	comm, err := s.ps.GetComment(ctx, postId, commentId)
 	if err != nil {
 		return nil, err
 	}
 
-	if comm.UserId != userId {
-		return nil, ErrAccessDenied
+	g, err := s.authorize(ctx, userId, comm.UserId, post.ModDelete)
+	if err != nil {
+		return nil, err
+	}
+
+	if g == grantAuthor {
+		return s.ps.DeleteComment(ctx, postId, commentId, version)
+	}
+
+	e, err := modEntry(postId, &commentId, userId, post.ModDelete, reason)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := s.moderateComment(ctx, e, version); err != nil {
+		return nil, err
 	}
 
-	return s.ps.DeleteComment(ctx, postId, commentId)
+	return &commentId, nil
 }
 
-func (s *Service) UpdateComment(ctx context.Context, postId, commentId, userId uuid.UUID, in post.InComment) (*post.Comment, error) {
+// version, if provided, should match the current version of the comment
+func (s *Service) UpdateComment(ctx context.Context, postId, commentId, userId uuid.UUID, in post.InComment, version *uint64) (*post.Comment, error) {
 	comm, err := s.ps.GetComment(ctx, postId, commentId)
 	if err != nil {
 		return nil, err
@@ -149,34 +421,124 @@ func (s *Service) UpdateComment(ctx context.Context, postId, commentId, userId u
 		return nil, ErrAccessDenied
 	}
 
-	if userId != in.UserId {
-		return nil, ErrWrongUserId
+	return s.publishComment(postId, func() (*post.Comment, error) {
+		return s.ps.UpdateComment(ctx, postId, commentId, in, version)
+	})
+}
+
+func (s *Service) VoteComment(ctx context.Context, postId, commentId, userId uuid.UUID, vote post.Vote) (*post.Comment, error) {
+	if !vote.Valid() {
+		return nil, post.ErrBadVote
+	}
+
+	return redact(s.ps.VoteComment(ctx, postId, commentId, userId, vote))
+}
+
+// applies post update and notifies subscribers about it
+// updates of a single post are published in the order they were applied
+func (s *Service) publishPost(id uuid.UUID, update func() (*post.Post, error)) (*post.Post, error) {
+	var (
+		upd *post.Post
+		err error
+	)
+
+	err = s.bus.Sequence(id, func() (*events.Event, error) {
+		upd, err = redact(update())
+		if err != nil {
+			return nil, err
+		}
+
+		return &events.Event{
+			Kind:   events.PostUpdated,
+			PostId: id,
+			Post:   upd,
+		}, nil
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	return s.ps.UpdateComment(ctx, postId, commentId, in)
+	return upd, nil
 }
 
-func (s *Service) sortPosts(posts []post.Post, sortBy string) ([]post.Post, error) {
-	switch sortBy {
-	case SortNewest:
-		slices.SortFunc(posts, func(a, b post.Post) int {
-			return b.CreatedAt.Compare(a.CreatedAt)
-		})
-	case SortOldest:
-		slices.SortFunc(posts, func(a, b post.Post) int {
-			return a.CreatedAt.Compare(b.CreatedAt)
-		})
-	case SortUpvotes:
-		slices.SortFunc(posts, func(a, b post.Post) int {
-			return int(b.Upvotes) - int(a.Upvotes)
-		})
-	case SortDownvotes:
-		slices.SortFunc(posts, func(a, b post.Post) int {
-			return int(a.Upvotes) - int(b.Upvotes)
-		})
-	default:
-		return nil, errors.New("undefined sort key")
+// applies comment update and notifies subscribers about it
+// updates are published in the same order as the rest of the events of the post
+func (s *Service) publishComment(postId uuid.UUID, update func() (*post.Comment, error)) (*post.Comment, error) {
+	var (
+		upd *post.Comment
+		err error
+	)
+
+	err = s.bus.Sequence(postId, func() (*events.Event, error) {
+		upd, err = redact(update())
+		if err != nil {
+			return nil, err
+		}
+
+		return &events.Event{
+			Kind:    events.CommentUpdated,
+			PostId:  postId,
+			Comment: upd,
+		}, nil
+	})
+	if err != nil {
+		return nil, err
 	}
 
+	return upd, nil
+}
+
+// returns public representation of a post or a comment, where deleted content is redacted
+func redact[T interface{ Tombstone() T }](v *T, err error) (*T, error) {
+	if err != nil {
+		return nil, err
+	}
+
+	t := (*v).Tombstone()
+
+	return &t, nil
+}
+
+func redactAll[T interface{ Tombstone() T }](items []T) []T {
+	for i := range items {
+		items[i] = items[i].Tombstone()
+	}
+
+	return items
+}
+
+// validates page query and clamps its size
+func (s *Service) pageQuery(q post.PageQuery) (post.PageQuery, error) {
+	if !q.SortBy.Valid() {
+		return q, ErrBadSortKey
+	}
+
+	if !q.Window.Valid() {
+		return q, ErrBadWindow
+	}
+
+	if q.First < 0 {
+		return q, ErrBadPageSize
+	}
+
+	if q.First == 0 {
+		q.First = s.conf.PageSize
+	}
+
+	q.First = min(q.First, s.conf.MaxPageSize)
+
+	return q, nil
+}
+
+// orders posts the same way, as pages of posts are ordered
+func (s *Service) sortPosts(posts []post.Post, sortBy post.SortKey) ([]post.Post, error) {
+	if !sortBy.Valid() {
+		return nil, ErrBadSortKey
+	}
+
+	slices.SortFunc(posts, func(a, b post.Post) int {
+		return sortBy.Compare(a.Cursor(), b.Cursor())
+	})
+
 	return posts, nil
 }
@@ -0,0 +1,134 @@
+package service
+
+import (
+	"context"
+
+	post "github.com/cutlery47/posts/internal/storage/post-storage"
+	"github.com/google/uuid"
+)
+
+// restores post on behalf of its author or a moderator
+// reason is required only when the post is restored by a moderator
+func (s *Service) RestorePost(ctx context.Context, id, userId uuid.UUID, reason string) (*post.Post, error) {
+	p, err := s.ps.GetPost(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	g, err := s.authorize(ctx, userId, p.UserId, post.ModRestore)
+	if err != nil {
+		return nil, err
+	}
+
+	if g == grantStaff {
+		e, err := modEntry(id, nil, userId, post.ModRestore, reason)
+		if err != nil {
+			return nil, err
+		}
+
+		return s.moderatePost(ctx, e, nil)
+	}
+
+	if err := s.checkDeletedByAuthor(ctx, id, nil); err != nil {
+		return nil, err
+	}
+
+	return s.publishPost(id, func() (*post.Post, error) {
+		return s.ps.RestorePost(ctx, id)
+	})
+}
+
+// restores comment on behalf of its author or a moderator
+// reason is required only when the comment is restored by a moderator
+func (s *Service) RestoreComment(ctx context.Context, postId, commentId, userId uuid.UUID, reason string) (*post.Comment, error) {
+	comm, err := s.ps.GetComment(ctx, postId, commentId)
+	if err != nil {
+		return nil, err
+	}
+
+	g, err := s.authorize(ctx, userId, comm.UserId, post.ModRestore)
+	if err != nil {
+		return nil, err
+	}
+
+	if g == grantStaff {
+		e, err := modEntry(postId, &commentId, userId, post.ModRestore, reason)
+		if err != nil {
+			return nil, err
+		}
+
+		return s.moderateComment(ctx, e, nil)
+	}
+
+	if err := s.checkDeletedByAuthor(ctx, postId, &commentId); err != nil {
+		return nil, err
+	}
+
+	return s.publishComment(postId, func() (*post.Comment, error) {
+		return s.ps.RestoreComment(ctx, postId, commentId)
+	})
+}
+
+// retrieves deleted content of the owner
+// users may only see their own trash, while moderators may see anyone's
+func (s *Service) GetTrash(ctx context.Context, userId, ownerId uuid.UUID) (*post.Trash, error) {
+	if userId != ownerId {
+		if err := s.requireStaff(ctx, userId); err != nil {
+			return nil, err
+		}
+	}
+
+	trash, err := s.ps.GetTrash(ctx, ownerId)
+	if err != nil {
+		return nil, err
+	}
+
+	// trashed items are shown in full, while the rest of their trees is redacted
+	for i, p := range trash.Posts {
+		t := p.Tombstone()
+		t.InPost = p.InPost
+		trash.Posts[i] = t
+	}
+
+	for i, c := range trash.Comments {
+		t := c.Tombstone()
+		t.InComment = c.InComment
+		trash.Comments[i] = t
+	}
+
+	return trash, nil
+}
+
+// authors can't revert moderators' decisions
+// fails if the last deletion of the post (or the comment, if provided) was made by a moderator
+func (s *Service) checkDeletedByAuthor(ctx context.Context, postId uuid.UUID, commentId *uuid.UUID) error {
+	log, err := s.ps.GetModerationLog(ctx, postId)
+	if err != nil {
+		return err
+	}
+
+	for i := len(log) - 1; i >= 0; i-- {
+		e := log[i]
+
+		if !sameItem(e.CommentId, commentId) {
+			continue
+		}
+
+		switch e.Action {
+		case post.ModDelete:
+			return ErrDeletedByStaff
+		case post.ModRestore:
+			return nil
+		}
+	}
+
+	return nil
+}
+
+func sameItem(a, b *uuid.UUID) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+
+	return *a == *b
+}
@@ -7,10 +7,8 @@ import (
 )
 
 // input-bound comment
+// holds only the fields, which are editable by the author
 type InComment struct {
-	// creator id
-	UserId uuid.UUID `json:"user_id"`
-
 	Content string `json:"content"`
 }
 
@@ -20,14 +18,33 @@ type Comment struct {
 	InComment `json:"in_comment"`
 
 	Id uuid.UUID `json:"id"`
+	// author id
+	UserId uuid.UUID `json:"user_id"`
+	// post, which the comment belongs to
+	PostId uuid.UUID `json:"post_id"`
+	// parent comment id (nil for top-level comments)
+	ParentId *uuid.UUID `json:"parent_id"`
+	// nesting level (1 for top-level comments)
+	Depth int `json:"depth"`
+
+	// set by moderators only
+	IsPinned bool `json:"is_pinned"`
 
 	Upvotes   uint64 `json:"upvotes"`
 	Downvotes uint64 `json:"downvotes"`
+	// derived from votes and age, so it isn't persisted alongside the comment
+	Ranking Ranking `json:"-"`
+
+	// incremented on every change of the comment, except for votes and replies
+	Version uint64 `json:"version"`
 
 	CreatedAt time.Time  `json:"created_at"`
 	UpdatedAt time.Time  `json:"updated_at"`
 	DeletedAt *time.Time `json:"deleted_at"`
 
+	// number of direct replies
+	ReplyCount int `json:"reply_count"`
 	// CommentId -> Comment
+	// left empty on pages of comments: replies are paginated on their own
 	Replies map[uuid.UUID]Comment `json:"replies"`
 }
@@ -1,12 +1,49 @@
 package storage
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+)
 
 var (
 	ErrPostNotFound   = errors.New("post not found")
 	ErrPostIsDeleted  = errors.New("post has been deleted")
 	ErrPostIsMute     = errors.New("post is mute")
+	ErrPostIsLocked   = errors.New("post has been locked by a moderator")
+	ErrPostNotDeleted = errors.New("post hasn't been deleted")
 	ErrCommNotFound   = errors.New("comment not found")
 	ErrCommIsDeleted  = errors.New("comment has been deleted")
+	ErrCommNotDeleted = errors.New("comment hasn't been deleted")
+	ErrCommTooDeep    = errors.New("comment is nested too deep")
+	ErrBadModAction   = errors.New("moderation action can't be applied")
+	ErrRevNotFound    = errors.New("revision not found")
+	ErrConflict       = errors.New("item has been changed concurrently")
+	ErrBadVote        = errors.New("vote should be one of: -1, 0, 1")
 	ErrNotImplemented = errors.New("not implemented")
 )
+
+// reported when an item has been changed since the version, which the change was based on
+// matches ErrConflict
+type ConflictError struct {
+	// version, which the change was based on
+	Expected uint64
+	// current version of the item
+	Actual uint64
+}
+
+func (e *ConflictError) Error() string {
+	return fmt.Sprintf("%v: expected version %v, actual version %v", ErrConflict, e.Expected, e.Actual)
+}
+
+func (e *ConflictError) Is(target error) bool {
+	return target == ErrConflict
+}
+
+// fails with ConflictError, unless the expected version is either missing or matches the actual one
+func CheckVersion(expected *uint64, actual uint64) error {
+	if expected == nil || *expected == actual {
+		return nil
+	}
+
+	return &ConflictError{Expected: *expected, Actual: actual}
+}
@@ -0,0 +1,117 @@
+package mem
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/cutlery47/posts/config"
+	storage "github.com/cutlery47/posts/internal/storage/post-storage"
+	"github.com/google/uuid"
+)
+
+// inserts comments from parallel goroutines, spread over the provided number of posts
+func benchInsertComment(b *testing.B, ms *memStorage, posts int) {
+	var (
+		ctx = context.Background()
+		ids = make([]uuid.UUID, posts)
+		n   atomic.Uint64
+	)
+
+	for i := range ids {
+		post, err := ms.InsertPost(ctx, uuid.New(), storage.InPost{})
+		if err != nil {
+			b.Fatalf("error: %v", err)
+		}
+		ids[i] = post.Id
+	}
+
+	in := storage.InComment{Content: "the quick brown fox jumps over the lazy dog"}
+
+	b.ResetTimer()
+
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			id := ids[n.Add(1)%uint64(len(ids))]
+
+			if _, err := ms.InsertComment(ctx, id, nil, uuid.New(), in); err != nil {
+				b.Errorf("error: %v", err)
+				return
+			}
+		}
+	})
+}
+
+func BenchmarkInsertComment(b *testing.B) {
+	for _, posts := range []int{1, 64} {
+		b.Run(fmt.Sprintf("posts=%v", posts), func(b *testing.B) {
+			ms, err := NewStorage(config.PostStorage{}, nil)
+			if err != nil {
+				b.Fatalf("error: %v", err)
+			}
+
+			benchInsertComment(b, ms, posts)
+		})
+	}
+}
+
+// snapshots, taken back to back, shouldn't hold writers off
+func BenchmarkInsertCommentDuringSnapshot(b *testing.B) {
+	for _, snapshots := range []bool{false, true} {
+		b.Run(fmt.Sprintf("snapshots=%v", snapshots), func(b *testing.B) {
+			dir := b.TempDir()
+
+			conf := config.PostStorage{
+				RestoreSource:   filepath.Join(dir, "dump"),
+				DumpDestination: filepath.Join(dir, "dump"),
+				WALDestination:  filepath.Join(dir, "wal"),
+				DumpEnabled:     true,
+				// snapshots are taken manually
+				DumpInterval:    time.Hour,
+				WALSyncInterval: time.Millisecond,
+			}
+
+			ms, err := NewStorage(conf, nil)
+			if err != nil {
+				b.Fatalf("error: %v", err)
+			}
+
+			var (
+				stop = make(chan struct{})
+				done = make(chan struct{})
+			)
+
+			// snapshots are stopped before the directory is removed
+			defer func() {
+				close(stop)
+				<-done
+			}()
+
+			if !snapshots {
+				close(done)
+			} else {
+				go func() {
+					defer close(done)
+
+					for {
+						select {
+						case <-stop:
+							return
+						default:
+						}
+
+						if err := ms.snapshot(); err != nil {
+							b.Errorf("error: %v", err)
+							return
+						}
+					}
+				}()
+			}
+
+			benchInsertComment(b, ms, 64)
+		})
+	}
+}
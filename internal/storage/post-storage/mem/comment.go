@@ -7,132 +7,93 @@ import (
 	"github.com/google/uuid"
 )
 
-func insertReply(c storage.Comment, in storage.InComment) (*storage.Comment, error) {
-	if c.DeletedAt != nil {
-		return nil, storage.ErrCommIsDeleted
+// helpers below work on a detached comment: its replies are indexed flatly,
+// so that any of them is reached in O(1), and the changes are written back into c.Replies
+
+func indexComment(c storage.Comment) (*postNode, *node) {
+	pn := newPostNode(storage.Post{Id: c.PostId})
+
+	n := pn.link(c, nil)
+	pn.graft(c.Replies, n)
+
+	return pn, n
+}
+
+// returns a reply at any depth below the comment
+func (n *node) reply(pn *postNode, id uuid.UUID) (*node, error) {
+	if id == n.comm.Id {
+		return nil, storage.ErrCommNotFound
 	}
 
-	var (
-		repl storage.Comment = toComment(in)
-	)
+	return pn.comment(id)
+}
+
+func insertReply(c storage.Comment, userId uuid.UUID, in storage.InComment) (*storage.Comment, error) {
+	pn, n := indexComment(c)
 
-	// loop until no collisions detected
-	for _, ok := c.Replies[repl.Id]; ok; _, ok = c.Replies[repl.Id] {
-		repl = toComment(in)
+	r, err := pn.insert(n, userId, in)
+	if err != nil {
+		return nil, err
 	}
+	replant(c.Replies, n.children)
 
-	c.Replies[repl.Id] = repl
+	repl := r.tree()
 
 	return &repl, nil
 }
 
 func getReply(c storage.Comment, id uuid.UUID) (*storage.Comment, bool) {
-	if len(c.Replies) == 0 {
-		return nil, false
-	}
-
-	// fast path
-	r, ok := c.Replies[id]
-	if ok {
-		return &r, true
-	}
+	pn, n := indexComment(c)
 
-	var (
-		repl *storage.Comment
-	)
-
-	// slow path
-	for idx, r := range c.Replies {
-		if idx == id {
-			repl = &r
-			break
-		}
-
-		cr, ok := getReply(r, id)
-		if ok {
-			repl = cr
-			break
-		}
-	}
-
-	if repl == nil {
+	r, err := n.reply(pn, id)
+	if err != nil {
 		return nil, false
 	}
 
-	return repl, true
+	repl := r.tree()
+
+	return &repl, true
 }
 
 func updateReply(c storage.Comment, id uuid.UUID, in storage.InComment) (*storage.Comment, error) {
-	if len(c.Replies) == 0 {
-		return nil, storage.ErrCommNotFound
-	}
+	pn, n := indexComment(c)
 
-	// fast path
-	r, ok := c.Replies[id]
-	if ok {
-		r.Content = in.Content
-		r.UpdatedAt = time.Now()
-		c.Replies[id] = r
-		return &r, nil
+	if _, err := n.reply(pn, id); err != nil {
+		return nil, err
 	}
 
-	var (
-		repl *storage.Comment
-	)
-
-	// slow path
-	for idx, r := range c.Replies {
-		if idx == id {
-			r.Content = in.Content
-			r.UpdatedAt = time.Now()
-			c.Replies[idx] = r
-			repl = &r
-			break
-		}
-
-		rr, err := updateReply(r, id, in)
-		if err == nil {
-			repl = rr
-			break
-		}
+	r, err := pn.mutate(id, func(c *storage.Comment) error {
+		c.Content = in.Content
+		c.UpdatedAt = time.Now()
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
+	replant(c.Replies, n.children)
 
-	if repl == nil {
-		return nil, storage.ErrCommNotFound
-	}
+	repl := r.tree()
 
-	return repl, nil
+	return &repl, nil
 }
 
 func deleteReply(c storage.Comment, id uuid.UUID) (*uuid.UUID, error) {
-	if len(c.Replies) == 0 {
-		return nil, storage.ErrCommNotFound
+	pn, n := indexComment(c)
+
+	if _, err := n.reply(pn, id); err != nil {
+		return nil, err
 	}
 
 	ts := time.Now()
 
-	// fast path
-	r, ok := c.Replies[id]
-	if ok {
-		r.DeletedAt = &ts
-		c.Replies[id] = r
-		return &id, nil
-	}
-
-	// slow path
-	for idx, r := range c.Replies {
-		if idx == id {
-			ts := time.Now()
-			r.DeletedAt = &ts
-			r.Replies[idx] = r
-			return &id, nil
-		}
-
-		found, err := deleteReply(r, id)
-		if err == nil {
-			return found, nil
-		}
+	r, err := pn.mutate(id, func(c *storage.Comment) error {
+		c.DeletedAt = &ts
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
+	replant(c.Replies, n.children)
 
-	return nil, storage.ErrCommNotFound
+	return &r.comm.Id, nil
 }
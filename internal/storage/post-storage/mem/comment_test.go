@@ -20,7 +20,7 @@ func TestCommentGetEmptyReplies(t *testing.T) {
 		DeletedAt: &ts,
 	}
 
-	_, err := insertReply(comm, storage.InComment{})
+	_, err := insertReply(comm, uuid.New(), storage.InComment{})
 	if !errors.Is(err, storage.ErrCommIsDeleted) {
 		t.Fatalf("error: %v", err)
 	}
@@ -5,4 +5,10 @@ import "errors"
 var (
 	ErrBadDump    = errors.New("error when dumping")
 	ErrBadRestore = errors.New("error when restoring")
+	ErrBadWal     = errors.New("error when writing wal")
+	ErrClosed     = errors.New("storage is closed")
+	// wal is compacted once the snapshot is written, so it should be restored from the same file
+	ErrSnapshotPath = errors.New("restore source and dump destination should be the same file")
+	// snapshot was written by a newer build or isn't a snapshot at all
+	ErrSnapshotFormat = errors.New("unknown snapshot format")
 )
@@ -0,0 +1,104 @@
+package mem
+
+import (
+	"context"
+	"time"
+
+	storage "github.com/cutlery47/posts/internal/storage/post-storage"
+	"github.com/google/uuid"
+)
+
+func (ms *memStorage) ModeratePost(ctx context.Context, e storage.ModEntry, version *uint64) (*storage.Post, error) {
+	if err := ctxDone(ctx); err != nil {
+		return nil, err
+	}
+
+	var (
+		post storage.Post
+	)
+
+	err := ms.commitPost(e.PostId, func(pn *postNode) ([]walRecord, error) {
+		if err := storage.CheckVersion(version, pn.post.Version); err != nil {
+			return nil, err
+		}
+
+		ts := time.Now()
+
+		if err := pn.post.Moderate(e.Action, ts); err != nil {
+			return nil, err
+		}
+
+		switch e.Action {
+		case storage.ModDelete:
+			ms.idx.removeTree(pn)
+		case storage.ModRestore:
+			ms.idx.addTree(pn)
+		}
+
+		post = pn.tree()
+
+		return []walRecord{postRecord(pn.post), modRecord(pn.record(stamp(e, ts)))}, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &post, nil
+}
+
+func (ms *memStorage) ModerateComment(ctx context.Context, e storage.ModEntry, version *uint64) (*storage.Comment, error) {
+	if err := ctxDone(ctx); err != nil {
+		return nil, err
+	}
+
+	if e.CommentId == nil {
+		return nil, storage.ErrCommNotFound
+	}
+
+	return ms.commitComment(e.PostId, *e.CommentId, func(pn *postNode, c *storage.Comment) ([]walRecord, error) {
+		if err := storage.CheckVersion(version, c.Version); err != nil {
+			return nil, err
+		}
+
+		ts := time.Now()
+
+		if err := c.Moderate(e.Action, ts); err != nil {
+			return nil, err
+		}
+
+		switch e.Action {
+		case storage.ModDelete:
+			ms.idx.remove(c.Id)
+		case storage.ModRestore:
+			if pn.post.DeletedAt == nil {
+				ms.idx.addComment(*c)
+			}
+		}
+
+		return []walRecord{modRecord(pn.record(stamp(e, ts)))}, nil
+	})
+}
+
+func (ms *memStorage) GetModerationLog(ctx context.Context, postId uuid.UUID) ([]storage.ModEntry, error) {
+	if err := ctxDone(ctx); err != nil {
+		return nil, err
+	}
+
+	pn, ok := ms.posts.get(postId)
+	if !ok {
+		return nil, storage.ErrPostNotFound
+	}
+
+	pn.mu.RLock()
+	defer pn.mu.RUnlock()
+
+	return append([]storage.ModEntry{}, pn.modlog...), nil
+}
+
+// assigns id and time to an audit log entry
+func stamp(e storage.ModEntry, ts time.Time) storage.ModEntry {
+	e.Id = uuid.New()
+	e.CreatedAt = ts
+
+	return e
+}
@@ -7,137 +7,89 @@ import (
 	"github.com/google/uuid"
 )
 
-func insertComment(p storage.Post, in storage.InComment) (*storage.Comment, error) {
-	if p.DeletedAt != nil {
-		return nil, storage.ErrPostIsDeleted
-	}
+// helpers below work on a detached post: its comments are indexed flatly,
+// so that any of them is reached in O(1), and the changes are written back into p.Comments
 
-	if p.IsMute {
-		return nil, storage.ErrPostIsMute
-	}
+func indexPost(p storage.Post) *postNode {
+	pn := newPostNode(p)
+	pn.graft(p.Comments, nil)
+
+	return pn
+}
 
-	var (
-		comm storage.Comment = toComment(in)
-	)
+func insertComment(p storage.Post, userId uuid.UUID, in storage.InComment) (*storage.Comment, error) {
+	pn := indexPost(p)
 
-	// loop until no collisions detected
-	for _, ok := p.Comments[comm.Id]; ok; _, ok = p.Comments[comm.Id] {
-		comm = toComment(in)
+	n, err := pn.insert(nil, userId, in)
+	if err != nil {
+		return nil, err
 	}
+	replant(p.Comments, pn.roots)
 
-	p.Comments[comm.Id] = comm
+	comm := n.tree()
 
 	return &comm, nil
 }
 
-func getComment(p storage.Post, id uuid.UUID) (*storage.Comment, bool) {
-	if len(p.Comments) == 0 {
-		return nil, false
+// checks if the post accepts new comments and replies
+func canComment(p storage.Post) error {
+	if p.DeletedAt != nil {
+		return storage.ErrPostIsDeleted
 	}
 
-	// fast path
-	c, ok := p.Comments[id]
-	if ok {
-		return &c, true
+	if p.IsMute {
+		return storage.ErrPostIsMute
 	}
 
-	var (
-		comm *storage.Comment
-	)
-
-	// slow path
-	// iterate down the tree for each comment
-	for idx, c := range p.Comments {
-		if idx == id {
-			comm = &c
-			break
-		}
-
-		r, ok := getReply(c, id)
-		if ok {
-			comm = r
-			break
-		}
+	if p.IsLocked {
+		return storage.ErrPostIsLocked
 	}
 
-	if comm == nil {
+	return nil
+}
+
+func getComment(p storage.Post, id uuid.UUID) (*storage.Comment, bool) {
+	n, err := indexPost(p).comment(id)
+	if err != nil {
 		return nil, false
 	}
 
-	return comm, true
+	comm := n.tree()
+
+	return &comm, true
 }
 
 func updateComment(p storage.Post, id uuid.UUID, in storage.InComment) (*storage.Comment, error) {
-	if len(p.Comments) == 0 {
-		return nil, storage.ErrCommNotFound
-	}
+	pn := indexPost(p)
 
-	// fast path
-	c, ok := p.Comments[id]
-	if ok {
+	n, err := pn.mutate(id, func(c *storage.Comment) error {
 		c.Content = in.Content
 		c.UpdatedAt = time.Now()
-		p.Comments[id] = c
-		return &c, nil
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
+	replant(p.Comments, pn.roots)
 
-	var (
-		comm *storage.Comment
-	)
-
-	// slow path
-	// iterate down the tree and update
-	for idx, c := range p.Comments {
-		if idx == id {
-			c.Content = in.Content
-			c.UpdatedAt = time.Now()
-			p.Comments[idx] = c
-			comm = &c
-			break
-		}
-
-		r, err := updateReply(c, id, in)
-		if err == nil {
-			comm = r
-			break
-		}
-	}
+	comm := n.tree()
 
-	if comm == nil {
-		return nil, storage.ErrCommNotFound
-	}
-
-	return comm, nil
+	return &comm, nil
 }
 
 func deleteComment(p storage.Post, id uuid.UUID) (*uuid.UUID, error) {
-	if len(p.Comments) == 0 {
-		return nil, storage.ErrCommNotFound
-	}
+	pn := indexPost(p)
 
 	ts := time.Now()
 
-	// fast path
-	c, ok := p.Comments[id]
-	if ok {
+	n, err := pn.mutate(id, func(c *storage.Comment) error {
 		c.DeletedAt = &ts
-		p.Comments[id] = c
-		return &id, nil
-	}
-
-	// slow path
-	for idx, c := range p.Comments {
-		if idx == id {
-			c.DeletedAt = &ts
-			p.Comments[idx] = c
-			return &idx, nil
-		}
-
-		found, err := deleteReply(c, id)
-		if err == nil {
-			return found, nil
-		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
+	replant(p.Comments, pn.roots)
 
-	return nil, storage.ErrCommNotFound
+	return &n.comm.Id, nil
 }
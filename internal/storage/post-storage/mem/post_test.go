@@ -51,7 +51,7 @@ func TestPostInsertCommentIntoDeleted(t *testing.T) {
 		DeletedAt: &ts,
 	}
 
-	_, err := insertComment(post, storage.InComment{})
+	_, err := insertComment(post, uuid.New(), storage.InComment{})
 	if !errors.Is(err, storage.ErrPostIsDeleted) {
 		t.Fatalf("error: %v", err)
 	}
@@ -64,7 +64,7 @@ func TestPostInsertCommentIntoMute(t *testing.T) {
 		},
 	}
 
-	_, err := insertComment(post, storage.InComment{})
+	_, err := insertComment(post, uuid.New(), storage.InComment{})
 	if !errors.Is(err, storage.ErrPostIsMute) {
 		t.Fatalf("error: %v", err)
 	}
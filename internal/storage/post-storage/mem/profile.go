@@ -0,0 +1,210 @@
+package mem
+
+import (
+	"context"
+	"sync"
+
+	storage "github.com/cutlery47/posts/internal/storage/post-storage"
+	"github.com/google/uuid"
+)
+
+func (ms *memStorage) GetPostsByAuthor(ctx context.Context, userId uuid.UUID, q storage.PageQuery) (*storage.Page[storage.Post], error) {
+	if err := ctxDone(ctx); err != nil {
+		return nil, err
+	}
+
+	posts := func(yield func(*postNode) bool) {
+		for _, pn := range ms.authors.postsOf(userId) {
+			if !pn.deleted() && !yield(pn) {
+				return
+			}
+		}
+	}
+
+	return mapPage(selectPage(posts, q), (*postNode).get), nil
+}
+
+func (ms *memStorage) GetCommentsByAuthor(ctx context.Context, userId uuid.UUID, q storage.PageQuery) (*storage.Page[storage.Comment], error) {
+	if err := ctxDone(ctx); err != nil {
+		return nil, err
+	}
+
+	comms := func(yield func(authored) bool) {
+		for _, a := range ms.authors.commentsOf(userId) {
+			if !a.deleted() && !yield(a) {
+				return
+			}
+		}
+	}
+
+	return mapPage(selectPage(comms, q), authored.get), nil
+}
+
+func (ms *memStorage) GetKarma(ctx context.Context, userId uuid.UUID) (int64, error) {
+	if err := ctxDone(ctx); err != nil {
+		return 0, err
+	}
+
+	var (
+		karma int64
+	)
+
+	for _, pn := range ms.authors.postsOf(userId) {
+		pn.mu.RLock()
+		if pn.post.DeletedAt == nil {
+			karma += storage.Score(pn.post.Upvotes, pn.post.Downvotes)
+		}
+		pn.mu.RUnlock()
+	}
+
+	for _, a := range ms.authors.commentsOf(userId) {
+		a.pn.mu.RLock()
+		if a.n.comm.DeletedAt == nil {
+			karma += storage.Score(a.n.comm.Upvotes, a.n.comm.Downvotes)
+		}
+		a.pn.mu.RUnlock()
+	}
+
+	return karma, nil
+}
+
+// reads the post under its lock
+func (pn *postNode) deleted() bool {
+	pn.mu.RLock()
+	defer pn.mu.RUnlock()
+
+	return pn.post.DeletedAt != nil
+}
+
+// comment alongside the post, whose lock guards it
+type authored struct {
+	pn *postNode
+	n  *node
+}
+
+func (a authored) Cursor() storage.Cursor {
+	a.pn.mu.RLock()
+	defer a.pn.mu.RUnlock()
+
+	return a.n.comm.Cursor()
+}
+
+// copies the comment without its replies under the post's lock
+func (a authored) get() storage.Comment {
+	a.pn.mu.RLock()
+	defer a.pn.mu.RUnlock()
+
+	return a.n.flat()
+}
+
+func (a authored) deleted() bool {
+	a.pn.mu.RLock()
+	defer a.pn.mu.RUnlock()
+
+	return a.n.comm.DeletedAt != nil
+}
+
+// UserId -> posts and comments of the user
+// authors never change, so entries are only added on insert and dropped on purge
+type authorIndex struct {
+	mu sync.RWMutex
+
+	// UserId -> PostId -> post
+	posts map[uuid.UUID]map[uuid.UUID]*postNode
+	// UserId -> CommentId -> comment
+	comments map[uuid.UUID]map[uuid.UUID]authored
+}
+
+func newAuthorIndex() *authorIndex {
+	return &authorIndex{
+		posts:    make(map[uuid.UUID]map[uuid.UUID]*postNode),
+		comments: make(map[uuid.UUID]map[uuid.UUID]authored),
+	}
+}
+
+// should be called with the post locked (or not yet reachable)
+func (ai *authorIndex) addPost(pn *postNode) {
+	ai.mu.Lock()
+	defer ai.mu.Unlock()
+
+	posts, ok := ai.posts[pn.post.UserId]
+	if !ok {
+		posts = make(map[uuid.UUID]*postNode)
+		ai.posts[pn.post.UserId] = posts
+	}
+
+	posts[pn.post.Id] = pn
+}
+
+// should be called with the post locked (or not yet reachable)
+func (ai *authorIndex) addComment(pn *postNode, n *node) {
+	ai.mu.Lock()
+	defer ai.mu.Unlock()
+
+	comms, ok := ai.comments[n.comm.UserId]
+	if !ok {
+		comms = make(map[uuid.UUID]authored)
+		ai.comments[n.comm.UserId] = comms
+	}
+
+	comms[n.comm.Id] = authored{pn: pn, n: n}
+}
+
+// indexes the post alongside all of its comments
+func (ai *authorIndex) addTree(pn *postNode) {
+	ai.addPost(pn)
+
+	for _, n := range pn.comments {
+		ai.addComment(pn, n)
+	}
+}
+
+func (ai *authorIndex) removePost(p storage.Post) {
+	ai.mu.Lock()
+	defer ai.mu.Unlock()
+
+	delete(ai.posts[p.UserId], p.Id)
+
+	if len(ai.posts[p.UserId]) == 0 {
+		delete(ai.posts, p.UserId)
+	}
+}
+
+func (ai *authorIndex) removeComment(c storage.Comment) {
+	ai.mu.Lock()
+	defer ai.mu.Unlock()
+
+	delete(ai.comments[c.UserId], c.Id)
+
+	if len(ai.comments[c.UserId]) == 0 {
+		delete(ai.comments, c.UserId)
+	}
+}
+
+// lists posts of the user at the moment of the call
+func (ai *authorIndex) postsOf(userId uuid.UUID) []*postNode {
+	ai.mu.RLock()
+	defer ai.mu.RUnlock()
+
+	posts := make([]*postNode, 0, len(ai.posts[userId]))
+
+	for _, pn := range ai.posts[userId] {
+		posts = append(posts, pn)
+	}
+
+	return posts
+}
+
+// lists comments of the user at the moment of the call
+func (ai *authorIndex) commentsOf(userId uuid.UUID) []authored {
+	ai.mu.RLock()
+	defer ai.mu.RUnlock()
+
+	comms := make([]authored, 0, len(ai.comments[userId]))
+
+	for _, a := range ai.comments[userId] {
+		comms = append(comms, a)
+	}
+
+	return comms
+}
@@ -0,0 +1,121 @@
+package mem
+
+import (
+	"context"
+	"log"
+	"slices"
+	"time"
+
+	"github.com/cutlery47/posts/internal/metrics"
+	storage "github.com/cutlery47/posts/internal/storage/post-storage"
+	"github.com/google/uuid"
+)
+
+func (ms *memStorage) Purge(ctx context.Context, before time.Time) (*storage.PurgeStats, error) {
+	if err := ctxDone(ctx); err != nil {
+		return nil, err
+	}
+
+	var (
+		stats = &storage.PurgeStats{}
+	)
+
+	err := ms.commit(func() ([]walRecord, error) {
+		ms.purge(before, stats)
+
+		if *stats == (storage.PurgeStats{}) {
+			return nil, nil
+		}
+
+		return []walRecord{purgeRecord(before)}, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return stats, nil
+}
+
+// removes expired posts and comments
+// should be called with mu held exclusively: posts are locked only to keep readers off
+func (ms *memStorage) purge(before time.Time, stats *storage.PurgeStats) {
+	for _, pn := range ms.posts.all() {
+		pn.mu.Lock()
+
+		if expired(pn.post.DeletedAt, before) {
+			ms.purgePost(pn, stats)
+		} else {
+			ms.purgeComments(pn, pn.roots, before, stats)
+		}
+
+		pn.mu.Unlock()
+	}
+}
+
+func (ms *memStorage) purgePost(pn *postNode, stats *storage.PurgeStats) {
+	for _, n := range pn.comments {
+		ms.forget(pn, n.comm, stats)
+	}
+
+	stats.Posts++
+	stats.Bytes += int64(len(pn.post.Content))
+
+	ms.idx.remove(pn.post.Id)
+	ms.authors.removePost(pn.post)
+	ms.posts.remove(pn.post.Id)
+}
+
+// removes expired comments alongside their subtrees
+func (ms *memStorage) purgeComments(pn *postNode, nodes map[uuid.UUID]*node, before time.Time, stats *storage.PurgeStats) {
+	for _, n := range nodes {
+		if !expired(n.comm.DeletedAt, before) {
+			ms.purgeComments(pn, n.children, before, stats)
+			continue
+		}
+
+		for _, c := range pn.unlink(n) {
+			ms.forget(pn, c, stats)
+		}
+	}
+}
+
+// drops everything, which refers to a purged comment
+func (ms *memStorage) forget(pn *postNode, c storage.Comment, stats *storage.PurgeStats) {
+	stats.Comments++
+	stats.Bytes += int64(len(c.Content))
+
+	ms.idx.remove(c.Id)
+	ms.authors.removeComment(c)
+	delete(pn.ballots, c.Id)
+	pn.unrevise(c.Id)
+
+	pn.modlog = slices.DeleteFunc(pn.modlog, func(e storage.ModEntry) bool {
+		return e.CommentId != nil && *e.CommentId == c.Id
+	})
+}
+
+// periodically purges content, which has been deleted for longer than the retention period
+// stops once the storage is closed
+func (ms *memStorage) purger() {
+	defer ms.workers.Done()
+
+	for {
+		select {
+		case <-time.After(ms.conf.PurgeInterval):
+		case <-ms.done:
+			return
+		}
+
+		stats, err := ms.Purge(context.Background(), time.Now().Add(-ms.conf.Retention))
+		if err != nil {
+			log.Println("[PURGER] couldn't purge deleted content:", err)
+			metrics.ObservePurge(0, 0, 0, err)
+		} else {
+			metrics.ObservePurge(stats.Posts, stats.Comments, stats.Bytes, nil)
+		}
+	}
+}
+
+func expired(deletedAt *time.Time, before time.Time) bool {
+	return deletedAt != nil && deletedAt.Before(before)
+}
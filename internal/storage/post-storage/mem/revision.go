@@ -0,0 +1,102 @@
+package mem
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	storage "github.com/cutlery47/posts/internal/storage/post-storage"
+	"github.com/google/uuid"
+)
+
+func (ms *memStorage) GetRevisions(ctx context.Context, postId uuid.UUID, commentId *uuid.UUID) ([]storage.Revision, error) {
+	if err := ctxDone(ctx); err != nil {
+		return nil, err
+	}
+
+	pn, ok := ms.posts.get(postId)
+	if !ok {
+		return nil, storage.ErrPostNotFound
+	}
+
+	pn.mu.RLock()
+	defer pn.mu.RUnlock()
+
+	itemId := postId
+
+	if commentId != nil {
+		if _, err := pn.comment(*commentId); err != nil {
+			return nil, err
+		}
+		itemId = *commentId
+	}
+
+	return slices.Clone(pn.revisions[itemId]), nil
+}
+
+func (ms *memStorage) RevertPost(ctx context.Context, postId, revisionId, editorId uuid.UUID) (*storage.Post, error) {
+	if err := ctxDone(ctx); err != nil {
+		return nil, err
+	}
+
+	var (
+		post storage.Post
+	)
+
+	err := ms.commitPost(postId, func(pn *postNode) ([]walRecord, error) {
+		if pn.post.DeletedAt != nil {
+			return nil, storage.ErrPostIsDeleted
+		}
+
+		rev, err := pn.revision(postId, revisionId)
+		if err != nil {
+			return nil, err
+		}
+
+		ts := time.Now()
+
+		pn.post.Content = rev.Content
+		pn.post.UpdatedAt = ts
+		pn.post.Version++
+
+		ms.idx.addPost(pn.post)
+
+		post = pn.tree()
+
+		return []walRecord{postRecord(pn.post), revisionRecord(pn.revise(storage.PostRevision(pn.post, editorId, ts)))}, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &post, nil
+}
+
+func (ms *memStorage) RevertComment(ctx context.Context, postId, commentId, revisionId, editorId uuid.UUID) (*storage.Comment, error) {
+	if err := ctxDone(ctx); err != nil {
+		return nil, err
+	}
+
+	return ms.commitComment(postId, commentId, func(pn *postNode, c *storage.Comment) ([]walRecord, error) {
+		if c.DeletedAt != nil {
+			return nil, storage.ErrCommIsDeleted
+		}
+
+		rev, err := pn.revision(commentId, revisionId)
+		if err != nil {
+			return nil, err
+		}
+
+		ts := time.Now()
+
+		c.Content = rev.Content
+		c.UpdatedAt = ts
+		c.Version++
+
+		if pn.post.DeletedAt == nil {
+			ms.idx.addComment(*c)
+		}
+
+		return []walRecord{revisionRecord(pn.revise(storage.CommentRevision(*c, editorId, ts)))}, nil
+	})
+}
@@ -0,0 +1,320 @@
+package mem
+
+import (
+	"context"
+	"html"
+	"strings"
+	"sync"
+	"unicode"
+
+	storage "github.com/cutlery47/posts/internal/storage/post-storage"
+	"github.com/google/uuid"
+)
+
+const (
+	// number of words, which are shown before the first match in a snippet
+	snippetLead = 5
+	// max number of words in a snippet
+	snippetWords = 20
+)
+
+func (ms *memStorage) Search(ctx context.Context, q storage.SearchQuery) (*storage.Page[storage.SearchHit], error) {
+	if err := ctxDone(ctx); err != nil {
+		return nil, err
+	}
+
+	return ms.idx.search(q), nil
+}
+
+// inverted index over contents of posts and comments
+type index struct {
+	mu sync.RWMutex
+	// Term -> DocId -> term frequency
+	postings map[string]map[uuid.UUID]int
+	// DocId -> indexed document
+	docs map[uuid.UUID]document
+}
+
+type document struct {
+	kind    storage.SearchKind
+	postId  uuid.UUID
+	content string
+	// number of terms in the content
+	length int
+}
+
+func newIndex() *index {
+	return &index{
+		postings: make(map[string]map[uuid.UUID]int),
+		docs:     make(map[uuid.UUID]document),
+	}
+}
+
+// indexes a post (or re-indexes if it's already present)
+func (idx *index) addPost(p storage.Post) {
+	idx.add(p.Id, storage.SearchPosts, p.Id, p.Content)
+}
+
+// indexes a comment (or re-indexes if it's already present)
+func (idx *index) addComment(c storage.Comment) {
+	idx.add(c.Id, storage.SearchComments, c.PostId, c.Content)
+}
+
+// indexes a post alongside its whole comment tree, skipping deleted content
+func (idx *index) addTree(pn *postNode) {
+	if pn.post.DeletedAt != nil {
+		return
+	}
+
+	idx.addPost(pn.post)
+	for _, n := range pn.comments {
+		if n.comm.DeletedAt == nil {
+			idx.addComment(n.comm)
+		}
+	}
+}
+
+// removes a post alongside its whole comment tree from the index
+func (idx *index) removeTree(pn *postNode) {
+	idx.remove(pn.post.Id)
+	for id := range pn.comments {
+		idx.remove(id)
+	}
+}
+
+func (idx *index) add(id uuid.UUID, kind storage.SearchKind, postId uuid.UUID, content string) {
+	toks := tokenize(content)
+
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	idx.drop(id)
+
+	for _, t := range toks {
+		docs, ok := idx.postings[t.term]
+		if !ok {
+			docs = make(map[uuid.UUID]int)
+			idx.postings[t.term] = docs
+		}
+		docs[id]++
+	}
+
+	idx.docs[id] = document{
+		kind:    kind,
+		postId:  postId,
+		content: content,
+		length:  len(toks),
+	}
+}
+
+func (idx *index) remove(id uuid.UUID) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	idx.drop(id)
+}
+
+// should be called with mu held
+func (idx *index) drop(id uuid.UUID) {
+	doc, ok := idx.docs[id]
+	if !ok {
+		return
+	}
+
+	for _, t := range tokenize(doc.content) {
+		docs := idx.postings[t.term]
+		delete(docs, id)
+		if len(docs) == 0 {
+			delete(idx.postings, t.term)
+		}
+	}
+
+	delete(idx.docs, id)
+}
+
+func (idx *index) search(q storage.SearchQuery) *storage.Page[storage.SearchHit] {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	terms := queryTerms(q.Query)
+	if len(terms) == 0 {
+		return &storage.Page[storage.SearchHit]{Items: []storage.SearchHit{}}
+	}
+
+	// iterating over the rarest term's postings,
+	// since every matched document should contain all of the terms
+	rarest := terms[0]
+	for _, t := range terms {
+		if len(idx.postings[t]) < len(idx.postings[rarest]) {
+			rarest = t
+		}
+	}
+
+	seq := func(yield func(storage.SearchHit) bool) {
+		for id := range idx.postings[rarest] {
+			doc := idx.docs[id]
+			if !q.Kind.Includes(doc.kind) {
+				continue
+			}
+
+			rank, ok := idx.rank(id, doc, terms)
+			if !ok {
+				continue
+			}
+
+			hit := storage.SearchHit{
+				Kind:   doc.kind,
+				Id:     id,
+				PostId: doc.postId,
+				Rank:   rank,
+			}
+
+			if q.After != nil && hit.Cursor().Compare(*q.After) <= 0 {
+				continue
+			}
+
+			if !yield(hit) {
+				return
+			}
+		}
+	}
+
+	hits := topN(seq, q.Limit+1, func(a, b storage.SearchHit) int {
+		return a.Cursor().Compare(b.Cursor())
+	})
+
+	page := toPage(hits, q.Limit)
+	for i, h := range page.Items {
+		page.Items[i].Snippet = snippet(idx.docs[h.Id].content, terms)
+	}
+
+	return page
+}
+
+// calculates term frequency relevance of a document
+// it depends on the document alone (as ts_rank does in postgres), so that ranks and, thus,
+// cursors of the other documents stay put, while the index is changed between page requests
+// returns false if the document doesn't contain all of the terms
+func (idx *index) rank(id uuid.UUID, doc document, terms []string) (float64, bool) {
+	var (
+		rank float64
+	)
+
+	for _, t := range terms {
+		tf, ok := idx.postings[t][id]
+		if !ok {
+			return 0, false
+		}
+
+		rank += float64(tf) / float64(doc.length)
+	}
+
+	return rank, true
+}
+
+type token struct {
+	// normalized form
+	term string
+	// byte offsets in the source text
+	start, end int
+}
+
+// splits text into lowercase words
+func tokenize(s string) []token {
+	var (
+		toks  []token
+		start = -1
+	)
+
+	for i, r := range s {
+		isWord := unicode.IsLetter(r) || unicode.IsDigit(r)
+
+		if isWord && start < 0 {
+			start = i
+		}
+
+		if !isWord && start >= 0 {
+			toks = append(toks, token{strings.ToLower(s[start:i]), start, i})
+			start = -1
+		}
+	}
+
+	if start >= 0 {
+		toks = append(toks, token{strings.ToLower(s[start:]), start, len(s)})
+	}
+
+	return toks
+}
+
+// returns unique terms of a query
+func queryTerms(q string) []string {
+	var (
+		terms []string
+		seen  = make(map[string]bool)
+	)
+
+	for _, t := range tokenize(q) {
+		if !seen[t.term] {
+			seen[t.term] = true
+			terms = append(terms, t.term)
+		}
+	}
+
+	return terms
+}
+
+// cuts a fragment around the first match and highlights matched terms in it
+func snippet(content string, terms []string) string {
+	var (
+		toks  = tokenize(content)
+		match = make(map[string]bool, len(terms))
+		first int
+	)
+
+	if len(toks) == 0 {
+		return ""
+	}
+
+	for _, t := range terms {
+		match[t] = true
+	}
+
+	for i, t := range toks {
+		if match[t.term] {
+			first = i
+			break
+		}
+	}
+
+	var (
+		from = max(0, first-snippetLead)
+		to   = min(len(toks), from+snippetWords)
+		sb   strings.Builder
+		pos  = toks[from].start
+	)
+
+	// content is escaped, so that the markers are the only markup in the snippet
+	for _, t := range toks[from:to] {
+		sb.WriteString(html.EscapeString(content[pos:t.start]))
+
+		if match[t.term] {
+			sb.WriteString(storage.HighlightStart)
+			sb.WriteString(html.EscapeString(content[t.start:t.end]))
+			sb.WriteString(storage.HighlightStop)
+		} else {
+			sb.WriteString(html.EscapeString(content[t.start:t.end]))
+		}
+
+		pos = t.end
+	}
+
+	return sb.String()
+}
+
+// applies fn to every comment in the tree
+func walk(comms map[uuid.UUID]storage.Comment, fn func(c storage.Comment)) {
+	for _, c := range comms {
+		fn(c)
+		walk(c.Replies, fn)
+	}
+}
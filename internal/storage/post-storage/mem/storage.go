@@ -5,48 +5,82 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"io"
+	"log"
+	"maps"
 	"os"
+	"path/filepath"
+	"slices"
 	"sync"
 	"time"
 
 	"github.com/cutlery47/posts/config"
+	"github.com/cutlery47/posts/internal/metrics"
 	storage "github.com/cutlery47/posts/internal/storage/post-storage"
 	"github.com/google/uuid"
 )
 
 type memStorage struct {
+	// held exclusively only by purge, which removes posts and comment subtrees
+	// mutations hold it shared, locking just the post they modify
 	mu *sync.RWMutex
-	// PostId -> Post
-	posts map[uuid.UUID]storage.Post
-
-	// restore src / dump dst
-	rfd, wfd *os.File
+	// PostId -> Post alongside its comments, ballots, moderation log and revisions
+	posts *postTable
+	// full-text index over posts and comments
+	idx *index
+	// posts and comments, grouped by their authors
+	authors *authorIndex
+
+	// log of mutations, made since the last snapshot (nil if persistence is disabled)
+	wal *wal
+	// last record of the wal, which is covered by the snapshot on disk
+	snapSeq uint64
+	// receives fatal persistence errors
+	errChan chan<- error
+	// serializes snapshots
+	snapMu sync.Mutex
+	// stops background dumps and purges
+	done      chan struct{}
+	closeOnce sync.Once
+	// running background workers, which are waited for on close
+	workers sync.WaitGroup
+
+	hmu sync.Mutex
+	// error of the last snapshot (nil if it has succeeded)
+	dumpErr error
 
 	conf config.PostStorage
 }
 
-func NewStorage(conf config.PostStorage, rfd, wfd *os.File, errChan chan<- error) (*memStorage, error) {
+func NewStorage(conf config.PostStorage, errChan chan<- error) (*memStorage, error) {
 	var (
 		ms = &memStorage{
-			mu:    &sync.RWMutex{},
-			posts: make(map[uuid.UUID]storage.Post),
-			conf:  conf,
+			mu:      &sync.RWMutex{},
+			posts:   newPostTable(),
+			idx:     newIndex(),
+			authors: newAuthorIndex(),
+			errChan: errChan,
+			done:    make(chan struct{}),
+			conf:    conf,
 		}
 	)
 
-	if !conf.DumpEnabled {
-		return ms, nil
-	}
+	if conf.DumpEnabled {
+		if filepath.Clean(conf.RestoreSource) != filepath.Clean(conf.DumpDestination) {
+			return nil, ErrSnapshotPath
+		}
 
-	ms.wfd = wfd
-	ms.rfd = rfd
+		if err := ms.restore(); err != nil {
+			return nil, err
+		}
 
-	if err := ms.restore(); err != nil {
-		return nil, err
+		ms.workers.Add(1)
+		go ms.dump()
 	}
 
-	go ms.dump(errChan)
+	if conf.Retention > 0 && conf.PurgeInterval > 0 {
+		ms.workers.Add(1)
+		go ms.purger()
+	}
 
 	return ms, nil
 }
@@ -56,15 +90,14 @@ func (ms *memStorage) GetPost(ctx context.Context, id uuid.UUID) (*storage.Post,
 		return nil, err
 	}
 
-	ms.mu.RLock()
-	defer ms.mu.RUnlock()
-
-	v, ok := ms.posts[id]
+	pn, ok := ms.posts.get(id)
 	if !ok {
 		return nil, storage.ErrPostNotFound
 	}
 
-	return &v, nil
+	post := pn.get()
+
+	return &post, nil
 }
 
 func (ms *memStorage) GetPosts(ctx context.Context) ([]storage.Post, error) {
@@ -72,85 +105,173 @@ func (ms *memStorage) GetPosts(ctx context.Context) ([]storage.Post, error) {
 		return nil, err
 	}
 
-	ms.mu.RLock()
-	defer ms.mu.RUnlock()
-
-	posts := make([]storage.Post, 0, len(ms.posts))
+	nodes := ms.posts.all()
+	posts := make([]storage.Post, 0, len(nodes))
 
-	for _, v := range ms.posts {
-		posts = append(posts, v)
+	for _, pn := range nodes {
+		posts = append(posts, pn.get())
 	}
 
 	return posts, nil
 }
 
-func (ms *memStorage) InsertPost(ctx context.Context, in storage.InPost) (*storage.Post, error) {
+func (ms *memStorage) GetPostsPage(ctx context.Context, q storage.PageQuery) (*storage.Page[storage.Post], error) {
 	if err := ctxDone(ctx); err != nil {
 		return nil, err
 	}
 
-	ms.mu.Lock()
-	defer ms.mu.Unlock()
+	return mapPage(selectPage(slices.Values(ms.posts.all()), q), (*postNode).get), nil
+}
+
+func (ms *memStorage) InsertPost(ctx context.Context, userId uuid.UUID, in storage.InPost) (*storage.Post, error) {
+	if err := ctxDone(ctx); err != nil {
+		return nil, err
+	}
+
+	var (
+		post storage.Post
+	)
+
+	seq, err := func() (uint64, error) {
+		ms.mu.RLock()
+		defer ms.mu.RUnlock()
+
+		if err := ms.writable(); err != nil {
+			return 0, err
+		}
+
+		pn := newPostNode(toPost(userId, in))
+
+		// locked before it's reachable, so that the post is logged ahead of its comments
+		pn.mu.Lock()
+		defer pn.mu.Unlock()
+
+		// loop until no collisions detected
+		for !ms.posts.add(pn) {
+			pn.post = toPost(userId, in)
+		}
+
+		ms.idx.addPost(pn.post)
+		ms.authors.addPost(pn)
+
+		rev := pn.revise(storage.PostRevision(pn.post, userId, pn.post.CreatedAt))
 
-	post := toPost(in)
+		post = pn.tree()
 
-	// loop until no collisions detected
-	for _, ok := ms.posts[post.Id]; ok; _, ok = ms.posts[post.Id] {
-		post = toPost(in)
+		return ms.log(postRecord(pn.post), revisionRecord(rev))
+	}()
+	if err != nil {
+		return nil, err
 	}
 
-	ms.posts[post.Id] = post
+	if err := ms.flush(seq); err != nil {
+		return nil, err
+	}
 
 	return &post, nil
 }
 
-func (ms *memStorage) DeletePost(ctx context.Context, id uuid.UUID) (*uuid.UUID, error) {
+func (ms *memStorage) DeletePost(ctx context.Context, id uuid.UUID, version *uint64) (*uuid.UUID, error) {
 	if err := ctxDone(ctx); err != nil {
 		return nil, err
 	}
 
-	ms.mu.Lock()
-	defer ms.mu.Unlock()
+	err := ms.commitPost(id, func(pn *postNode) ([]walRecord, error) {
+		if err := storage.CheckVersion(version, pn.post.Version); err != nil {
+			return nil, err
+		}
 
-	post, ok := ms.posts[id]
-	if !ok {
-		return nil, storage.ErrPostNotFound
-	}
+		if pn.post.DeletedAt != nil {
+			return nil, storage.ErrPostIsDeleted
+		}
 
-	if post.DeletedAt != nil {
-		return nil, storage.ErrPostIsDeleted
-	}
+		ts := time.Now()
 
-	ts := time.Now()
+		pn.post.DeletedAt = &ts
+		pn.post.Version++
+		ms.idx.removeTree(pn)
 
-	post.DeletedAt = &ts
-	ms.posts[id] = post
+		return []walRecord{postRecord(pn.post)}, nil
+	})
+	if err != nil {
+		return nil, err
+	}
 
 	return &id, nil
 }
 
-func (ms *memStorage) UpdatePost(ctx context.Context, id uuid.UUID, in storage.InPost) (*storage.Post, error) {
+func (ms *memStorage) UpdatePost(ctx context.Context, id uuid.UUID, in storage.InPost, version *uint64) (*storage.Post, error) {
 	if err := ctxDone(ctx); err != nil {
 		return nil, err
 	}
 
-	ms.mu.Lock()
-	defer ms.mu.Unlock()
+	var (
+		post storage.Post
+	)
 
-	post, ok := ms.posts[id]
-	if !ok {
-		return nil, storage.ErrPostNotFound
+	err := ms.commitPost(id, func(pn *postNode) ([]walRecord, error) {
+		if err := storage.CheckVersion(version, pn.post.Version); err != nil {
+			return nil, err
+		}
+
+		if pn.post.DeletedAt != nil {
+			return nil, storage.ErrPostIsDeleted
+		}
+
+		var (
+			recs   []walRecord
+			edited = pn.post.Content != in.Content
+		)
+
+		pn.post.UpdatedAt = time.Now()
+		pn.post.Content = in.Content
+		pn.post.IsMute = in.IsMute
+		pn.post.Version++
+
+		ms.idx.addPost(pn.post)
+
+		post = pn.tree()
+		recs = append(recs, postRecord(pn.post))
+
+		// only the author edits the post
+		if edited {
+			recs = append(recs, revisionRecord(pn.revise(storage.PostRevision(pn.post, pn.post.UserId, pn.post.UpdatedAt))))
+		}
+
+		return recs, nil
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	if post.DeletedAt != nil {
-		return nil, storage.ErrPostIsDeleted
+	return &post, nil
+}
+
+func (ms *memStorage) VotePost(ctx context.Context, id, userId uuid.UUID, vote storage.Vote) (*storage.Post, error) {
+	if err := ctxDone(ctx); err != nil {
+		return nil, err
 	}
 
-	post.UpdatedAt = time.Now()
-	post.Content = in.Content
-	post.IsMute = in.IsMute
+	var (
+		post storage.Post
+	)
+
+	err := ms.commitPost(id, func(pn *postNode) ([]walRecord, error) {
+		if pn.post.DeletedAt != nil {
+			return nil, storage.ErrPostIsDeleted
+		}
+
+		prev := pn.cast(id, userId, vote)
+		tally(&pn.post.Upvotes, &pn.post.Downvotes, prev, vote)
+		rankPost(&pn.post)
 
-	ms.posts[id] = post
+		post = pn.tree()
+
+		return []walRecord{ballotRecord(id, id, userId, vote), postRecord(pn.post)}, nil
+	})
+	if err != nil {
+		return nil, err
+	}
 
 	return &post, nil
 }
@@ -160,129 +281,721 @@ func (ms *memStorage) GetComment(ctx context.Context, postId, commentId uuid.UUI
 		return nil, err
 	}
 
-	ms.mu.RLock()
-	defer ms.mu.RUnlock()
-
-	post, ok := ms.posts[postId]
+	pn, ok := ms.posts.get(postId)
 	if !ok {
 		return nil, storage.ErrPostNotFound
 	}
 
-	comm, ok := getComment(post, commentId)
-	if !ok {
-		return nil, storage.ErrCommNotFound
+	pn.mu.RLock()
+	defer pn.mu.RUnlock()
+
+	n, err := pn.comment(commentId)
+	if err != nil {
+		return nil, err
 	}
 
-	return comm, nil
+	comm := n.tree()
+
+	return &comm, nil
 }
 
-func (ms *memStorage) InsertComment(ctx context.Context, postId uuid.UUID, parentId *uuid.UUID, in storage.InComment) (*storage.Comment, error) {
+func (ms *memStorage) GetCommentsPage(ctx context.Context, postId uuid.UUID, parentId *uuid.UUID, q storage.PageQuery) (*storage.Page[storage.Comment], error) {
 	if err := ctxDone(ctx); err != nil {
 		return nil, err
 	}
 
-	ms.mu.Lock()
-	defer ms.mu.Unlock()
-
-	post, ok := ms.posts[postId]
+	pn, ok := ms.posts.get(postId)
 	if !ok {
 		return nil, storage.ErrPostNotFound
 	}
 
-	// fast path
-	// entered if a given comment is on the first level of tree depth (O(1): single insertion)
+	pn.mu.RLock()
+	defer pn.mu.RUnlock()
+
 	if parentId == nil {
-		comm, err := insertComment(post, in)
+		return mapPage(selectPage(maps.Values(pn.roots), q), (*node).flat), nil
+	}
+
+	parent, err := pn.comment(*parentId)
+	if err != nil {
+		return nil, err
+	}
+
+	return mapPage(selectPage(maps.Values(parent.children), q), (*node).flat), nil
+}
+
+func (ms *memStorage) InsertComment(ctx context.Context, postId uuid.UUID, parentId *uuid.UUID, userId uuid.UUID, in storage.InComment) (*storage.Comment, error) {
+	if err := ctxDone(ctx); err != nil {
+		return nil, err
+	}
+
+	var (
+		comm storage.Comment
+	)
+
+	err := ms.commitPost(postId, func(pn *postNode) ([]walRecord, error) {
+		var (
+			parent *node
+			err    error
+		)
+
+		if parentId != nil {
+			parent, err = pn.comment(*parentId)
+			if err != nil {
+				return nil, err
+			}
+
+			if ms.conf.MaxCommentDepth > 0 && parent.comm.Depth >= ms.conf.MaxCommentDepth {
+				return nil, storage.ErrCommTooDeep
+			}
+		}
+
+		n, err := pn.insert(parent, userId, in)
 		if err != nil {
 			return nil, err
 		}
-		return comm, nil
+
+		comm = n.tree()
+		ms.idx.addComment(comm)
+		ms.authors.addComment(pn, n)
+
+		rev := pn.revise(storage.CommentRevision(comm, userId, comm.CreatedAt))
+
+		return []walRecord{commentRecord(comm), revisionRecord(rev)}, nil
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	// slow path
-	// entered if a given comment is somwhere down the tree (O(N): full dfs traversal + insertion)
-	parent, ok := getComment(post, *parentId)
-	if !ok {
-		return nil, storage.ErrCommNotFound
+	return &comm, nil
+}
+
+func (ms *memStorage) UpdateComment(ctx context.Context, postId, commentId uuid.UUID, in storage.InComment, version *uint64) (*storage.Comment, error) {
+	if err := ctxDone(ctx); err != nil {
+		return nil, err
 	}
 
-	return insertReply(*parent, in)
+	return ms.commitComment(postId, commentId, func(pn *postNode, c *storage.Comment) ([]walRecord, error) {
+		if err := storage.CheckVersion(version, c.Version); err != nil {
+			return nil, err
+		}
+
+		if c.DeletedAt != nil {
+			return nil, storage.ErrCommIsDeleted
+		}
+
+		edited := c.Content != in.Content
+
+		c.Content = in.Content
+		c.UpdatedAt = time.Now()
+		c.Version++
+
+		// comments of a deleted post are out of the index
+		if pn.post.DeletedAt == nil {
+			ms.idx.addComment(*c)
+		}
+
+		if !edited {
+			return nil, nil
+		}
+
+		// only the author edits the comment
+		return []walRecord{revisionRecord(pn.revise(storage.CommentRevision(*c, c.UserId, c.UpdatedAt)))}, nil
+	})
 }
 
-func (ms *memStorage) UpdateComment(ctx context.Context, postId, commentId uuid.UUID, in storage.InComment) (*storage.Comment, error) {
+func (ms *memStorage) DeleteComment(ctx context.Context, postId, commentId uuid.UUID, version *uint64) (*uuid.UUID, error) {
 	if err := ctxDone(ctx); err != nil {
 		return nil, err
 	}
 
-	ms.mu.Lock()
-	defer ms.mu.Unlock()
+	_, err := ms.commitComment(postId, commentId, func(pn *postNode, c *storage.Comment) ([]walRecord, error) {
+		if err := storage.CheckVersion(version, c.Version); err != nil {
+			return nil, err
+		}
 
-	post, ok := ms.posts[postId]
-	if !ok {
-		return nil, storage.ErrPostNotFound
+		if c.DeletedAt != nil {
+			return nil, storage.ErrCommIsDeleted
+		}
+
+		ts := time.Now()
+
+		c.DeletedAt = &ts
+		c.Version++
+		ms.idx.remove(c.Id)
+
+		return nil, nil
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	return updateComment(post, commentId, in)
+	return &commentId, nil
 }
 
-func (ms *memStorage) DeleteComment(ctx context.Context, postId, commentId uuid.UUID) (*uuid.UUID, error) {
+func (ms *memStorage) VoteComment(ctx context.Context, postId, commentId, userId uuid.UUID, vote storage.Vote) (*storage.Comment, error) {
 	if err := ctxDone(ctx); err != nil {
 		return nil, err
 	}
 
-	ms.mu.Lock()
-	defer ms.mu.Unlock()
+	return ms.commitComment(postId, commentId, func(pn *postNode, c *storage.Comment) ([]walRecord, error) {
+		if c.DeletedAt != nil {
+			return nil, storage.ErrCommIsDeleted
+		}
 
-	post, ok := ms.posts[postId]
-	if !ok {
-		return nil, storage.ErrPostNotFound
+		prev := pn.cast(commentId, userId, vote)
+		tally(&c.Upvotes, &c.Downvotes, prev, vote)
+		rankComment(c)
+
+		return []walRecord{ballotRecord(postId, commentId, userId, vote)}, nil
+	})
+}
+
+// applies fn to a single comment of the post and logs the comment alongside the records, returned by fn
+func (ms *memStorage) commitComment(postId, commentId uuid.UUID, fn func(pn *postNode, c *storage.Comment) ([]walRecord, error)) (*storage.Comment, error) {
+	var (
+		comm storage.Comment
+	)
+
+	err := ms.commitPost(postId, func(pn *postNode) ([]walRecord, error) {
+		var (
+			recs []walRecord
+		)
+
+		n, err := pn.mutate(commentId, func(c *storage.Comment) error {
+			var err error
+
+			recs, err = fn(pn, c)
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		comm = n.tree()
+
+		return append([]walRecord{commentRecord(n.comm)}, recs...), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &comm, nil
+}
+
+// applies mutation of a single post under its lock and logs its results
+// records are logged before the lock is released, so that their order matches the order of mutations of the post
+// returns once the mutation is durable (or right away if persistence is disabled)
+func (ms *memStorage) commitPost(id uuid.UUID, mutate func(pn *postNode) ([]walRecord, error)) error {
+	seq, err := func() (uint64, error) {
+		// post can't be purged until the mutation is logged
+		ms.mu.RLock()
+		defer ms.mu.RUnlock()
+
+		if err := ms.writable(); err != nil {
+			return 0, err
+		}
+
+		pn, ok := ms.posts.get(id)
+		if !ok {
+			return 0, storage.ErrPostNotFound
+		}
+
+		pn.mu.Lock()
+		defer pn.mu.Unlock()
+
+		recs, err := mutate(pn)
+		if err != nil {
+			return 0, err
+		}
+
+		return ms.log(recs...)
+	}()
+	if err != nil {
+		return err
+	}
+
+	return ms.flush(seq)
+}
+
+// applies mutation, which may touch any of the posts, under the exclusive lock and logs its results
+func (ms *memStorage) commit(mutate func() ([]walRecord, error)) error {
+	seq, err := func() (uint64, error) {
+		ms.mu.Lock()
+		defer ms.mu.Unlock()
+
+		if err := ms.writable(); err != nil {
+			return 0, err
+		}
+
+		recs, err := mutate()
+		if err != nil {
+			return 0, err
+		}
+
+		return ms.log(recs...)
+	}()
+	if err != nil {
+		return err
 	}
 
-	return deleteComment(post, commentId)
+	return ms.flush(seq)
 }
 
-// dump current state of the storage into given io.ReadWriter
-func (ms *memStorage) dump(errChan chan<- error) {
+// mutation can't be undone, if it fails to be logged, so it's rejected once the wal is unusable
+func (ms *memStorage) writable() error {
+	if ms.wal == nil {
+		return nil
+	}
+
+	return ms.wal.failed()
+}
+
+// appends records to the wal, returning the seq to wait for (0 if there's nothing to wait for)
+func (ms *memStorage) log(recs ...walRecord) (uint64, error) {
+	if ms.wal == nil || len(recs) == 0 {
+		return 0, nil
+	}
+
+	return ms.wal.append(recs...)
+}
+
+// blocks until the logged records are durable
+func (ms *memStorage) flush(seq uint64) error {
+	if seq == 0 {
+		return nil
+	}
+
+	if err := ms.wal.wait(seq); err != nil {
+		ms.fail(fmt.Errorf("%v: %v", ErrBadWal, err))
+		return err
+	}
+
+	return nil
+}
+
+// reports fatal persistence error without blocking
+func (ms *memStorage) fail(err error) {
+	select {
+	case ms.errChan <- err:
+	default:
+	}
+}
+
+// periodically writes compacted snapshots of the storage
+// failed snapshots are retried with backoff, until too many of them fail in a row
+func (ms *memStorage) dump() {
+	defer ms.workers.Done()
+
+	var (
+		failures int
+	)
+
 	for {
-		time.Sleep(ms.conf.DumpInterval)
+		select {
+		case <-time.After(ms.dumpDelay(failures)):
+		case <-ms.done:
+			return
+		}
 
-		err := func() error {
-			ms.mu.Lock()
-			defer ms.mu.Unlock()
+		err := ms.snapshot()
 
-			// clear prev contents
-			if err := ms.wfd.Truncate(0); err != nil {
-				return err
-			}
+		if err == nil {
+			failures = 0
+		} else {
+			failures++
+			log.Printf("[DUMP] snapshot failed (%v in a row): %v", failures, err)
+		}
 
-			// move pointer to the beginning
-			if _, err := ms.wfd.Seek(0, 0); err != nil {
-				return err
-			}
+		ms.setDumpErr(err)
+		metrics.ObserveDump(failures, err)
 
-			// flush storage state
-			err := json.NewEncoder(ms.wfd).Encode(ms.posts)
-			if err != nil {
-				return err
-			}
+		if err != nil && ms.conf.DumpMaxFailures > 0 && failures >= ms.conf.DumpMaxFailures {
+			ms.fail(fmt.Errorf("%v: %v", ErrBadDump, err))
+			return
+		}
+	}
+}
+
+// returns delay before the next snapshot: retry delay is doubled on every failure, up to the dump interval
+func (ms *memStorage) dumpDelay(failures int) time.Duration {
+	if failures == 0 {
+		return ms.conf.DumpInterval
+	}
+
+	delay := ms.conf.DumpRetryDelay
+	for i := 1; i < failures && delay < ms.conf.DumpInterval; i++ {
+		delay *= 2
+	}
+
+	return min(delay, ms.conf.DumpInterval)
+}
+
+func (ms *memStorage) setDumpErr(err error) {
+	ms.hmu.Lock()
+	defer ms.hmu.Unlock()
+
+	ms.dumpErr = err
+}
+
+// storage is not ready if the wal is broken or the last snapshot has failed
+func (ms *memStorage) Ready(ctx context.Context) error {
+	if err := ctxDone(ctx); err != nil {
+		return err
+	}
+
+	if ms.wal == nil {
+		return nil
+	}
+
+	if err := ms.wal.failed(); errors.Is(err, ErrClosed) {
+		return err
+	} else if err != nil {
+		return fmt.Errorf("%v: %v", ErrBadWal, err)
+	}
+
+	ms.hmu.Lock()
+	defer ms.hmu.Unlock()
+
+	if ms.dumpErr != nil {
+		return fmt.Errorf("%v: %v", ErrBadDump, ms.dumpErr)
+	}
+
+	return nil
+}
+
+// stops background workers, writes the final snapshot and closes the wal
+// mutations fail once the storage is closed
+func (ms *memStorage) Close() error {
+	ms.closeOnce.Do(func() {
+		close(ms.done)
+	})
+
+	// running dump or purge is finished before the final snapshot is taken
+	ms.workers.Wait()
 
-			return nil
-		}()
+	if ms.wal == nil {
+		return nil
+	}
+
+	// the wal still covers the state, even if the snapshot fails
+	if err := ms.snapshot(); err != nil {
+		ms.wal.close()
+		return fmt.Errorf("%v: %v", ErrBadDump, err)
+	}
+
+	return ms.wal.close()
+}
+
+// writes current state of the storage and drops the part of the wal, which it covers
+// writers aren't blocked: posts are copied one at a time, each under its own lock
+func (ms *memStorage) snapshot() error {
+	// concurrent snapshots (background and the final one) would race on compaction
+	ms.snapMu.Lock()
+	defer ms.snapMu.Unlock()
 
+	if ms.wal.last() == ms.snapSeq {
+		return nil
+	}
+
+	snap, err := func() (snapshot, error) {
+		// purge is held off, so that replay never meets records of the content, which is missing from the snapshot
+		ms.mu.RLock()
+		defer ms.mu.RUnlock()
+
+		// records up to seq are already applied, since records are logged after their mutations
+		seq, err := ms.wal.rotate()
 		if err != nil {
-			errChan <- fmt.Errorf("%v: %v", ErrBadDump, err)
-			break
+			return snapshot{}, err
+		}
+
+		snap := ms.capture()
+		snap.Seq = seq
+
+		return snap, nil
+	}()
+	if err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(snap)
+	if err != nil {
+		return err
+	}
+
+	if err := writeFileAtomic(ms.conf.DumpDestination, data); err != nil {
+		return err
+	}
+
+	ms.snapSeq = snap.Seq
+
+	return ms.wal.compact(snap.Seq)
+}
+
+// copies state of the storage, locking a single post at a time
+// copy may include mutations, logged after the last record it's marked with:
+// those are simply applied again on restore, since every record holds the resulting state
+func (ms *memStorage) capture() snapshot {
+	snap := snapshot{
+		Format:     snapshotFormat,
+		Posts:      make(map[uuid.UUID]storage.Post),
+		Ballots:    make(map[uuid.UUID]map[uuid.UUID]storage.Vote),
+		Moderation: make(map[uuid.UUID][]storage.ModEntry),
+		Revisions:  make(map[uuid.UUID][]storage.Revision),
+	}
+
+	for _, pn := range ms.posts.all() {
+		pn.mu.RLock()
+
+		snap.Posts[pn.post.Id] = pn.tree()
+
+		for id, ballots := range pn.ballots {
+			snap.Ballots[id] = maps.Clone(ballots)
 		}
+
+		if len(pn.modlog) > 0 {
+			snap.Moderation[pn.post.Id] = slices.Clone(pn.modlog)
+		}
+
+		for id, revs := range pn.revisions {
+			snap.Revisions[id] = slices.Clone(revs)
+		}
+
+		pn.mu.RUnlock()
 	}
 
+	return snap
 }
 
-// restores last state of the storage from given io.ReadWriter
+// restores last state of the storage from the snapshot and replays the wal on top of it
 func (ms *memStorage) restore() error {
-	err := json.NewDecoder(ms.rfd).Decode(&ms.posts)
-	if err != nil && !errors.Is(err, io.EOF) {
+	var (
+		snap snapshot
+	)
+
+	data, err := os.ReadFile(ms.conf.RestoreSource)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
 		return fmt.Errorf("%v: %v", ErrBadRestore, err)
 	}
 
+	if len(data) > 0 {
+		if snap, err = decodeSnapshot(data); err != nil {
+			return fmt.Errorf("%v: %v", ErrBadRestore, err)
+		}
+	}
+
+	if err := ms.load(snap); err != nil {
+		return fmt.Errorf("%v: %v", ErrBadRestore, err)
+	}
+
+	seq, err := readWal(ms.conf.WALDestination, snap.Seq, ms.apply)
+	if err != nil {
+		return fmt.Errorf("%v: %v", ErrBadRestore, err)
+	}
+
+	ms.snapSeq = snap.Seq
+
+	ms.wal, err = openWal(ms.conf.WALDestination, ms.conf.WALSyncInterval, seq)
+	if err != nil {
+		return fmt.Errorf("%v: %v", ErrBadRestore, err)
+	}
+
+	for _, pn := range ms.posts.all() {
+		ms.idx.addTree(pn)
+		ms.authors.addTree(pn)
+	}
+
+	// snapshots of the current format already hold revisions of every item
+	if snap.Format < revisionsFormat {
+		if err := ms.backfill(); err != nil {
+			return fmt.Errorf("%v: %v", ErrBadRestore, err)
+		}
+	}
+
+	return nil
+}
+
+// records current content of posts and comments, which were stored before revisions were introduced,
+// as their first revision
+func (ms *memStorage) backfill() error {
+	var (
+		recs []walRecord
+	)
+
+	for _, pn := range ms.posts.all() {
+		if _, ok := pn.revisions[pn.post.Id]; !ok {
+			rev := pn.revise(storage.PostRevision(pn.post, pn.post.UserId, pn.post.UpdatedAt))
+			recs = append(recs, revisionRecord(rev))
+		}
+
+		for id, n := range pn.comments {
+			if _, ok := pn.revisions[id]; !ok {
+				rev := pn.revise(storage.CommentRevision(n.comm, n.comm.UserId, n.comm.UpdatedAt))
+				recs = append(recs, revisionRecord(rev))
+			}
+		}
+	}
+
+	seq, err := ms.log(recs...)
+	if err != nil {
+		return err
+	}
+
+	return ms.flush(seq)
+}
+
+// fills the storage with the snapshot contents
+// not concurrent-safe by itself!
+func (ms *memStorage) load(snap snapshot) error {
+	var (
+		// PostId / CommentId -> Post
+		owners = make(map[uuid.UUID]*postNode)
+	)
+
+	for _, p := range snap.Posts {
+		pn, err := loadPost(p)
+		if err != nil {
+			return err
+		}
+
+		ms.posts.add(pn)
+
+		owners[pn.post.Id] = pn
+		for id := range pn.comments {
+			owners[id] = pn
+		}
+	}
+
+	for id, ballots := range snap.Ballots {
+		if pn, ok := owners[id]; ok {
+			pn.ballots[id] = ballots
+		}
+	}
+
+	for id, entries := range snap.Moderation {
+		if pn, ok := owners[id]; ok {
+			pn.modlog = entries
+		}
+	}
+
+	for id, revs := range snap.Revisions {
+		if pn, ok := owners[id]; ok {
+			for _, r := range revs {
+				pn.revise(r)
+			}
+		}
+	}
+
+	return nil
+}
+
+// version of the snapshot layout, written by this build
+// 0 stands for snapshots, written before the layout was versioned
+const snapshotFormat = 1
+
+// first format, whose snapshots are known to hold revisions of every post and comment
+// older ones get the revisions of items, which were stored without any, on restore
+const revisionsFormat = 1
+
+// on-disk representation of the storage state
+type snapshot struct {
+	Format int `json:"format"`
+	// last wal record, which is reflected in the snapshot
+	Seq        uint64                                   `json:"seq"`
+	Posts      map[uuid.UUID]storage.Post               `json:"posts"`
+	Ballots    map[uuid.UUID]map[uuid.UUID]storage.Vote `json:"ballots"`
+	Moderation map[uuid.UUID][]storage.ModEntry         `json:"moderation"`
+	// PostId / CommentId -> revisions, oldest first
+	Revisions map[uuid.UUID][]storage.Revision `json:"revisions"`
+}
+
+// decodes the snapshot, including the legacy dumps, which hold nothing but PostId -> Post map
+// unknown layouts are rejected, so that existing data is never overwritten by an empty state
+func decodeSnapshot(data []byte) (snapshot, error) {
+	var (
+		snap   snapshot
+		fields map[string]json.RawMessage
+	)
+
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return snap, err
+	}
+
+	if posts, ok := fields["posts"]; ok {
+		if err := json.Unmarshal(data, &snap); err != nil {
+			return snap, err
+		}
+
+		if snap.Format > snapshotFormat {
+			return snap, fmt.Errorf("%v: %v", ErrSnapshotFormat, snap.Format)
+		}
+
+		if snap.Format == 0 {
+			return snap, legacyAuthors(posts, snap.Posts)
+		}
+
+		return snap, nil
+	}
+
+	for key := range fields {
+		if _, err := uuid.Parse(key); err != nil {
+			return snap, ErrSnapshotFormat
+		}
+	}
+
+	if err := json.Unmarshal(data, &snap.Posts); err != nil {
+		return snap, err
+	}
+
+	return snap, legacyAuthors(data, snap.Posts)
+}
+
+// authors of posts and comments, as they were dumped before they left the input-bound fields
+type legacyPost struct {
+	InPost struct {
+		UserId uuid.UUID `json:"user_id"`
+	} `json:"in_post"`
+	Comments map[uuid.UUID]legacyComment `json:"comments"`
+}
+
+type legacyComment struct {
+	InComment struct {
+		UserId uuid.UUID `json:"user_id"`
+	} `json:"in_comment"`
+	Replies map[uuid.UUID]legacyComment `json:"replies"`
+}
+
+// fills authors, which are missing from the posts and comments, out of their legacy fields
+func legacyAuthors(data []byte, posts map[uuid.UUID]storage.Post) error {
+	var (
+		legacy map[uuid.UUID]legacyPost
+	)
+
+	if err := json.Unmarshal(data, &legacy); err != nil {
+		return err
+	}
+
+	var fill func(comms map[uuid.UUID]storage.Comment, legacy map[uuid.UUID]legacyComment)
+
+	fill = func(comms map[uuid.UUID]storage.Comment, legacy map[uuid.UUID]legacyComment) {
+		for id, c := range comms {
+			if c.UserId == uuid.Nil {
+				c.UserId = legacy[id].InComment.UserId
+			}
+
+			fill(c.Replies, legacy[id].Replies)
+			comms[id] = c
+		}
+	}
+
+	for id, p := range posts {
+		if p.UserId == uuid.Nil {
+			p.UserId = legacy[id].InPost.UserId
+		}
+
+		fill(p.Comments, legacy[id].Comments)
+		posts[id] = p
+	}
+
 	return nil
 }
@@ -5,6 +5,7 @@ import (
 	"errors"
 	"io"
 	"testing"
+	"time"
 
 	"github.com/cutlery47/posts/config"
 	storage "github.com/cutlery47/posts/internal/storage/post-storage"
@@ -26,15 +27,14 @@ var (
 func TestStorageInsertPost(t *testing.T) {
 	ctx := context.Background()
 
-	store, _ = mem.NewStorage(conf, nil, nil, nil)
+	store, _ = mem.NewStorage(conf, nil)
 
 	in := storage.InPost{
-		UserId:  uuid.New(),
 		IsMute:  false,
 		Content: "content",
 	}
 
-	_, err := store.InsertPost(ctx, in)
+	_, err := store.InsertPost(ctx, uuid.New(), in)
 	if err != nil {
 		t.Fatalf("error: %v", err)
 	}
@@ -43,15 +43,14 @@ func TestStorageInsertPost(t *testing.T) {
 func TestStorageGetPost(t *testing.T) {
 	ctx := context.Background()
 
-	store, _ = mem.NewStorage(conf, nil, nil, nil)
+	store, _ = mem.NewStorage(conf, nil)
 
 	in := storage.InPost{
-		UserId:  uuid.New(),
 		IsMute:  false,
 		Content: "content",
 	}
 
-	post, err := store.InsertPost(ctx, in)
+	post, err := store.InsertPost(ctx, uuid.New(), in)
 	if err != nil {
 		t.Fatalf("error: %v", err)
 	}
@@ -69,9 +68,9 @@ func TestStorageGetPost(t *testing.T) {
 func TestStorageGetNonexistantPost(t *testing.T) {
 	ctx := context.Background()
 
-	store, _ = mem.NewStorage(conf, nil, nil, nil)
+	store, _ = mem.NewStorage(conf, nil)
 
-	_, err := store.InsertPost(ctx, storage.InPost{})
+	_, err := store.InsertPost(ctx, uuid.New(), storage.InPost{})
 	if err != nil {
 		t.Fatalf("error: %v", err)
 	}
@@ -85,26 +84,24 @@ func TestStorageGetNonexistantPost(t *testing.T) {
 func TestStorageGetPosts(t *testing.T) {
 	ctx := context.Background()
 
-	store, _ = mem.NewStorage(conf, nil, nil, nil)
+	store, _ = mem.NewStorage(conf, nil)
 
 	in1 := storage.InPost{
-		UserId:  uuid.New(),
 		IsMute:  false,
 		Content: "content1",
 	}
 
 	in2 := storage.InPost{
-		UserId:  uuid.New(),
 		IsMute:  true,
 		Content: "content2",
 	}
 
-	post1, err := store.InsertPost(ctx, in1)
+	post1, err := store.InsertPost(ctx, uuid.New(), in1)
 	if err != nil {
 		t.Fatalf("error: %v", err)
 	}
 
-	post2, err := store.InsertPost(ctx, in2)
+	post2, err := store.InsertPost(ctx, uuid.New(), in2)
 	if err != nil {
 		t.Fatalf("error: %v", err)
 	}
@@ -126,14 +123,14 @@ func TestStorageGetPosts(t *testing.T) {
 func TestStorageDeletePost(t *testing.T) {
 	ctx := context.Background()
 
-	store, _ = mem.NewStorage(conf, nil, nil, nil)
+	store, _ = mem.NewStorage(conf, nil)
 
-	post, err := store.InsertPost(ctx, storage.InPost{})
+	post, err := store.InsertPost(ctx, uuid.New(), storage.InPost{})
 	if err != nil {
 		t.Fatalf("error: %v", err)
 	}
 
-	_, err = store.DeletePost(ctx, post.Id)
+	_, err = store.DeletePost(ctx, post.Id, nil)
 	if err != nil {
 		t.Fatalf("error: %v", err)
 	}
@@ -151,14 +148,14 @@ func TestStorageDeletePost(t *testing.T) {
 func TestStorageDeleteNonexistantPost(t *testing.T) {
 	ctx := context.Background()
 
-	store, _ = mem.NewStorage(conf, nil, nil, nil)
+	store, _ = mem.NewStorage(conf, nil)
 
-	_, err := store.InsertPost(ctx, storage.InPost{})
+	_, err := store.InsertPost(ctx, uuid.New(), storage.InPost{})
 	if err != nil {
 		t.Fatalf("error: %v", err)
 	}
 
-	_, err = store.DeletePost(ctx, uuid.New())
+	_, err = store.DeletePost(ctx, uuid.New(), nil)
 	if !errors.Is(err, storage.ErrPostNotFound) {
 		t.Fatalf("error: %v", err)
 	}
@@ -167,49 +164,196 @@ func TestStorageDeleteNonexistantPost(t *testing.T) {
 func TestStorageDeleteDeletedPost(t *testing.T) {
 	ctx := context.Background()
 
-	store, _ = mem.NewStorage(conf, nil, nil, nil)
+	store, _ = mem.NewStorage(conf, nil)
 
-	post, err := store.InsertPost(ctx, storage.InPost{})
+	post, err := store.InsertPost(ctx, uuid.New(), storage.InPost{})
 	if err != nil {
 		t.Fatalf("error: %v", err)
 	}
 
-	_, err = store.DeletePost(ctx, post.Id)
+	_, err = store.DeletePost(ctx, post.Id, nil)
 	if err != nil {
 		t.Fatalf("error: %v", err)
 	}
 
-	_, err = store.DeletePost(ctx, post.Id)
+	_, err = store.DeletePost(ctx, post.Id, nil)
 	if !errors.Is(err, storage.ErrPostIsDeleted) {
 		t.Fatalf("managed to delete deleted post: %v", err)
 	}
 }
 
+func TestStorageRestorePost(t *testing.T) {
+	ctx := context.Background()
+
+	store, _ = mem.NewStorage(conf, nil)
+
+	post, err := store.InsertPost(ctx, uuid.New(), storage.InPost{})
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	_, err = store.RestorePost(ctx, post.Id)
+	if !errors.Is(err, storage.ErrPostNotDeleted) {
+		t.Fatalf("error: %v", err)
+	}
+
+	_, err = store.DeletePost(ctx, post.Id, nil)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	rPost, err := store.RestorePost(ctx, post.Id)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	if rPost.DeletedAt != nil {
+		t.Fatalf("post wasn't restored")
+	}
+}
+
+func TestStorageGetTrash(t *testing.T) {
+	ctx := context.Background()
+
+	store, _ = mem.NewStorage(conf, nil)
+
+	id := uuid.New()
+
+	post, err := store.InsertPost(ctx, id, storage.InPost{})
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	comm, err := store.InsertComment(ctx, post.Id, nil, id, storage.InComment{})
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	// someone else's deleted comment
+	other, err := store.InsertComment(ctx, post.Id, nil, uuid.New(), storage.InComment{})
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	for _, c := range []uuid.UUID{comm.Id, other.Id} {
+		_, err = store.DeleteComment(ctx, post.Id, c, nil)
+		if err != nil {
+			t.Fatalf("error: %v", err)
+		}
+	}
+
+	_, err = store.DeletePost(ctx, post.Id, nil)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	trash, err := store.GetTrash(ctx, id)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	if len(trash.Posts) != 1 || trash.Posts[0].Id != post.Id {
+		t.Fatalf("unexpected trashed posts: %v", trash.Posts)
+	}
+
+	if len(trash.Comments) != 1 || trash.Comments[0].Id != comm.Id {
+		t.Fatalf("unexpected trashed comments: %v", trash.Comments)
+	}
+}
+
+func TestStoragePurge(t *testing.T) {
+	ctx := context.Background()
+
+	store, _ = mem.NewStorage(conf, nil)
+
+	post, err := store.InsertPost(ctx, uuid.New(), storage.InPost{Content: "post"})
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	comm, err := store.InsertComment(ctx, post.Id, nil, uuid.New(), storage.InComment{Content: "comment"})
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	_, err = store.InsertComment(ctx, post.Id, &comm.Id, uuid.New(), storage.InComment{Content: "reply"})
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	_, err = store.DeleteComment(ctx, post.Id, comm.Id, nil)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	// nothing has expired yet
+	stats, err := store.Purge(ctx, time.Now().Add(-time.Hour))
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	if *stats != (storage.PurgeStats{}) {
+		t.Fatalf("unexpected stats: %v", stats)
+	}
+
+	// deleted comment is purged alongside its replies
+	stats, err = store.Purge(ctx, time.Now())
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	if *stats != (storage.PurgeStats{Comments: 2, Bytes: int64(len("comment") + len("reply"))}) {
+		t.Fatalf("unexpected stats: %v", stats)
+	}
+
+	_, err = store.GetComment(ctx, post.Id, comm.Id)
+	if !errors.Is(err, storage.ErrCommNotFound) {
+		t.Fatalf("error: %v", err)
+	}
+
+	_, err = store.DeletePost(ctx, post.Id, nil)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	stats, err = store.Purge(ctx, time.Now())
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	if stats.Posts != 1 {
+		t.Fatalf("unexpected stats: %v", stats)
+	}
+
+	_, err = store.GetPost(ctx, post.Id)
+	if !errors.Is(err, storage.ErrPostNotFound) {
+		t.Fatalf("error: %v", err)
+	}
+}
+
 func TestStorageUpdatePost(t *testing.T) {
 	ctx := context.Background()
 
-	store, _ = mem.NewStorage(conf, nil, nil, nil)
+	store, _ = mem.NewStorage(conf, nil)
 
 	id := uuid.New()
 
 	in := storage.InPost{
-		UserId:  id,
 		IsMute:  false,
 		Content: "content",
 	}
 
-	post, err := store.InsertPost(ctx, in)
+	post, err := store.InsertPost(ctx, id, in)
 	if err != nil {
 		t.Fatalf("error: %v", err)
 	}
 
 	inUpd := storage.InPost{
-		UserId:  id,
 		IsMute:  true,
 		Content: "skibidi",
 	}
 
-	upd, err := store.UpdatePost(ctx, post.Id, inUpd)
+	upd, err := store.UpdatePost(ctx, post.Id, inUpd, nil)
 	if err != nil {
 		t.Fatalf("error: %v", err)
 	}
@@ -217,19 +361,23 @@ func TestStorageUpdatePost(t *testing.T) {
 	if upd.InPost != inUpd {
 		t.Fatalf("updates didn't persist")
 	}
+
+	if upd.UserId != id {
+		t.Fatalf("author has changed")
+	}
 }
 
 func TestStorageUpdateNonexistantPost(t *testing.T) {
 	ctx := context.Background()
 
-	store, _ = mem.NewStorage(conf, nil, nil, nil)
+	store, _ = mem.NewStorage(conf, nil)
 
-	_, err := store.InsertPost(ctx, storage.InPost{})
+	_, err := store.InsertPost(ctx, uuid.New(), storage.InPost{})
 	if err != nil {
 		t.Fatalf("error: %v", err)
 	}
 
-	_, err = store.UpdatePost(ctx, uuid.New(), storage.InPost{})
+	_, err = store.UpdatePost(ctx, uuid.New(), storage.InPost{}, nil)
 	if !errors.Is(err, storage.ErrPostNotFound) {
 		t.Fatalf("error: %v", err)
 	}
@@ -238,46 +386,212 @@ func TestStorageUpdateNonexistantPost(t *testing.T) {
 func TestStorageUpdateDeletedPost(t *testing.T) {
 	ctx := context.Background()
 
-	store, _ = mem.NewStorage(conf, nil, nil, nil)
+	store, _ = mem.NewStorage(conf, nil)
 
-	post, err := store.InsertPost(ctx, storage.InPost{})
+	post, err := store.InsertPost(ctx, uuid.New(), storage.InPost{})
 	if err != nil {
 		t.Fatalf("error: %v", err)
 	}
 
-	_, err = store.DeletePost(ctx, post.Id)
+	_, err = store.DeletePost(ctx, post.Id, nil)
 	if err != nil {
 		t.Fatalf("error: %v", err)
 	}
 
-	_, err = store.UpdatePost(ctx, post.Id, storage.InPost{})
+	_, err = store.UpdatePost(ctx, post.Id, storage.InPost{}, nil)
 	if !errors.Is(err, storage.ErrPostIsDeleted) {
 		t.Fatalf("managed to update deleted post: %v", err)
 	}
 }
 
+func TestStoragePostVersion(t *testing.T) {
+	ctx := context.Background()
+
+	store, _ = mem.NewStorage(conf, nil)
+
+	post, err := store.InsertPost(ctx, uuid.New(), storage.InPost{Content: "content"})
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	if post.Version != 1 {
+		t.Fatalf("wrong version: %v", post.Version)
+	}
+
+	upd, err := store.UpdatePost(ctx, post.Id, storage.InPost{Content: "first"}, &post.Version)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	if upd.Version != 2 {
+		t.Fatalf("wrong version: %v", upd.Version)
+	}
+
+	// votes don't conflict with edits
+	if _, err := store.VotePost(ctx, post.Id, uuid.New(), storage.VoteUp); err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	// change, based on the outdated version, is rejected
+	_, err = store.UpdatePost(ctx, post.Id, storage.InPost{Content: "second"}, &post.Version)
+
+	var conflict *storage.ConflictError
+	if !errors.As(err, &conflict) || !errors.Is(err, storage.ErrConflict) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if conflict.Expected != 1 || conflict.Actual != 2 {
+		t.Fatalf("wrong conflict: %v", conflict)
+	}
+
+	_, err = store.DeletePost(ctx, post.Id, &post.Version)
+	if !errors.Is(err, storage.ErrConflict) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if _, err := store.DeletePost(ctx, post.Id, &upd.Version); err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	got, err := store.GetPost(ctx, post.Id)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	if got.Content != "first" || got.Version != 3 {
+		t.Fatalf("wrong post: %v", got)
+	}
+}
+
+func TestStorageModeratePost(t *testing.T) {
+	ctx := context.Background()
+
+	store, _ = mem.NewStorage(conf, nil)
+
+	post, err := store.InsertPost(ctx, uuid.New(), storage.InPost{})
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	e := storage.ModEntry{
+		PostId:  post.Id,
+		ActorId: uuid.New(),
+		Action:  storage.ModLock,
+		Reason:  "flame",
+	}
+
+	upd, err := store.ModeratePost(ctx, e, nil)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	if !upd.IsLocked {
+		t.Fatalf("post wasn't locked")
+	}
+
+	_, err = store.InsertComment(ctx, post.Id, nil, uuid.New(), storage.InComment{})
+	if !errors.Is(err, storage.ErrPostIsLocked) {
+		t.Fatalf("error: %v", err)
+	}
+
+	e.Action = storage.ModRestore
+
+	_, err = store.ModeratePost(ctx, e, nil)
+	if !errors.Is(err, storage.ErrPostNotDeleted) {
+		t.Fatalf("error: %v", err)
+	}
+
+	log, err := store.GetModerationLog(ctx, post.Id)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	// failed actions are not recorded
+	if len(log) != 1 || log[0].Action != storage.ModLock {
+		t.Fatalf("unexpected log: %v", log)
+	}
+}
+
+func TestStorageModerateComment(t *testing.T) {
+	ctx := context.Background()
+
+	store, _ = mem.NewStorage(conf, nil)
+
+	post, err := store.InsertPost(ctx, uuid.New(), storage.InPost{})
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	comm, err := store.InsertComment(ctx, post.Id, nil, uuid.New(), storage.InComment{})
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	e := storage.ModEntry{
+		PostId:    post.Id,
+		CommentId: &comm.Id,
+		ActorId:   uuid.New(),
+		Action:    storage.ModDelete,
+		Reason:    "spam",
+	}
+
+	upd, err := store.ModerateComment(ctx, e, nil)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	if upd.DeletedAt == nil {
+		t.Fatalf("comment wasn't deleted")
+	}
+
+	e.Action = storage.ModRestore
+
+	upd, err = store.ModerateComment(ctx, e, nil)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	if upd.DeletedAt != nil {
+		t.Fatalf("comment wasn't restored")
+	}
+
+	e.Action = storage.ModLock
+
+	_, err = store.ModerateComment(ctx, e, nil)
+	if !errors.Is(err, storage.ErrBadModAction) {
+		t.Fatalf("error: %v", err)
+	}
+
+	log, err := store.GetModerationLog(ctx, post.Id)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	if len(log) != 2 {
+		t.Fatalf("unexpected log: %v", log)
+	}
+}
+
 func TestStorageInsertComment(t *testing.T) {
 	ctx := context.Background()
 
-	store, _ = mem.NewStorage(conf, nil, nil, nil)
+	store, _ = mem.NewStorage(conf, nil)
 
 	inPost := storage.InPost{
-		UserId:  uuid.New(),
 		IsMute:  false,
 		Content: "content",
 	}
 
-	post, err := store.InsertPost(ctx, inPost)
+	post, err := store.InsertPost(ctx, uuid.New(), inPost)
 	if err != nil {
 		t.Fatalf("error: %v", err)
 	}
 
 	inComm := storage.InComment{
-		UserId:  uuid.New(),
 		Content: "content",
 	}
 
-	comm, err := store.InsertComment(ctx, post.Id, nil, inComm)
+	comm, err := store.InsertComment(ctx, post.Id, nil, uuid.New(), inComm)
 	if err != nil {
 		t.Fatalf("error: %v", err)
 	}
@@ -299,14 +613,14 @@ func TestStorageInsertComment(t *testing.T) {
 func TestStorageInsertCommentIntoNonexistantPost(t *testing.T) {
 	ctx := context.Background()
 
-	store, _ = mem.NewStorage(conf, nil, nil, nil)
+	store, _ = mem.NewStorage(conf, nil)
 
-	_, err := store.InsertPost(ctx, storage.InPost{})
+	_, err := store.InsertPost(ctx, uuid.New(), storage.InPost{})
 	if err != nil {
 		t.Fatalf("error: %v", err)
 	}
 
-	_, err = store.InsertComment(ctx, uuid.New(), nil, storage.InComment{})
+	_, err = store.InsertComment(ctx, uuid.New(), nil, uuid.New(), storage.InComment{})
 	if !errors.Is(err, storage.ErrPostNotFound) {
 		t.Fatalf("error: %v", err)
 	}
@@ -315,24 +629,23 @@ func TestStorageInsertCommentIntoNonexistantPost(t *testing.T) {
 func TestStorageInsertReply(t *testing.T) {
 	ctx := context.Background()
 
-	store, _ = mem.NewStorage(conf, nil, nil, nil)
+	store, _ = mem.NewStorage(conf, nil)
 
-	post, err := store.InsertPost(ctx, storage.InPost{})
+	post, err := store.InsertPost(ctx, uuid.New(), storage.InPost{})
 	if err != nil {
 		t.Fatalf("error: %v", err)
 	}
 
-	comm, err := store.InsertComment(ctx, post.Id, nil, storage.InComment{})
+	comm, err := store.InsertComment(ctx, post.Id, nil, uuid.New(), storage.InComment{})
 	if err != nil {
 		t.Fatalf("error: %v", err)
 	}
 
 	inRepl := storage.InComment{
-		UserId:  uuid.New(),
 		Content: "content",
 	}
 
-	repl, err := store.InsertComment(ctx, post.Id, &comm.Id, inRepl)
+	repl, err := store.InsertComment(ctx, post.Id, &comm.Id, uuid.New(), inRepl)
 	if err != nil {
 		t.Fatalf("error: %v", err)
 	}
@@ -345,21 +658,21 @@ func TestStorageInsertReply(t *testing.T) {
 func TestStorageInsertReplyIntoNonexistantComment(t *testing.T) {
 	ctx := context.Background()
 
-	store, _ = mem.NewStorage(conf, nil, nil, nil)
+	store, _ = mem.NewStorage(conf, nil)
 
-	post, err := store.InsertPost(ctx, storage.InPost{})
+	post, err := store.InsertPost(ctx, uuid.New(), storage.InPost{})
 	if err != nil {
 		t.Fatalf("error: %v", err)
 	}
 
-	_, err = store.InsertComment(ctx, post.Id, nil, storage.InComment{})
+	_, err = store.InsertComment(ctx, post.Id, nil, uuid.New(), storage.InComment{})
 	if err != nil {
 		t.Fatalf("error: %v", err)
 	}
 
 	rnd := uuid.New()
 
-	_, err = store.InsertComment(ctx, post.Id, &rnd, storage.InComment{})
+	_, err = store.InsertComment(ctx, post.Id, &rnd, uuid.New(), storage.InComment{})
 	if !(errors.Is(err, storage.ErrCommNotFound)) {
 		t.Fatalf("error: %v", err)
 	}
@@ -368,19 +681,19 @@ func TestStorageInsertReplyIntoNonexistantComment(t *testing.T) {
 func TestStorageDeleteComment(t *testing.T) {
 	ctx := context.Background()
 
-	store, _ = mem.NewStorage(conf, nil, nil, nil)
+	store, _ = mem.NewStorage(conf, nil)
 
-	post, err := store.InsertPost(ctx, storage.InPost{})
+	post, err := store.InsertPost(ctx, uuid.New(), storage.InPost{})
 	if err != nil {
 		t.Fatalf("error: %v", err)
 	}
 
-	comm, err := store.InsertComment(ctx, post.Id, nil, storage.InComment{})
+	comm, err := store.InsertComment(ctx, post.Id, nil, uuid.New(), storage.InComment{})
 	if err != nil {
 		t.Fatalf("error: %v", err)
 	}
 
-	_, err = store.DeleteComment(ctx, post.Id, comm.Id)
+	_, err = store.DeleteComment(ctx, post.Id, comm.Id, nil)
 	if err != nil {
 		t.Fatalf("error: %v", err)
 	}
@@ -389,45 +702,71 @@ func TestStorageDeleteComment(t *testing.T) {
 func TestStorageDeleteReply(t *testing.T) {
 	ctx := context.Background()
 
-	store, _ = mem.NewStorage(conf, nil, nil, nil)
+	store, _ = mem.NewStorage(conf, nil)
+
+	post, err := store.InsertPost(ctx, uuid.New(), storage.InPost{})
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	comm, err := store.InsertComment(ctx, post.Id, nil, uuid.New(), storage.InComment{})
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	repl, err := store.InsertComment(ctx, post.Id, &comm.Id, uuid.New(), storage.InComment{})
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
 
-	post, err := store.InsertPost(ctx, storage.InPost{})
+	_, err = store.DeleteComment(ctx, post.Id, repl.Id, nil)
 	if err != nil {
 		t.Fatalf("error: %v", err)
 	}
+}
+
+func TestStorageDeleteDeletedComment(t *testing.T) {
+	ctx := context.Background()
 
-	comm, err := store.InsertComment(ctx, post.Id, nil, storage.InComment{})
+	store, _ = mem.NewStorage(conf, nil)
+
+	post, err := store.InsertPost(ctx, uuid.New(), storage.InPost{})
 	if err != nil {
 		t.Fatalf("error: %v", err)
 	}
 
-	repl, err := store.InsertComment(ctx, post.Id, &comm.Id, storage.InComment{})
+	comm, err := store.InsertComment(ctx, post.Id, nil, uuid.New(), storage.InComment{})
 	if err != nil {
 		t.Fatalf("error: %v", err)
 	}
 
-	_, err = store.DeleteComment(ctx, post.Id, repl.Id)
+	_, err = store.DeleteComment(ctx, post.Id, comm.Id, nil)
 	if err != nil {
 		t.Fatalf("error: %v", err)
 	}
+
+	_, err = store.DeleteComment(ctx, post.Id, comm.Id, nil)
+	if !errors.Is(err, storage.ErrCommIsDeleted) {
+		t.Fatalf("managed to delete deleted comment: %v", err)
+	}
 }
 
 func TestStorageDeleteCommentInNonexistantPost(t *testing.T) {
 	ctx := context.Background()
 
-	store, _ = mem.NewStorage(conf, nil, nil, nil)
+	store, _ = mem.NewStorage(conf, nil)
 
-	post, err := store.InsertPost(ctx, storage.InPost{})
+	post, err := store.InsertPost(ctx, uuid.New(), storage.InPost{})
 	if err != nil {
 		t.Fatalf("error: %v", err)
 	}
 
-	comm, err := store.InsertComment(ctx, post.Id, nil, storage.InComment{})
+	comm, err := store.InsertComment(ctx, post.Id, nil, uuid.New(), storage.InComment{})
 	if err != nil {
 		t.Fatalf("error: %v", err)
 	}
 
-	_, err = store.DeleteComment(ctx, uuid.New(), comm.Id)
+	_, err = store.DeleteComment(ctx, uuid.New(), comm.Id, nil)
 	if !errors.Is(err, storage.ErrPostNotFound) {
 		t.Fatalf("error: %v", err)
 	}
@@ -436,9 +775,9 @@ func TestStorageDeleteCommentInNonexistantPost(t *testing.T) {
 func TestStorageUpdateComment(t *testing.T) {
 	ctx := context.Background()
 
-	store, _ = mem.NewStorage(conf, nil, nil, nil)
+	store, _ = mem.NewStorage(conf, nil)
 
-	post, err := store.InsertPost(ctx, storage.InPost{})
+	post, err := store.InsertPost(ctx, uuid.New(), storage.InPost{})
 	if err != nil {
 		t.Fatalf("error: %v", err)
 	}
@@ -446,21 +785,19 @@ func TestStorageUpdateComment(t *testing.T) {
 	id := uuid.New()
 
 	inComm := storage.InComment{
-		UserId:  id,
 		Content: "content",
 	}
 
-	comm, err := store.InsertComment(ctx, post.Id, nil, inComm)
+	comm, err := store.InsertComment(ctx, post.Id, nil, id, inComm)
 	if err != nil {
 		t.Fatalf("error: %v", err)
 	}
 
 	inUpd := storage.InComment{
-		UserId:  id,
 		Content: "123123123",
 	}
 
-	upd, err := store.UpdateComment(ctx, post.Id, comm.Id, inUpd)
+	upd, err := store.UpdateComment(ctx, post.Id, comm.Id, inUpd, nil)
 	if err != nil {
 		t.Fatalf("error: %v", err)
 	}
@@ -470,22 +807,48 @@ func TestStorageUpdateComment(t *testing.T) {
 	}
 }
 
+func TestStorageUpdateDeletedComment(t *testing.T) {
+	ctx := context.Background()
+
+	store, _ = mem.NewStorage(conf, nil)
+
+	post, err := store.InsertPost(ctx, uuid.New(), storage.InPost{})
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	comm, err := store.InsertComment(ctx, post.Id, nil, uuid.New(), storage.InComment{Content: "content"})
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	_, err = store.DeleteComment(ctx, post.Id, comm.Id, nil)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	_, err = store.UpdateComment(ctx, post.Id, comm.Id, storage.InComment{Content: "edited"}, nil)
+	if !errors.Is(err, storage.ErrCommIsDeleted) {
+		t.Fatalf("managed to update deleted comment: %v", err)
+	}
+}
+
 func TestStorageUpdateCommentInNonexistantPost(t *testing.T) {
 	ctx := context.Background()
 
-	store, _ = mem.NewStorage(conf, nil, nil, nil)
+	store, _ = mem.NewStorage(conf, nil)
 
-	post, err := store.InsertPost(ctx, storage.InPost{})
+	post, err := store.InsertPost(ctx, uuid.New(), storage.InPost{})
 	if err != nil {
 		t.Fatalf("error: %v", err)
 	}
 
-	comm, err := store.InsertComment(ctx, post.Id, nil, storage.InComment{})
+	comm, err := store.InsertComment(ctx, post.Id, nil, uuid.New(), storage.InComment{})
 	if err != nil {
 		t.Fatalf("error: %v", err)
 	}
 
-	_, err = store.UpdateComment(ctx, uuid.New(), comm.Id, storage.InComment{})
+	_, err = store.UpdateComment(ctx, uuid.New(), comm.Id, storage.InComment{}, nil)
 	if !errors.Is(err, storage.ErrPostNotFound) {
 		t.Fatalf("error: %v", err)
 	}
@@ -494,14 +857,14 @@ func TestStorageUpdateCommentInNonexistantPost(t *testing.T) {
 func TestStorageUpdateReply(t *testing.T) {
 	ctx := context.Background()
 
-	store, _ = mem.NewStorage(conf, nil, nil, nil)
+	store, _ = mem.NewStorage(conf, nil)
 
-	post, err := store.InsertPost(ctx, storage.InPost{})
+	post, err := store.InsertPost(ctx, uuid.New(), storage.InPost{})
 	if err != nil {
 		t.Fatalf("error: %v", err)
 	}
 
-	comm, err := store.InsertComment(ctx, post.Id, nil, storage.InComment{})
+	comm, err := store.InsertComment(ctx, post.Id, nil, uuid.New(), storage.InComment{})
 	if err != nil {
 		t.Fatalf("error: %v", err)
 	}
@@ -509,21 +872,19 @@ func TestStorageUpdateReply(t *testing.T) {
 	id := uuid.New()
 
 	inRepl := storage.InComment{
-		UserId:  id,
 		Content: "content",
 	}
 
-	repl, err := store.InsertComment(ctx, post.Id, &comm.Id, inRepl)
+	repl, err := store.InsertComment(ctx, post.Id, &comm.Id, id, inRepl)
 	if err != nil {
 		t.Fatalf("error: %v", err)
 	}
 
 	inUpd := storage.InComment{
-		UserId:  id,
 		Content: "skibidi",
 	}
 
-	upd, err := store.UpdateComment(ctx, post.Id, repl.Id, inUpd)
+	upd, err := store.UpdateComment(ctx, post.Id, repl.Id, inUpd, nil)
 	if err != nil {
 		t.Fatalf("error: %v", err)
 	}
@@ -532,3 +893,1018 @@ func TestStorageUpdateReply(t *testing.T) {
 		t.Fatalf("updates didn't persist")
 	}
 }
+
+func TestStorageCommentVersion(t *testing.T) {
+	ctx := context.Background()
+
+	store, _ = mem.NewStorage(conf, nil)
+
+	post, err := store.InsertPost(ctx, uuid.New(), storage.InPost{})
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	comm, err := store.InsertComment(ctx, post.Id, nil, uuid.New(), storage.InComment{Content: "content"})
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	// replies don't change the comment itself
+	if _, err := store.InsertComment(ctx, post.Id, &comm.Id, uuid.New(), storage.InComment{}); err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	upd, err := store.UpdateComment(ctx, post.Id, comm.Id, storage.InComment{Content: "edited"}, &comm.Version)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	if upd.Version != comm.Version+1 {
+		t.Fatalf("wrong version: %v", upd.Version)
+	}
+
+	_, err = store.UpdateComment(ctx, post.Id, comm.Id, storage.InComment{Content: "lost"}, &comm.Version)
+	if !errors.Is(err, storage.ErrConflict) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	e := storage.ModEntry{PostId: post.Id, CommentId: &comm.Id, ActorId: uuid.New(), Action: storage.ModPin, Reason: "reason"}
+
+	_, err = store.ModerateComment(ctx, e, &comm.Version)
+	if !errors.Is(err, storage.ErrConflict) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	pinned, err := store.ModerateComment(ctx, e, &upd.Version)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	_, err = store.DeleteComment(ctx, post.Id, comm.Id, &upd.Version)
+	if !errors.Is(err, storage.ErrConflict) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if _, err := store.DeleteComment(ctx, post.Id, comm.Id, &pinned.Version); err != nil {
+		t.Fatalf("error: %v", err)
+	}
+}
+
+func TestStorageNestedReplies(t *testing.T) {
+	ctx := context.Background()
+
+	store, _ = mem.NewStorage(conf, nil)
+
+	post, err := store.InsertPost(ctx, uuid.New(), storage.InPost{})
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	var (
+		chain    []uuid.UUID
+		parentId *uuid.UUID
+	)
+
+	for range 4 {
+		comm, err := store.InsertComment(ctx, post.Id, parentId, uuid.New(), storage.InComment{})
+		if err != nil {
+			t.Fatalf("error: %v", err)
+		}
+
+		chain = append(chain, comm.Id)
+		parentId = &comm.Id
+	}
+
+	deepest := chain[len(chain)-1]
+
+	if _, err := store.UpdateComment(ctx, post.Id, deepest, storage.InComment{Content: "edited"}, nil); err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	if _, err := store.VoteComment(ctx, post.Id, deepest, uuid.New(), storage.VoteUp); err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	if _, err := store.DeleteComment(ctx, post.Id, chain[1], nil); err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	gPost, err := store.GetPost(ctx, post.Id)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	var (
+		level = gPost.Comments
+		comm  storage.Comment
+	)
+
+	for i, id := range chain {
+		var ok bool
+
+		comm, ok = level[id]
+		if !ok {
+			t.Fatalf("comment on level %v is missing", i)
+		}
+
+		if (i == 1) != (comm.DeletedAt != nil) {
+			t.Fatalf("deletion of level %v is wrong", i)
+		}
+
+		level = comm.Replies
+	}
+
+	if comm.Content != "edited" || comm.Upvotes != 1 {
+		t.Fatalf("updates of the deepest reply didn't persist")
+	}
+
+	other, err := store.InsertPost(ctx, uuid.New(), storage.InPost{})
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	if _, err := store.GetComment(ctx, other.Id, deepest); !errors.Is(err, storage.ErrCommNotFound) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestStorageCommentDepth(t *testing.T) {
+	ctx := context.Background()
+
+	store, _ = mem.NewStorage(config.PostStorage{MaxCommentDepth: 3}, nil)
+
+	post, err := store.InsertPost(ctx, uuid.New(), storage.InPost{})
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	var (
+		parentId *uuid.UUID
+		root     uuid.UUID
+	)
+
+	for i := range 3 {
+		comm, err := store.InsertComment(ctx, post.Id, parentId, uuid.New(), storage.InComment{})
+		if err != nil {
+			t.Fatalf("error: %v", err)
+		}
+
+		if comm.Depth != i+1 {
+			t.Fatalf("wrong depth on level %v: %v", i, comm.Depth)
+		}
+
+		if i == 0 {
+			root = comm.Id
+		}
+		parentId = &comm.Id
+	}
+
+	_, err = store.InsertComment(ctx, post.Id, parentId, uuid.New(), storage.InComment{})
+	if !errors.Is(err, storage.ErrCommTooDeep) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if _, err := store.InsertComment(ctx, post.Id, &root, uuid.New(), storage.InComment{}); err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	gComm, err := store.GetComment(ctx, post.Id, root)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	if gComm.ReplyCount != 2 {
+		t.Fatalf("wrong reply count: %v", gComm.ReplyCount)
+	}
+}
+
+func TestStorageVotePost(t *testing.T) {
+	ctx := context.Background()
+
+	store, _ = mem.NewStorage(conf, nil)
+
+	post, err := store.InsertPost(ctx, uuid.New(), storage.InPost{})
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	user1, user2 := uuid.New(), uuid.New()
+
+	// repeated votes shouldn't inflate counters
+	for range 2 {
+		_, err = store.VotePost(ctx, post.Id, user1, storage.VoteUp)
+		if err != nil {
+			t.Fatalf("error: %v", err)
+		}
+	}
+
+	voted, err := store.VotePost(ctx, post.Id, user2, storage.VoteDown)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	if voted.Upvotes != 1 || voted.Downvotes != 1 {
+		t.Fatalf("wrong tally: %v up, %v down", voted.Upvotes, voted.Downvotes)
+	}
+
+	// flip
+	voted, err = store.VotePost(ctx, post.Id, user1, storage.VoteDown)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	if voted.Upvotes != 0 || voted.Downvotes != 2 {
+		t.Fatalf("wrong tally after flip: %v up, %v down", voted.Upvotes, voted.Downvotes)
+	}
+
+	// retract
+	voted, err = store.VotePost(ctx, post.Id, user2, storage.VoteNone)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	if voted.Upvotes != 0 || voted.Downvotes != 1 {
+		t.Fatalf("wrong tally after retraction: %v up, %v down", voted.Upvotes, voted.Downvotes)
+	}
+}
+
+func TestStorageVoteDeletedPost(t *testing.T) {
+	ctx := context.Background()
+
+	store, _ = mem.NewStorage(conf, nil)
+
+	post, err := store.InsertPost(ctx, uuid.New(), storage.InPost{})
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	_, err = store.DeletePost(ctx, post.Id, nil)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	_, err = store.VotePost(ctx, post.Id, uuid.New(), storage.VoteUp)
+	if !errors.Is(err, storage.ErrPostIsDeleted) {
+		t.Fatalf("managed to vote on deleted post: %v", err)
+	}
+}
+
+func TestStorageVoteReply(t *testing.T) {
+	ctx := context.Background()
+
+	store, _ = mem.NewStorage(conf, nil)
+
+	post, err := store.InsertPost(ctx, uuid.New(), storage.InPost{})
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	comm, err := store.InsertComment(ctx, post.Id, nil, uuid.New(), storage.InComment{})
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	repl, err := store.InsertComment(ctx, post.Id, &comm.Id, uuid.New(), storage.InComment{})
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	_, err = store.VoteComment(ctx, post.Id, repl.Id, uuid.New(), storage.VoteUp)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	got, err := store.GetComment(ctx, post.Id, repl.Id)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	if got.Upvotes != 1 {
+		t.Fatalf("vote didn't persist")
+	}
+}
+
+func TestStorageGetPostsPage(t *testing.T) {
+	ctx := context.Background()
+
+	store, _ = mem.NewStorage(conf, nil)
+
+	for range 5 {
+		_, err := store.InsertPost(ctx, uuid.New(), storage.InPost{})
+		if err != nil {
+			t.Fatalf("error: %v", err)
+		}
+	}
+
+	var (
+		q = storage.PageQuery{
+			First:  2,
+			SortBy: storage.SortNewest,
+		}
+
+		seen  = make(map[uuid.UUID]bool)
+		pages int
+	)
+
+	for {
+		page, err := store.GetPostsPage(ctx, q)
+		if err != nil {
+			t.Fatalf("error: %v", err)
+		}
+		pages++
+
+		for i, v := range page.Items {
+			if seen[v.Id] {
+				t.Fatalf("post was returned twice")
+			}
+			seen[v.Id] = true
+
+			if i > 0 && page.Items[i-1].CreatedAt.Before(v.CreatedAt) {
+				t.Fatalf("wrong order")
+			}
+		}
+
+		if !page.HasNextPage {
+			break
+		}
+
+		after := page.Items[len(page.Items)-1].Cursor()
+		q.After = &after
+	}
+
+	if len(seen) != 5 || pages != 3 {
+		t.Fatalf("wrong pagination: %v posts in %v pages", len(seen), pages)
+	}
+}
+
+func TestStorageGetCommentsPage(t *testing.T) {
+	ctx := context.Background()
+
+	store, _ = mem.NewStorage(conf, nil)
+
+	post, err := store.InsertPost(ctx, uuid.New(), storage.InPost{})
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	comm, err := store.InsertComment(ctx, post.Id, nil, uuid.New(), storage.InComment{})
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	for range 3 {
+		_, err := store.InsertComment(ctx, post.Id, &comm.Id, uuid.New(), storage.InComment{})
+		if err != nil {
+			t.Fatalf("error: %v", err)
+		}
+	}
+
+	q := storage.PageQuery{
+		First:  2,
+		SortBy: storage.SortOldest,
+	}
+
+	top, err := store.GetCommentsPage(ctx, post.Id, nil, q)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	if len(top.Items) != 1 || top.HasNextPage {
+		t.Fatalf("wrong top-level page")
+	}
+
+	// replies are counted, but left for their own pages
+	if top.Items[0].ReplyCount != 3 || len(top.Items[0].Replies) != 0 {
+		t.Fatalf("wrong replies of a paginated comment")
+	}
+
+	repls, err := store.GetCommentsPage(ctx, post.Id, &comm.Id, q)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	if len(repls.Items) != 2 || !repls.HasNextPage {
+		t.Fatalf("wrong reply page")
+	}
+
+	rnd := uuid.New()
+
+	_, err = store.GetCommentsPage(ctx, post.Id, &rnd, q)
+	if !errors.Is(err, storage.ErrCommNotFound) {
+		t.Fatalf("error: %v", err)
+	}
+}
+
+func TestStorageGetCommentsPageTop(t *testing.T) {
+	ctx := context.Background()
+
+	store, _ = mem.NewStorage(conf, nil)
+
+	post, err := store.InsertPost(ctx, uuid.New(), storage.InPost{})
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	var (
+		// upvotes / downvotes of each comment
+		votes = [][2]int{{1, 3}, {2, 0}, {3, 2}}
+		ids   []uuid.UUID
+	)
+
+	for _, v := range votes {
+		comm, err := store.InsertComment(ctx, post.Id, nil, uuid.New(), storage.InComment{})
+		if err != nil {
+			t.Fatalf("error: %v", err)
+		}
+
+		for range v[0] {
+			if _, err := store.VoteComment(ctx, post.Id, comm.Id, uuid.New(), storage.VoteUp); err != nil {
+				t.Fatalf("error: %v", err)
+			}
+		}
+
+		for range v[1] {
+			if _, err := store.VoteComment(ctx, post.Id, comm.Id, uuid.New(), storage.VoteDown); err != nil {
+				t.Fatalf("error: %v", err)
+			}
+		}
+
+		ids = append(ids, comm.Id)
+	}
+
+	q := storage.PageQuery{
+		First:  2,
+		SortBy: storage.SortTop,
+	}
+
+	page, err := store.GetCommentsPage(ctx, post.Id, nil, q)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	if len(page.Items) != 2 || !page.HasNextPage || page.Items[0].Id != ids[1] || page.Items[1].Id != ids[2] {
+		t.Fatalf("wrong first page")
+	}
+
+	after := page.Items[1].Cursor()
+	q.After = &after
+
+	page, err = store.GetCommentsPage(ctx, post.Id, nil, q)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	if len(page.Items) != 1 || page.HasNextPage || page.Items[0].Id != ids[0] {
+		t.Fatalf("wrong last page")
+	}
+}
+
+func TestStorageGetPostsPageRanked(t *testing.T) {
+	ctx := context.Background()
+
+	store, _ = mem.NewStorage(conf, nil)
+
+	var (
+		// upvotes / downvotes of each post
+		votes = [][2]int{{10, 0}, {5, 5}, {1, 0}, {0, 3}}
+		ids   []uuid.UUID
+	)
+
+	for _, v := range votes {
+		post, err := store.InsertPost(ctx, uuid.New(), storage.InPost{})
+		if err != nil {
+			t.Fatalf("error: %v", err)
+		}
+
+		for range v[0] {
+			if _, err := store.VotePost(ctx, post.Id, uuid.New(), storage.VoteUp); err != nil {
+				t.Fatalf("error: %v", err)
+			}
+		}
+
+		for range v[1] {
+			if _, err := store.VotePost(ctx, post.Id, uuid.New(), storage.VoteDown); err != nil {
+				t.Fatalf("error: %v", err)
+			}
+		}
+
+		ids = append(ids, post.Id)
+	}
+
+	// indexes of the posts, expected to be placed first and last
+	tests := map[storage.SortKey][2]int{
+		storage.SortHot:           {0, 3},
+		storage.SortBest:          {0, 3},
+		storage.SortControversial: {1, -1},
+		storage.SortDownvotes:     {1, -1},
+	}
+
+	for sortBy, want := range tests {
+		page, err := store.GetPostsPage(ctx, storage.PageQuery{First: len(votes), SortBy: sortBy})
+		if err != nil {
+			t.Fatalf("error: %v", err)
+		}
+
+		if len(page.Items) != len(votes) || page.HasNextPage {
+			t.Fatalf("wrong %v page", sortBy)
+		}
+
+		if page.Items[0].Id != ids[want[0]] {
+			t.Fatalf("wrong first post by %v", sortBy)
+		}
+
+		if want[1] != -1 && page.Items[len(page.Items)-1].Id != ids[want[1]] {
+			t.Fatalf("wrong last post by %v", sortBy)
+		}
+	}
+
+	page, err := store.GetPostsPage(ctx, storage.PageQuery{First: len(votes), SortBy: storage.SortTop, Window: storage.WindowDay})
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	if len(page.Items) != len(votes) || page.Items[0].Id != ids[0] {
+		t.Fatalf("wrong top page within a day")
+	}
+}
+
+func TestStorageGetByAuthor(t *testing.T) {
+	ctx := context.Background()
+
+	store, _ = mem.NewStorage(conf, nil)
+
+	var (
+		author = uuid.New()
+		other  = uuid.New()
+		posts  []uuid.UUID
+	)
+
+	for range 3 {
+		post, err := store.InsertPost(ctx, author, storage.InPost{})
+		if err != nil {
+			t.Fatalf("error: %v", err)
+		}
+		posts = append(posts, post.Id)
+	}
+
+	if _, err := store.InsertPost(ctx, other, storage.InPost{}); err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	comm, err := store.InsertComment(ctx, posts[0], nil, other, storage.InComment{})
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	repl, err := store.InsertComment(ctx, posts[0], &comm.Id, author, storage.InComment{})
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	if _, err := store.DeletePost(ctx, posts[2], nil); err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	q := storage.PageQuery{
+		First:  1,
+		SortBy: storage.SortOldest,
+	}
+
+	page, err := store.GetPostsByAuthor(ctx, author, q)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	if len(page.Items) != 1 || !page.HasNextPage || page.Items[0].Id != posts[0] {
+		t.Fatalf("wrong first page of posts")
+	}
+
+	after := page.Items[0].Cursor()
+	q.After = &after
+
+	page, err = store.GetPostsByAuthor(ctx, author, q)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	// deleted post is left out
+	if len(page.Items) != 1 || page.HasNextPage || page.Items[0].Id != posts[1] {
+		t.Fatalf("wrong last page of posts")
+	}
+
+	comms, err := store.GetCommentsByAuthor(ctx, author, storage.PageQuery{First: 10, SortBy: storage.SortNewest})
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	if len(comms.Items) != 1 || comms.Items[0].Id != repl.Id {
+		t.Fatalf("wrong comments of the author")
+	}
+}
+
+func TestStorageGetKarma(t *testing.T) {
+	ctx := context.Background()
+
+	store, _ = mem.NewStorage(conf, nil)
+
+	author := uuid.New()
+
+	post, err := store.InsertPost(ctx, author, storage.InPost{})
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	comm, err := store.InsertComment(ctx, post.Id, nil, author, storage.InComment{})
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	for range 3 {
+		if _, err := store.VotePost(ctx, post.Id, uuid.New(), storage.VoteUp); err != nil {
+			t.Fatalf("error: %v", err)
+		}
+	}
+
+	for range 2 {
+		if _, err := store.VoteComment(ctx, post.Id, comm.Id, uuid.New(), storage.VoteDown); err != nil {
+			t.Fatalf("error: %v", err)
+		}
+	}
+
+	karma, err := store.GetKarma(ctx, author)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	if karma != 1 {
+		t.Fatalf("wrong karma: %v", karma)
+	}
+
+	if _, err := store.DeleteComment(ctx, post.Id, comm.Id, nil); err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	karma, err = store.GetKarma(ctx, author)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	// votes of deleted comments don't count
+	if karma != 3 {
+		t.Fatalf("wrong karma: %v", karma)
+	}
+}
+
+func TestStorageRevertPost(t *testing.T) {
+	ctx := context.Background()
+
+	store, _ = mem.NewStorage(conf, nil)
+
+	author, admin := uuid.New(), uuid.New()
+
+	post, err := store.InsertPost(ctx, author, storage.InPost{Content: "first"})
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	if _, err := store.UpdatePost(ctx, post.Id, storage.InPost{Content: "second"}, nil); err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	// edits, which leave content intact, aren't recorded
+	if _, err := store.UpdatePost(ctx, post.Id, storage.InPost{Content: "second", IsMute: true}, nil); err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	revs, err := store.GetRevisions(ctx, post.Id, nil)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	if len(revs) != 2 || revs[0].Content != "first" || revs[1].Content != "second" {
+		t.Fatalf("wrong revisions: %v", revs)
+	}
+
+	upd, err := store.RevertPost(ctx, post.Id, revs[0].Id, admin)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	if upd.Content != "first" || !upd.IsMute {
+		t.Fatalf("wrong post: %v", upd)
+	}
+
+	revs, err = store.GetRevisions(ctx, post.Id, nil)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	// revert is recorded as a revision of its own
+	if len(revs) != 3 || revs[2].Content != "first" || revs[2].EditorId != admin {
+		t.Fatalf("wrong revisions: %v", revs)
+	}
+
+	_, err = store.RevertPost(ctx, post.Id, uuid.New(), author)
+	if !errors.Is(err, storage.ErrRevNotFound) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestStorageRevertComment(t *testing.T) {
+	ctx := context.Background()
+
+	store, _ = mem.NewStorage(conf, nil)
+
+	author := uuid.New()
+
+	post, err := store.InsertPost(ctx, uuid.New(), storage.InPost{})
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	comm, err := store.InsertComment(ctx, post.Id, nil, author, storage.InComment{Content: "first"})
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	if _, err := store.UpdateComment(ctx, post.Id, comm.Id, storage.InComment{Content: "second"}, nil); err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	revs, err := store.GetRevisions(ctx, post.Id, &comm.Id)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	if len(revs) != 2 || revs[0].Content != "first" || revs[0].EditorId != author {
+		t.Fatalf("wrong revisions: %v", revs)
+	}
+
+	upd, err := store.RevertComment(ctx, post.Id, comm.Id, revs[0].Id, author)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	if upd.Content != "first" {
+		t.Fatalf("wrong comment: %v", upd)
+	}
+
+	// revisions of the post itself are kept apart
+	_, err = store.RevertComment(ctx, post.Id, comm.Id, revs[0].Id, author)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	postRevs, err := store.GetRevisions(ctx, post.Id, nil)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	_, err = store.RevertComment(ctx, post.Id, comm.Id, postRevs[0].Id, author)
+	if !errors.Is(err, storage.ErrRevNotFound) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if _, err := store.DeleteComment(ctx, post.Id, comm.Id, nil); err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	_, err = store.RevertComment(ctx, post.Id, comm.Id, revs[0].Id, author)
+	if !errors.Is(err, storage.ErrCommIsDeleted) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestStorageSearch(t *testing.T) {
+	ctx := context.Background()
+
+	store, _ = mem.NewStorage(conf, nil)
+
+	post1, err := store.InsertPost(ctx, uuid.New(), storage.InPost{Content: "the quick brown fox"})
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	post2, err := store.InsertPost(ctx, uuid.New(), storage.InPost{Content: "Fox! Fox! Brown fox!"})
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	comm, err := store.InsertComment(ctx, post1.Id, nil, uuid.New(), storage.InComment{Content: "not a fox, but a dog"})
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	q := storage.SearchQuery{
+		Query: "brown FOX",
+		Kind:  storage.SearchAll,
+		Limit: 10,
+	}
+
+	res, err := store.Search(ctx, q)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	if len(res.Items) != 2 {
+		t.Fatalf("wrong number of hits: %v", len(res.Items))
+	}
+
+	if res.Items[0].Id != post2.Id {
+		t.Fatalf("wrong ranking")
+	}
+
+	if res.Items[1].Snippet != "the quick <b>brown</b> <b>fox</b>" {
+		t.Fatalf("wrong snippet: %v", res.Items[1].Snippet)
+	}
+
+	q.Query = "fox"
+	q.Kind = storage.SearchComments
+
+	res, err = store.Search(ctx, q)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	if len(res.Items) != 1 || res.Items[0].Id != comm.Id || res.Items[0].PostId != post1.Id {
+		t.Fatalf("wrong comment hits")
+	}
+}
+
+func TestStorageSearchCursor(t *testing.T) {
+	ctx := context.Background()
+
+	store, _ = mem.NewStorage(conf, nil)
+
+	contents := []string{"fox", "fox fox dog", "fox dog dog", "fox dog dog dog"}
+	for _, c := range contents {
+		if _, err := store.InsertPost(ctx, uuid.New(), storage.InPost{Content: c}); err != nil {
+			t.Fatalf("error: %v", err)
+		}
+	}
+
+	q := storage.SearchQuery{
+		Query: "fox",
+		Kind:  storage.SearchAll,
+		Limit: 2,
+	}
+
+	first, err := store.Search(ctx, q)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	// documents, which are added between the pages, don't shift the cursor
+	for range 10 {
+		if _, err := store.InsertPost(ctx, uuid.New(), storage.InPost{Content: "cat"}); err != nil {
+			t.Fatalf("error: %v", err)
+		}
+	}
+
+	cursor := first.Items[len(first.Items)-1].Cursor()
+	q.After = &cursor
+
+	second, err := store.Search(ctx, q)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	seen := make(map[uuid.UUID]bool)
+	for _, h := range append(first.Items, second.Items...) {
+		if seen[h.Id] {
+			t.Fatalf("hit %v is repeated", h.Id)
+		}
+		seen[h.Id] = true
+	}
+
+	if len(seen) != len(contents) {
+		t.Fatalf("got %v hits, expected %v", len(seen), len(contents))
+	}
+}
+
+func TestStorageSearchEscape(t *testing.T) {
+	ctx := context.Background()
+
+	store, _ = mem.NewStorage(conf, nil)
+
+	_, err := store.InsertPost(ctx, uuid.New(), storage.InPost{Content: `<script>alert("fox")</script> & <b>fox</b>`})
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	res, err := store.Search(ctx, storage.SearchQuery{Query: "fox", Kind: storage.SearchAll, Limit: 10})
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	if len(res.Items) != 1 {
+		t.Fatalf("wrong number of hits: %v", len(res.Items))
+	}
+
+	// markers are the only markup in the snippet
+	expected := `script&gt;alert(&#34;<b>fox</b>&#34;)&lt;/script&gt; &amp; &lt;b&gt;<b>fox</b>&lt;/b`
+	if res.Items[0].Snippet != expected {
+		t.Fatalf("wrong snippet: %v", res.Items[0].Snippet)
+	}
+}
+
+func TestStorageSearchReindex(t *testing.T) {
+	ctx := context.Background()
+
+	store, _ = mem.NewStorage(conf, nil)
+
+	post, err := store.InsertPost(ctx, uuid.New(), storage.InPost{Content: "old content"})
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	comm, err := store.InsertComment(ctx, post.Id, nil, uuid.New(), storage.InComment{Content: "old comment"})
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	_, err = store.UpdatePost(ctx, post.Id, storage.InPost{Content: "new content"}, nil)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	_, err = store.DeleteComment(ctx, post.Id, comm.Id, nil)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	q := storage.SearchQuery{
+		Query: "old",
+		Kind:  storage.SearchAll,
+		Limit: 10,
+	}
+
+	res, err := store.Search(ctx, q)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	if len(res.Items) != 0 {
+		t.Fatalf("stale content was found")
+	}
+
+	q.Query = "new"
+
+	res, err = store.Search(ctx, q)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	if len(res.Items) != 1 {
+		t.Fatalf("updated content wasn't found")
+	}
+
+	_, err = store.DeletePost(ctx, post.Id, nil)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	res, err = store.Search(ctx, q)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	if len(res.Items) != 0 {
+		t.Fatalf("deleted content was found")
+	}
+}
+
+func TestStorageSearchUpdateInDeletedPost(t *testing.T) {
+	ctx := context.Background()
+
+	store, _ = mem.NewStorage(conf, nil)
+
+	post, err := store.InsertPost(ctx, uuid.New(), storage.InPost{Content: "post"})
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	comm, err := store.InsertComment(ctx, post.Id, nil, uuid.New(), storage.InComment{Content: "old comment"})
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	_, err = store.DeletePost(ctx, post.Id, nil)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	_, err = store.UpdateComment(ctx, post.Id, comm.Id, storage.InComment{Content: "new comment"}, nil)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	res, err := store.Search(ctx, storage.SearchQuery{Query: "new", Kind: storage.SearchAll, Limit: 10})
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	if len(res.Items) != 0 {
+		t.Fatalf("comment of deleted post was found")
+	}
+}
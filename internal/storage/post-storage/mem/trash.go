@@ -0,0 +1,94 @@
+package mem
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	storage "github.com/cutlery47/posts/internal/storage/post-storage"
+	"github.com/google/uuid"
+)
+
+func (ms *memStorage) RestorePost(ctx context.Context, id uuid.UUID) (*storage.Post, error) {
+	if err := ctxDone(ctx); err != nil {
+		return nil, err
+	}
+
+	var (
+		post storage.Post
+	)
+
+	err := ms.commitPost(id, func(pn *postNode) ([]walRecord, error) {
+		if err := pn.post.Moderate(storage.ModRestore, time.Now()); err != nil {
+			return nil, err
+		}
+
+		ms.idx.addTree(pn)
+
+		post = pn.tree()
+
+		return []walRecord{postRecord(pn.post)}, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &post, nil
+}
+
+func (ms *memStorage) RestoreComment(ctx context.Context, postId, commentId uuid.UUID) (*storage.Comment, error) {
+	if err := ctxDone(ctx); err != nil {
+		return nil, err
+	}
+
+	return ms.commitComment(postId, commentId, func(pn *postNode, c *storage.Comment) ([]walRecord, error) {
+		if err := c.Moderate(storage.ModRestore, time.Now()); err != nil {
+			return nil, err
+		}
+
+		if pn.post.DeletedAt == nil {
+			ms.idx.addComment(*c)
+		}
+
+		return nil, nil
+	})
+}
+
+func (ms *memStorage) GetTrash(ctx context.Context, userId uuid.UUID) (*storage.Trash, error) {
+	if err := ctxDone(ctx); err != nil {
+		return nil, err
+	}
+
+	var (
+		trash = &storage.Trash{
+			Posts:    []storage.Post{},
+			Comments: []storage.Comment{},
+		}
+	)
+
+	for _, pn := range ms.posts.all() {
+		pn.mu.RLock()
+
+		if pn.post.UserId == userId && pn.post.DeletedAt != nil {
+			trash.Posts = append(trash.Posts, pn.tree())
+		}
+
+		for _, n := range pn.comments {
+			if n.comm.UserId == userId && n.comm.DeletedAt != nil {
+				trash.Comments = append(trash.Comments, n.tree())
+			}
+		}
+
+		pn.mu.RUnlock()
+	}
+
+	slices.SortFunc(trash.Posts, func(a, b storage.Post) int {
+		return b.DeletedAt.Compare(*a.DeletedAt)
+	})
+
+	slices.SortFunc(trash.Comments, func(a, b storage.Comment) int {
+		return b.DeletedAt.Compare(*a.DeletedAt)
+	})
+
+	return trash, nil
+}
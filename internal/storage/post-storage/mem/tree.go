@@ -0,0 +1,433 @@
+package mem
+
+import (
+	"sync"
+
+	storage "github.com/cutlery47/posts/internal/storage/post-storage"
+	"github.com/google/uuid"
+)
+
+// number of shards in the post table (power of two)
+const shardCount = 64
+
+// PostId -> Post alongside its comments
+// posts are spread over shards, so that inserts don't contend with each other
+type postTable struct {
+	shards [shardCount]shard
+}
+
+type shard struct {
+	mu    sync.RWMutex
+	posts map[uuid.UUID]*postNode
+}
+
+func newPostTable() *postTable {
+	t := &postTable{}
+
+	for i := range t.shards {
+		t.shards[i].posts = make(map[uuid.UUID]*postNode)
+	}
+
+	return t
+}
+
+func (t *postTable) shard(id uuid.UUID) *shard {
+	return &t.shards[id[0]&(shardCount-1)]
+}
+
+func (t *postTable) get(id uuid.UUID) (*postNode, bool) {
+	s := t.shard(id)
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	pn, ok := s.posts[id]
+	return pn, ok
+}
+
+// adds a post, unless there's one with the same id already
+func (t *postTable) add(pn *postNode) bool {
+	s := t.shard(pn.post.Id)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.posts[pn.post.Id]; ok {
+		return false
+	}
+
+	s.posts[pn.post.Id] = pn
+
+	return true
+}
+
+func (t *postTable) remove(id uuid.UUID) {
+	s := t.shard(id)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.posts, id)
+}
+
+// lists all of the posts at the moment of the call
+func (t *postTable) all() []*postNode {
+	var (
+		posts []*postNode
+	)
+
+	for i := range t.shards {
+		s := &t.shards[i]
+
+		s.mu.RLock()
+		for _, pn := range s.posts {
+			posts = append(posts, pn)
+		}
+		s.mu.RUnlock()
+	}
+
+	return posts
+}
+
+// post, whose comments are indexed by id, so that any of them is reached in O(1)
+type postNode struct {
+	// guards the post alongside its comments, ballots, moderation log and revisions
+	mu sync.RWMutex
+
+	// Comments are always empty: the tree is held by nodes
+	post storage.Post
+	// top-level comments
+	roots map[uuid.UUID]*node
+	// CommentId -> comment at any depth of the tree
+	comments map[uuid.UUID]*node
+
+	// PostId / CommentId -> UserId -> Vote
+	ballots map[uuid.UUID]map[uuid.UUID]storage.Vote
+	// moderation audit log
+	modlog []storage.ModEntry
+	// PostId / CommentId -> revisions, oldest first
+	revisions map[uuid.UUID][]storage.Revision
+	// ids of all of the revisions above
+	revised map[uuid.UUID]bool
+}
+
+type node struct {
+	// Replies are always empty: the tree is held by nodes
+	comm storage.Comment
+	// nil for top-level comments
+	parent   *node
+	children map[uuid.UUID]*node
+}
+
+func newPostNode(p storage.Post) *postNode {
+	p.Comments = nil
+	rankPost(&p)
+
+	return &postNode{
+		post:      p,
+		roots:     make(map[uuid.UUID]*node),
+		comments:  make(map[uuid.UUID]*node),
+		ballots:   make(map[uuid.UUID]map[uuid.UUID]storage.Vote),
+		revisions: make(map[uuid.UUID][]storage.Revision),
+		revised:   make(map[uuid.UUID]bool),
+	}
+}
+
+// reads the post under its lock
+func (pn *postNode) Cursor() storage.Cursor {
+	pn.mu.RLock()
+	defer pn.mu.RUnlock()
+
+	return pn.post.Cursor()
+}
+
+// builds a detached copy of the post under its lock
+func (pn *postNode) get() storage.Post {
+	pn.mu.RLock()
+	defer pn.mu.RUnlock()
+
+	return pn.tree()
+}
+
+func (n *node) Cursor() storage.Cursor {
+	return n.comm.Cursor()
+}
+
+// builds a detached copy of the post alongside its whole comment tree
+func (pn *postNode) tree() storage.Post {
+	p := pn.post
+	p.Comments = subtree(pn.roots)
+
+	return p
+}
+
+// builds a detached copy of the comment alongside its replies
+func (n *node) tree() storage.Comment {
+	c := n.comm
+	c.Replies = subtree(n.children)
+	c.ReplyCount = len(n.children)
+
+	return c
+}
+
+// copies the comment without its replies, which are only counted
+func (n *node) flat() storage.Comment {
+	c := n.comm
+	c.Replies = make(map[uuid.UUID]storage.Comment)
+	c.ReplyCount = len(n.children)
+
+	return c
+}
+
+func subtree(nodes map[uuid.UUID]*node) map[uuid.UUID]storage.Comment {
+	comms := make(map[uuid.UUID]storage.Comment, len(nodes))
+
+	for id, n := range nodes {
+		comms[id] = n.tree()
+	}
+
+	return comms
+}
+
+// returns a comment of the post by provided id
+func (pn *postNode) comment(id uuid.UUID) (*node, error) {
+	n, ok := pn.comments[id]
+	if !ok {
+		return nil, storage.ErrCommNotFound
+	}
+
+	return n, nil
+}
+
+// creates a new comment (or a reply to the parent, if it's not nil)
+func (pn *postNode) insert(parent *node, userId uuid.UUID, in storage.InComment) (*node, error) {
+	if err := canComment(pn.post); err != nil {
+		return nil, err
+	}
+
+	var (
+		parentId *uuid.UUID
+	)
+
+	if parent != nil {
+		if parent.comm.DeletedAt != nil {
+			return nil, storage.ErrCommIsDeleted
+		}
+		parentId = &parent.comm.Id
+	}
+
+	comm := toComment(pn.post.Id, parentId, userId, in)
+
+	// loop until no collisions detected
+	for _, ok := pn.comments[comm.Id]; ok; _, ok = pn.comments[comm.Id] {
+		comm = toComment(pn.post.Id, parentId, userId, in)
+	}
+
+	return pn.link(comm, parent), nil
+}
+
+// inserts the comment or replaces the existing one, keeping its replies
+// parent of a new comment should already be present
+func (pn *postNode) put(comm storage.Comment) (*node, error) {
+	comm.Replies = nil
+	rankComment(&comm)
+
+	if n, ok := pn.comments[comm.Id]; ok {
+		comm.Depth = n.comm.Depth
+		n.comm = comm
+		return n, nil
+	}
+
+	var (
+		parent *node
+	)
+
+	if comm.ParentId != nil {
+		var err error
+
+		parent, err = pn.comment(*comm.ParentId)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return pn.link(comm, parent), nil
+}
+
+// applies fn to the comment with provided id
+// comment is left intact if fn fails
+func (pn *postNode) mutate(id uuid.UUID, fn func(c *storage.Comment) error) (*node, error) {
+	n, err := pn.comment(id)
+	if err != nil {
+		return nil, err
+	}
+
+	comm := n.comm
+	if err := fn(&comm); err != nil {
+		return nil, err
+	}
+	n.comm = comm
+
+	return n, nil
+}
+
+// attaches a new comment to the tree
+func (pn *postNode) link(comm storage.Comment, parent *node) *node {
+	comm.Replies = nil
+	comm.ReplyCount = 0
+	comm.Depth = 1
+
+	if parent != nil {
+		comm.Depth = parent.comm.Depth + 1
+	}
+
+	n := &node{
+		comm:     comm,
+		parent:   parent,
+		children: make(map[uuid.UUID]*node),
+	}
+
+	if parent == nil {
+		pn.roots[comm.Id] = n
+	} else {
+		parent.children[comm.Id] = n
+	}
+
+	pn.comments[comm.Id] = n
+
+	return n
+}
+
+// detaches the comment alongside its replies from the tree, returning all of the detached comments
+func (pn *postNode) unlink(n *node) []storage.Comment {
+	if n.parent == nil {
+		delete(pn.roots, n.comm.Id)
+	} else {
+		delete(n.parent.children, n.comm.Id)
+	}
+
+	var (
+		removed []storage.Comment
+		drop    func(n *node)
+	)
+
+	drop = func(n *node) {
+		delete(pn.comments, n.comm.Id)
+		removed = append(removed, n.comm)
+
+		for _, c := range n.children {
+			drop(c)
+		}
+	}
+
+	drop(n)
+
+	return removed
+}
+
+// records user's ballot on the post or its comment, returning the previous one
+func (pn *postNode) cast(itemId, userId uuid.UUID, vote storage.Vote) storage.Vote {
+	ballots, ok := pn.ballots[itemId]
+	if !ok {
+		ballots = make(map[uuid.UUID]storage.Vote)
+		pn.ballots[itemId] = ballots
+	}
+
+	prev := ballots[userId]
+
+	if vote == storage.VoteNone {
+		delete(ballots, userId)
+	} else {
+		ballots[userId] = vote
+	}
+
+	return prev
+}
+
+// appends an entry to the audit log, unless it's already there (as it may be during wal replay)
+func (pn *postNode) record(e storage.ModEntry) storage.ModEntry {
+	for _, v := range pn.modlog {
+		if v.Id == e.Id {
+			return e
+		}
+	}
+
+	pn.modlog = append(pn.modlog, e)
+
+	return e
+}
+
+// appends a revision to the history of its item, unless it's already there (as it may be during wal replay)
+func (pn *postNode) revise(r storage.Revision) storage.Revision {
+	if pn.revised[r.Id] {
+		return r
+	}
+
+	itemId := r.ItemId()
+
+	pn.revisions[itemId] = append(pn.revisions[itemId], r)
+	pn.revised[r.Id] = true
+
+	return r
+}
+
+// drops the whole history of the item
+func (pn *postNode) unrevise(itemId uuid.UUID) {
+	for _, r := range pn.revisions[itemId] {
+		delete(pn.revised, r.Id)
+	}
+
+	delete(pn.revisions, itemId)
+}
+
+// returns a revision of the post or its comment by provided id
+func (pn *postNode) revision(itemId, id uuid.UUID) (storage.Revision, error) {
+	for _, r := range pn.revisions[itemId] {
+		if r.Id == id {
+			return r, nil
+		}
+	}
+
+	return storage.Revision{}, storage.ErrRevNotFound
+}
+
+// builds a post out of a detached tree
+func loadPost(p storage.Post) (*postNode, error) {
+	var (
+		pn  = newPostNode(p)
+		err error
+	)
+
+	// parents are visited before their replies
+	walk(p.Comments, func(c storage.Comment) {
+		if err == nil {
+			_, err = pn.put(c)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return pn, nil
+}
+
+// indexes a detached tree of comments under the parent (nil for top-level ones)
+func (pn *postNode) graft(comms map[uuid.UUID]storage.Comment, parent *node) {
+	for _, c := range comms {
+		n := pn.link(c, parent)
+		pn.graft(c.Replies, n)
+	}
+}
+
+// rewrites a detached tree of comments out of the indexed one
+func replant(comms map[uuid.UUID]storage.Comment, nodes map[uuid.UUID]*node) {
+	if comms == nil {
+		return
+	}
+
+	clear(comms)
+
+	for id, n := range nodes {
+		comms[id] = n.tree()
+	}
+}
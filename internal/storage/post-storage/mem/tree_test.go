@@ -0,0 +1,216 @@
+package mem
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	storage "github.com/cutlery47/posts/internal/storage/post-storage"
+	"github.com/google/uuid"
+)
+
+func TestTreePostGetEmptyComments(t *testing.T) {
+	pn := newPostNode(storage.Post{Id: uuid.New()})
+
+	if _, err := pn.comment(uuid.New()); !errors.Is(err, storage.ErrCommNotFound) {
+		t.Fatalf("should be %v", storage.ErrCommNotFound)
+	}
+}
+
+func TestTreePostUpdateEmptyComments(t *testing.T) {
+	pn := newPostNode(storage.Post{Id: uuid.New()})
+
+	_, err := pn.mutate(uuid.New(), func(c *storage.Comment) error { return nil })
+	if !errors.Is(err, storage.ErrCommNotFound) {
+		t.Fatalf("should be %v", storage.ErrCommNotFound)
+	}
+}
+
+func TestTreePostInsertCommentIntoDeleted(t *testing.T) {
+	ts := time.Now()
+	pn := newPostNode(storage.Post{
+		Id:        uuid.New(),
+		DeletedAt: &ts,
+	})
+
+	_, err := pn.insert(nil, uuid.New(), storage.InComment{})
+	if !errors.Is(err, storage.ErrPostIsDeleted) {
+		t.Fatalf("error: %v", err)
+	}
+}
+
+func TestTreePostInsertCommentIntoMute(t *testing.T) {
+	pn := newPostNode(storage.Post{
+		Id: uuid.New(),
+		InPost: storage.InPost{
+			IsMute: true,
+		},
+	})
+
+	_, err := pn.insert(nil, uuid.New(), storage.InComment{})
+	if !errors.Is(err, storage.ErrPostIsMute) {
+		t.Fatalf("error: %v", err)
+	}
+}
+
+func TestTreePostInsertReplyIntoLocked(t *testing.T) {
+	pn := newPostNode(storage.Post{Id: uuid.New()})
+
+	root, err := pn.insert(nil, uuid.New(), storage.InComment{})
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	pn.post.IsLocked = true
+
+	_, err = pn.insert(root, uuid.New(), storage.InComment{})
+	if !errors.Is(err, storage.ErrPostIsLocked) {
+		t.Fatalf("error: %v", err)
+	}
+}
+
+func TestTreePostUpdateNotFound(t *testing.T) {
+	pn := newPostNode(storage.Post{Id: uuid.New()})
+
+	if _, err := pn.insert(nil, uuid.New(), storage.InComment{}); err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	_, err := pn.mutate(uuid.New(), func(c *storage.Comment) error { return nil })
+	if !errors.Is(err, storage.ErrCommNotFound) {
+		t.Fatalf("error: %v", err)
+	}
+}
+
+func TestTreePostFailedUpdate(t *testing.T) {
+	pn := newPostNode(storage.Post{Id: uuid.New()})
+
+	n, err := pn.insert(nil, uuid.New(), storage.InComment{Content: "before"})
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	fail := errors.New("fail")
+
+	_, err = pn.mutate(n.comm.Id, func(c *storage.Comment) error {
+		c.Content = "after"
+		return fail
+	})
+	if !errors.Is(err, fail) {
+		t.Fatalf("error: %v", err)
+	}
+
+	// comment is left intact
+	if n.comm.Content != "before" {
+		t.Fatalf("comment was changed by a failed update")
+	}
+}
+
+// builds a post with a chain of nested comments: root <- reply <- nested
+func commentChain(t *testing.T) (*postNode, []*node) {
+	var (
+		pn     = newPostNode(storage.Post{Id: uuid.New()})
+		chain  []*node
+		parent *node
+	)
+
+	for range 3 {
+		n, err := pn.insert(parent, uuid.New(), storage.InComment{Content: "comment"})
+		if err != nil {
+			t.Fatalf("error: %v", err)
+		}
+
+		chain = append(chain, n)
+		parent = n
+	}
+
+	return pn, chain
+}
+
+func TestTreeCommentInsertReplyIntoDeleted(t *testing.T) {
+	pn, chain := commentChain(t)
+
+	ts := time.Now()
+	chain[0].comm.DeletedAt = &ts
+
+	_, err := pn.insert(chain[0], uuid.New(), storage.InComment{})
+	if !errors.Is(err, storage.ErrCommIsDeleted) {
+		t.Fatalf("error: %v", err)
+	}
+}
+
+func TestTreeCommentNestedGetReply(t *testing.T) {
+	pn, chain := commentChain(t)
+
+	for i, n := range chain {
+		found, err := pn.comment(n.comm.Id)
+		if err != nil {
+			t.Fatalf("error: %v", err)
+		}
+
+		if found != n {
+			t.Fatalf("found another comment")
+		}
+
+		if found.comm.Depth != i+1 {
+			t.Fatalf("depth %v, expected %v", found.comm.Depth, i+1)
+		}
+	}
+}
+
+func TestTreeCommentNestedUpdateReply(t *testing.T) {
+	pn, chain := commentChain(t)
+
+	nested := chain[2].comm.Id
+
+	_, err := pn.mutate(nested, func(c *storage.Comment) error {
+		c.Content = "edited"
+		return nil
+	})
+	if err != nil {
+		t.Fatal("error: ", err)
+	}
+
+	// the update is seen through the whole tree
+	var (
+		root  = pn.tree().Comments[chain[0].comm.Id]
+		reply = root.Replies[chain[1].comm.Id]
+	)
+
+	if reply.Replies[nested].Content != "edited" {
+		t.Fatalf("update isn't seen in the tree")
+	}
+}
+
+func TestTreeCommentNestedDeleteReply(t *testing.T) {
+	pn, chain := commentChain(t)
+
+	removed := pn.unlink(chain[1])
+	if len(removed) != 2 {
+		t.Fatalf("removed %v comments, expected 2", len(removed))
+	}
+
+	for _, n := range chain[1:] {
+		if _, err := pn.comment(n.comm.Id); !errors.Is(err, storage.ErrCommNotFound) {
+			t.Fatalf("unlinked comment is still found")
+		}
+	}
+
+	if len(chain[0].children) != 0 {
+		t.Fatalf("unlinked reply is still a child")
+	}
+}
+
+func TestTreeCommentFlat(t *testing.T) {
+	_, chain := commentChain(t)
+
+	c := chain[0].flat()
+	if len(c.Replies) != 0 || c.ReplyCount != 1 {
+		t.Fatalf("got %v replies and a count of %v, expected none and 1", len(c.Replies), c.ReplyCount)
+	}
+
+	c = chain[0].tree()
+	if len(c.Replies) != 1 || len(c.Replies[chain[1].comm.Id].Replies) != 1 {
+		t.Fatalf("tree isn't built")
+	}
+}
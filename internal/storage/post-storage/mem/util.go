@@ -1,7 +1,10 @@
 package mem
 
 import (
+	"container/heap"
 	"context"
+	"iter"
+	"slices"
 	"time"
 
 	storage "github.com/cutlery47/posts/internal/storage/post-storage"
@@ -18,28 +21,168 @@ func ctxDone(ctx context.Context) error {
 	return nil
 }
 
-func toComment(in storage.InComment) storage.Comment {
-	return storage.Comment{
+func toComment(postId uuid.UUID, parentId *uuid.UUID, userId uuid.UUID, in storage.InComment) storage.Comment {
+	comm := storage.Comment{
 		Id:        uuid.New(),
+		UserId:    userId,
+		PostId:    postId,
+		ParentId:  parentId,
 		Upvotes:   0,
 		Downvotes: 0,
+		Version:   1,
 		CreatedAt: time.Now(),
 		UpdatedAt: time.Now(),
 		DeletedAt: nil,
 		Replies:   make(map[uuid.UUID]storage.Comment),
 		InComment: in,
 	}
+	rankComment(&comm)
+
+	return comm
 }
 
-func toPost(in storage.InPost) storage.Post {
-	return storage.Post{
+func toPost(userId uuid.UUID, in storage.InPost) storage.Post {
+	post := storage.Post{
 		Id:        uuid.New(),
+		UserId:    userId,
 		Upvotes:   0,
 		Downvotes: 0,
+		Version:   1,
 		CreatedAt: time.Now(),
 		UpdatedAt: time.Now(),
 		DeletedAt: nil,
 		Comments:  make(map[uuid.UUID]storage.Comment),
 		InPost:    in,
 	}
+	rankPost(&post)
+
+	return post
+}
+
+// applies ballot change to upvote and downvote counters
+func tally(upvotes, downvotes *uint64, prev, next storage.Vote) {
+	up, down := storage.Tally(prev, next)
+
+	*upvotes = uint64(int64(*upvotes) + up)
+	*downvotes = uint64(int64(*downvotes) + down)
+}
+
+// ranks are kept alongside the items, so that they aren't recomputed on every comparison
+// should be called whenever votes change or an item is restored (ranks aren't persisted)
+func rankPost(p *storage.Post) {
+	p.Ranking = storage.Rank(p.Upvotes, p.Downvotes, p.CreatedAt)
+}
+
+func rankComment(c *storage.Comment) {
+	c.Ranking = storage.Rank(c.Upvotes, c.Downvotes, c.CreatedAt)
+}
+
+// selects a page of items, which are positioned after the cursor in given order
+func selectPage[T interface{ Cursor() storage.Cursor }](items iter.Seq[T], q storage.PageQuery) *storage.Page[T] {
+	var (
+		since = q.Since(time.Now())
+	)
+
+	// cursors are taken once per item, so that the item isn't locked on every comparison
+	seq := func(yield func(positioned[T]) bool) {
+		for v := range items {
+			c := v.Cursor()
+
+			if c.CreatedAt.Before(since) {
+				continue
+			}
+
+			if q.After != nil && q.SortBy.Compare(c, *q.After) <= 0 {
+				continue
+			}
+
+			if !yield(positioned[T]{v: v, c: c}) {
+				return
+			}
+		}
+	}
+
+	top := topN(seq, q.First+1, func(a, b positioned[T]) int {
+		return q.SortBy.Compare(a.c, b.c)
+	})
+
+	return mapPage(toPage(top, q.First), func(p positioned[T]) T {
+		return p.v
+	})
+}
+
+// item alongside its cursor
+type positioned[T any] struct {
+	v T
+	c storage.Cursor
+}
+
+// trims extra item, which was selected in order to find out if there's a next page
+func toPage[T any](items []T, first int) *storage.Page[T] {
+	page := &storage.Page[T]{
+		Items:       items,
+		HasNextPage: len(items) > first,
+	}
+
+	if page.HasNextPage {
+		page.Items = page.Items[:first]
+	}
+
+	return page
+}
+
+// converts items of the page (e.g. builds detached copies of posts and comments)
+func mapPage[N, T any](page *storage.Page[N], fn func(N) T) *storage.Page[T] {
+	items := make([]T, 0, len(page.Items))
+
+	for _, n := range page.Items {
+		items = append(items, fn(n))
+	}
+
+	return &storage.Page[T]{
+		Items:       items,
+		HasNextPage: page.HasNextPage,
+	}
+}
+
+// selects n items, which are placed first in given order, and sorts them
+// keeps only n candidates at a time, so it runs in O(N log(n))
+func topN[T any](seq iter.Seq[T], n int, cmp func(a, b T) int) []T {
+	h := &boundedHeap[T]{
+		items: []T{},
+		cmp:   cmp,
+	}
+
+	for v := range seq {
+		if h.Len() < n {
+			heap.Push(h, v)
+		} else if n > 0 && cmp(v, h.items[0]) < 0 {
+			h.items[0] = v
+			heap.Fix(h, 0)
+		}
+	}
+
+	slices.SortFunc(h.items, cmp)
+
+	return h.items
+}
+
+// max-heap, which keeps the item placed last in given order on top
+type boundedHeap[T any] struct {
+	items []T
+	cmp   func(a, b T) int
+}
+
+func (h boundedHeap[T]) Len() int           { return len(h.items) }
+func (h boundedHeap[T]) Less(i, j int) bool { return h.cmp(h.items[i], h.items[j]) > 0 }
+func (h boundedHeap[T]) Swap(i, j int)      { h.items[i], h.items[j] = h.items[j], h.items[i] }
+
+func (h *boundedHeap[T]) Push(x any) {
+	h.items = append(h.items, x.(T))
+}
+
+func (h *boundedHeap[T]) Pop() any {
+	last := h.items[len(h.items)-1]
+	h.items = h.items[:len(h.items)-1]
+	return last
 }
@@ -0,0 +1,48 @@
+package mem
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	storage "github.com/cutlery47/posts/internal/storage/post-storage"
+	"github.com/google/uuid"
+)
+
+type item storage.Cursor
+
+func (i item) Cursor() storage.Cursor {
+	return storage.Cursor(i)
+}
+
+func TestSelectPageWindow(t *testing.T) {
+	var (
+		now   = time.Now()
+		items = []item{
+			{Id: uuid.New(), CreatedAt: now.Add(-time.Hour), Upvotes: 1},
+			{Id: uuid.New(), CreatedAt: now.Add(-48 * time.Hour), Upvotes: 3},
+			{Id: uuid.New(), CreatedAt: now.Add(-30 * 24 * time.Hour), Upvotes: 2},
+		}
+	)
+
+	// ids, expected within each window, top first
+	tests := map[storage.Window][]uuid.UUID{
+		storage.WindowDay:  {items[0].Id},
+		storage.WindowWeek: {items[1].Id, items[0].Id},
+		storage.WindowAll:  {items[1].Id, items[2].Id, items[0].Id},
+		"":                 {items[1].Id, items[2].Id, items[0].Id},
+	}
+
+	for window, want := range tests {
+		page := selectPage(slices.Values(items), storage.PageQuery{First: 10, SortBy: storage.SortTop, Window: window})
+
+		var got []uuid.UUID
+		for _, v := range page.Items {
+			got = append(got, v.Id)
+		}
+
+		if !slices.Equal(got, want) {
+			t.Fatalf("wrong items within %q window", window)
+		}
+	}
+}
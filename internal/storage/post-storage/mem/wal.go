@@ -0,0 +1,532 @@
+package mem
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	storage "github.com/cutlery47/posts/internal/storage/post-storage"
+	"github.com/google/uuid"
+)
+
+// kinds of wal records
+// each record holds the state of an item after the mutation, so that replay is idempotent
+type walOp string
+
+const (
+	// post without its comments
+	walPost walOp = "post"
+	// comment without its replies
+	walComment walOp = "comment"
+	walBallot  walOp = "ballot"
+	// moderation audit log entry
+	walModeration walOp = "moderation"
+	// revision of a post or a comment
+	walRevision walOp = "revision"
+	// purge of content, deleted before the threshold
+	walPurge walOp = "purge"
+)
+
+type walRecord struct {
+	Seq uint64 `json:"seq"`
+	Op  walOp  `json:"op"`
+
+	Post     *storage.Post     `json:"post,omitempty"`
+	Comment  *storage.Comment  `json:"comment,omitempty"`
+	Ballot   *ballot           `json:"ballot,omitempty"`
+	Entry    *storage.ModEntry `json:"entry,omitempty"`
+	Revision *storage.Revision `json:"revision,omitempty"`
+	Before   *time.Time        `json:"before,omitempty"`
+}
+
+type ballot struct {
+	// post, which holds the item (missing from the records of older versions)
+	PostId uuid.UUID    `json:"post_id"`
+	ItemId uuid.UUID    `json:"item_id"`
+	UserId uuid.UUID    `json:"user_id"`
+	Vote   storage.Vote `json:"vote"`
+}
+
+func postRecord(p storage.Post) walRecord {
+	p.Comments = nil
+	return walRecord{Op: walPost, Post: &p}
+}
+
+func commentRecord(c storage.Comment) walRecord {
+	c.Replies = nil
+	return walRecord{Op: walComment, Comment: &c}
+}
+
+func ballotRecord(postId, itemId, userId uuid.UUID, vote storage.Vote) walRecord {
+	return walRecord{Op: walBallot, Ballot: &ballot{postId, itemId, userId, vote}}
+}
+
+func modRecord(e storage.ModEntry) walRecord {
+	return walRecord{Op: walModeration, Entry: &e}
+}
+
+func revisionRecord(r storage.Revision) walRecord {
+	return walRecord{Op: walRevision, Revision: &r}
+}
+
+func purgeRecord(before time.Time) walRecord {
+	return walRecord{Op: walPurge, Before: &before}
+}
+
+// applies a single wal record to the storage during replay
+// not concurrent-safe by itself!
+func (ms *memStorage) apply(rec walRecord) error {
+	switch rec.Op {
+	case walPost:
+		if pn, ok := ms.posts.get(rec.Post.Id); ok {
+			pn.post = *rec.Post
+			rankPost(&pn.post)
+		} else {
+			ms.posts.add(newPostNode(*rec.Post))
+		}
+	case walComment:
+		pn, ok := ms.posts.get(rec.Comment.PostId)
+		if !ok {
+			return storage.ErrPostNotFound
+		}
+
+		if _, err := pn.put(*rec.Comment); err != nil {
+			return err
+		}
+	case walBallot:
+		pn, ok := ms.owner(*rec.Ballot)
+		if !ok {
+			return storage.ErrPostNotFound
+		}
+
+		pn.cast(rec.Ballot.ItemId, rec.Ballot.UserId, rec.Ballot.Vote)
+	case walModeration:
+		pn, ok := ms.posts.get(rec.Entry.PostId)
+		if !ok {
+			return storage.ErrPostNotFound
+		}
+
+		pn.record(*rec.Entry)
+	case walRevision:
+		pn, ok := ms.posts.get(rec.Revision.PostId)
+		if !ok {
+			return storage.ErrPostNotFound
+		}
+
+		pn.revise(*rec.Revision)
+	case walPurge:
+		ms.purge(*rec.Before, &storage.PurgeStats{})
+	default:
+		return fmt.Errorf("unknown wal record: %v", rec.Op)
+	}
+
+	return nil
+}
+
+// finds the post, which holds the voted item
+func (ms *memStorage) owner(b ballot) (*postNode, bool) {
+	if b.PostId != uuid.Nil {
+		return ms.posts.get(b.PostId)
+	}
+
+	if pn, ok := ms.posts.get(b.ItemId); ok {
+		return pn, true
+	}
+
+	for _, pn := range ms.posts.all() {
+		if _, ok := pn.comments[b.ItemId]; ok {
+			return pn, true
+		}
+	}
+
+	return nil, false
+}
+
+// append-only log of storage mutations
+// the log is split into segments, each of which is named after the first record it holds
+type wal struct {
+	dir string
+	// fsync interval (0 means fsync on every write)
+	interval time.Duration
+
+	mu sync.Mutex
+	// signaled on every fsync
+	cond *sync.Cond
+
+	f *os.File
+	w *bufio.Writer
+
+	// last appended record
+	seq uint64
+	// last record, which is known to be on disk
+	synced uint64
+	// wal is unusable after the first io error
+	err error
+}
+
+// opens a new segment, which follows the record with provided seq
+func openWal(dir string, interval time.Duration, seq uint64) (*wal, error) {
+	if err := os.MkdirAll(dir, 0777); err != nil {
+		return nil, err
+	}
+
+	w := &wal{
+		dir:      dir,
+		interval: interval,
+		seq:      seq,
+		synced:   seq,
+	}
+	w.cond = sync.NewCond(&w.mu)
+
+	if err := w.open(); err != nil {
+		return nil, err
+	}
+
+	if interval > 0 {
+		go w.syncer()
+	}
+
+	return w, nil
+}
+
+// appends records to the log, returning the seq of the last one
+// records are not durable until wait returns
+func (w *wal) append(recs ...walRecord) (uint64, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.err != nil {
+		return 0, w.err
+	}
+
+	for _, rec := range recs {
+		rec.Seq = w.seq + 1
+
+		data, err := json.Marshal(rec)
+		if err != nil {
+			return 0, err
+		}
+
+		if _, err := w.w.Write(append(data, '\n')); err != nil {
+			w.err = err
+			return 0, err
+		}
+
+		w.seq = rec.Seq
+	}
+
+	return w.seq, nil
+}
+
+// returns the seq of the last appended record
+func (w *wal) last() uint64 {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return w.seq
+}
+
+// blocks until the record with provided seq is on disk
+func (w *wal) wait(seq uint64) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	// records, appended by concurrent writers, are synced alongside
+	if w.interval == 0 {
+		if w.synced < seq {
+			w.sync()
+		}
+		return w.err
+	}
+
+	for w.synced < seq && w.err == nil {
+		w.cond.Wait()
+	}
+
+	return w.err
+}
+
+// group commit: fsyncs all of the appended records once in an interval
+func (w *wal) syncer() {
+	for {
+		time.Sleep(w.interval)
+
+		w.mu.Lock()
+
+		if w.synced < w.seq {
+			w.sync()
+		}
+		w.cond.Broadcast()
+
+		stop := w.err != nil
+
+		w.mu.Unlock()
+
+		if stop {
+			return
+		}
+	}
+}
+
+// should be called with mu held
+func (w *wal) sync() {
+	if w.err != nil {
+		return
+	}
+
+	if err := w.w.Flush(); err != nil {
+		w.err = err
+		return
+	}
+
+	if err := w.f.Sync(); err != nil {
+		w.err = err
+		return
+	}
+
+	w.synced = w.seq
+}
+
+// starts a new segment, so that the previous ones may be compacted
+// returns the seq of the last record in the previous segments
+func (w *wal) rotate() (uint64, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.sync()
+	w.cond.Broadcast()
+
+	if w.err != nil {
+		return 0, w.err
+	}
+
+	// previous segment is kept in use if the new one can't be opened, so that the rotation may be retried
+	prev := w.f
+
+	if err := w.open(); err != nil {
+		return 0, err
+	}
+
+	// previous segment is already synced
+	prev.Close()
+
+	return w.seq, nil
+}
+
+// returns the error, which has made the wal unusable
+func (w *wal) failed() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return w.err
+}
+
+// syncs appended records and closes the current segment
+// the wal is unusable afterwards
+func (w *wal) close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if errors.Is(w.err, ErrClosed) {
+		return nil
+	}
+
+	w.sync()
+	w.cond.Broadcast()
+
+	err := w.err
+	if cerr := w.f.Close(); err == nil {
+		err = cerr
+	}
+
+	w.err = ErrClosed
+
+	return err
+}
+
+// removes segments, which hold only the records up to provided seq
+func (w *wal) compact(seq uint64) error {
+	segs, err := segments(w.dir)
+	if err != nil {
+		return err
+	}
+
+	for _, s := range segs {
+		if s.first > seq {
+			continue
+		}
+
+		if err := os.Remove(s.path); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// should be called with mu held
+func (w *wal) open() error {
+	// existing segment may only hold a torn record, since its first record is yet to be written
+	f, err := os.OpenFile(segmentPath(w.dir, w.seq+1), os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0666)
+	if err != nil {
+		return err
+	}
+
+	if err := syncDir(w.dir); err != nil {
+		f.Close()
+		return err
+	}
+
+	w.f = f
+	w.w = bufio.NewWriter(f)
+
+	return nil
+}
+
+type segment struct {
+	path string
+	// seq of the first record in the segment
+	first uint64
+}
+
+const segmentExt = ".wal"
+
+func segmentPath(dir string, first uint64) string {
+	return filepath.Join(dir, fmt.Sprintf("%020d%v", first, segmentExt))
+}
+
+// lists wal segments in the order they were written
+func segments(dir string) ([]segment, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var (
+		segs []segment
+	)
+
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !strings.HasSuffix(name, segmentExt) {
+			continue
+		}
+
+		var first uint64
+		if _, err := fmt.Sscanf(strings.TrimSuffix(name, segmentExt), "%d", &first); err != nil {
+			continue
+		}
+
+		segs = append(segs, segment{path: filepath.Join(dir, name), first: first})
+	}
+
+	slices.SortFunc(segs, func(a, b segment) int {
+		switch {
+		case a.first < b.first:
+			return -1
+		case a.first > b.first:
+			return 1
+		}
+		return 0
+	})
+
+	return segs, nil
+}
+
+// reads records of all segments, which follow provided seq
+// torn record at the end of a segment (left by a crash mid-write) is skipped
+func readWal(dir string, after uint64, fn func(rec walRecord) error) (uint64, error) {
+	segs, err := segments(dir)
+	if err != nil {
+		return 0, err
+	}
+
+	last := after
+
+	for _, s := range segs {
+		data, err := os.ReadFile(s.path)
+		if err != nil {
+			return 0, err
+		}
+
+		r := bufio.NewReader(bytes.NewReader(data))
+
+		for {
+			line, err := r.ReadBytes('\n')
+			if errors.Is(err, io.EOF) {
+				// either the segment is over or the last record is torn
+				break
+			}
+
+			var rec walRecord
+			if err := json.Unmarshal(line, &rec); err != nil {
+				return 0, fmt.Errorf("%v: %v", s.path, err)
+			}
+
+			if rec.Seq <= last {
+				continue
+			}
+
+			if err := fn(rec); err != nil {
+				return 0, fmt.Errorf("%v: record %v: %v", s.path, rec.Seq, err)
+			}
+
+			last = rec.Seq
+		}
+	}
+
+	return last, nil
+}
+
+// writes file atomically: readers observe either the old contents or the new ones
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return err
+	}
+
+	return syncDir(filepath.Dir(path))
+}
+
+// persists directory entries (created, renamed or removed files)
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+
+	return d.Sync()
+}
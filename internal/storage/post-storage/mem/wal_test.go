@@ -0,0 +1,630 @@
+package mem
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cutlery47/posts/config"
+	storage "github.com/cutlery47/posts/internal/storage/post-storage"
+	"github.com/google/uuid"
+)
+
+func walConf(t *testing.T) config.PostStorage {
+	dir := t.TempDir()
+
+	return config.PostStorage{
+		RestoreSource:   filepath.Join(dir, "dump"),
+		DumpDestination: filepath.Join(dir, "dump"),
+		WALDestination:  filepath.Join(dir, "wal"),
+		DumpEnabled:     true,
+		// snapshots are taken manually
+		DumpInterval: time.Hour,
+	}
+}
+
+// fills storage with every kind of mutation
+func populate(t *testing.T, ms *memStorage) {
+	ctx := context.Background()
+
+	post, err := ms.InsertPost(ctx, uuid.New(), storage.InPost{Content: "post"})
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	comm, err := ms.InsertComment(ctx, post.Id, nil, uuid.New(), storage.InComment{Content: "comment"})
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	repl, err := ms.InsertComment(ctx, post.Id, &comm.Id, uuid.New(), storage.InComment{Content: "reply"})
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	if _, err := ms.UpdateComment(ctx, post.Id, repl.Id, storage.InComment{Content: "edited"}, nil); err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	if _, err := ms.RevertComment(ctx, post.Id, repl.Id, repl.Id, uuid.New()); err == nil {
+		t.Fatalf("reverted to unknown revision")
+	}
+
+	revs, err := ms.GetRevisions(ctx, post.Id, &repl.Id)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	if _, err := ms.RevertComment(ctx, post.Id, repl.Id, revs[0].Id, uuid.New()); err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	if _, err := ms.VoteComment(ctx, post.Id, repl.Id, uuid.New(), storage.VoteUp); err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	if _, err := ms.VotePost(ctx, post.Id, uuid.New(), storage.VoteDown); err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	if _, err := ms.DeleteComment(ctx, post.Id, comm.Id, nil); err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	e := storage.ModEntry{PostId: post.Id, ActorId: uuid.New(), Action: storage.ModLock, Reason: "reason"}
+	if _, err := ms.ModeratePost(ctx, e, nil); err != nil {
+		t.Fatalf("error: %v", err)
+	}
+}
+
+func state(t *testing.T, ms *memStorage) string {
+	data, err := json.Marshal(ms.capture())
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	return string(data)
+}
+
+func TestWalReplay(t *testing.T) {
+	conf := walConf(t)
+
+	ms, err := NewStorage(conf, nil)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	populate(t, ms)
+
+	restored, err := NewStorage(conf, nil)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	if state(t, ms) != state(t, restored) {
+		t.Fatalf("restored state differs")
+	}
+
+	if len(restored.authors.posts) != len(ms.authors.posts) || len(restored.authors.comments) != len(ms.authors.comments) {
+		t.Fatalf("author index wasn't rebuilt")
+	}
+}
+
+// content, which was stored before revisions were introduced, gets its first revision once on restore
+func TestRestoreLegacyDump(t *testing.T) {
+	conf := walConf(t)
+
+	post := storage.Post{
+		Id:       uuid.New(),
+		UserId:   uuid.New(),
+		InPost:   storage.InPost{Content: "post"},
+		Comments: make(map[uuid.UUID]storage.Comment),
+	}
+
+	// dumps used to hold nothing but the posts
+	data, err := json.Marshal(map[uuid.UUID]storage.Post{post.Id: post})
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	if err := os.WriteFile(conf.RestoreSource, data, 0666); err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	ms, err := NewStorage(conf, nil)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	if _, err := ms.GetPost(context.Background(), post.Id); err != nil {
+		t.Fatalf("error: %v", err)
+	}
+}
+
+func TestRestoreLegacyAuthors(t *testing.T) {
+	var (
+		conf = walConf(t)
+
+		postId, commId, replyId             = uuid.New(), uuid.New(), uuid.New()
+		postAuthor, commAuthor, replyAuthor = uuid.New(), uuid.New(), uuid.New()
+	)
+
+	// authors used to be dumped among the input-bound fields
+	data := fmt.Sprintf(`{%q: {
+		"id": %q, "in_post": {"user_id": %q, "content": "post"},
+		"comments": {%q: {
+			"id": %q, "post_id": %q, "depth": 1, "in_comment": {"user_id": %q, "content": "comment"},
+			"replies": {%q: {
+				"id": %q, "post_id": %q, "parent_id": %q, "depth": 2, "in_comment": {"user_id": %q, "content": "reply"}
+			}}
+		}}
+	}}`, postId, postId, postAuthor, commId, commId, postId, commAuthor, replyId, replyId, postId, commId, replyAuthor)
+
+	if err := os.WriteFile(conf.RestoreSource, []byte(data), 0666); err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	ms, err := NewStorage(conf, nil)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	post, err := ms.GetPost(context.Background(), postId)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	if post.UserId != postAuthor {
+		t.Fatalf("post author %v, expected %v", post.UserId, postAuthor)
+	}
+
+	for id, author := range map[uuid.UUID]uuid.UUID{commId: commAuthor, replyId: replyAuthor} {
+		comm, err := ms.GetComment(context.Background(), postId, id)
+		if err != nil {
+			t.Fatalf("error: %v", err)
+		}
+
+		if comm.UserId != author {
+			t.Fatalf("comment author %v, expected %v", comm.UserId, author)
+		}
+	}
+}
+
+func TestRestoreUnknownDump(t *testing.T) {
+	for _, data := range []string{`{"format": 100, "posts": {}}`, `{"items": []}`, `[]`} {
+		conf := walConf(t)
+
+		if err := os.WriteFile(conf.RestoreSource, []byte(data), 0666); err != nil {
+			t.Fatalf("error: %v", err)
+		}
+
+		if _, err := NewStorage(conf, nil); err == nil {
+			t.Fatalf("dump %v was restored", data)
+		}
+	}
+}
+
+func TestRevisionBackfill(t *testing.T) {
+	conf := walConf(t)
+
+	post := storage.Post{
+		Id:       uuid.New(),
+		UserId:   uuid.New(),
+		InPost:   storage.InPost{Content: "post"},
+		Comments: make(map[uuid.UUID]storage.Comment),
+	}
+
+	comm := storage.Comment{
+		Id:        uuid.New(),
+		PostId:    post.Id,
+		UserId:    uuid.New(),
+		InComment: storage.InComment{Content: "comment"},
+		Depth:     1,
+	}
+	post.Comments[comm.Id] = comm
+
+	data, err := json.Marshal(snapshot{Posts: map[uuid.UUID]storage.Post{post.Id: post}})
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	if err := os.WriteFile(conf.RestoreSource, data, 0666); err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	ms, err := NewStorage(conf, nil)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	revs, err := ms.GetRevisions(context.Background(), post.Id, &comm.Id)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	if len(revs) != 1 || revs[0].Content != comm.Content || revs[0].EditorId != comm.UserId {
+		t.Fatalf("wrong revisions: %v", revs)
+	}
+
+	restored, err := NewStorage(conf, nil)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	// backfilled revisions are logged, so that they aren't made up again
+	if state(t, ms) != state(t, restored) {
+		t.Fatalf("restored state differs")
+	}
+}
+
+func TestRevisionBackfillOnce(t *testing.T) {
+	conf := walConf(t)
+
+	post := storage.Post{
+		Id:       uuid.New(),
+		UserId:   uuid.New(),
+		InPost:   storage.InPost{Content: "post"},
+		Comments: make(map[uuid.UUID]storage.Comment),
+	}
+
+	// snapshots of the current format aren't backfilled, even if an item has no revisions
+	data, err := json.Marshal(snapshot{Format: snapshotFormat, Posts: map[uuid.UUID]storage.Post{post.Id: post}})
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	if err := os.WriteFile(conf.RestoreSource, data, 0666); err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	ms, err := NewStorage(conf, nil)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	revs, err := ms.GetRevisions(context.Background(), post.Id, nil)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	if len(revs) != 0 {
+		t.Fatalf("revisions were backfilled: %v", revs)
+	}
+}
+
+func TestReviseTwice(t *testing.T) {
+	var (
+		pn = newPostNode(storage.Post{Id: uuid.New()})
+		r  = storage.PostRevision(pn.post, uuid.New(), time.Now())
+	)
+
+	// as it happens, when a record is replayed on top of the snapshot, which already reflects it
+	pn.revise(r)
+	pn.revise(r)
+
+	if len(pn.revisions[pn.post.Id]) != 1 {
+		t.Fatalf("revision was recorded twice")
+	}
+
+	pn.unrevise(pn.post.Id)
+	pn.revise(r)
+
+	if len(pn.revisions[pn.post.Id]) != 1 {
+		t.Fatalf("revision wasn't recorded after its history was dropped")
+	}
+}
+
+func TestWalSnapshot(t *testing.T) {
+	conf := walConf(t)
+
+	ms, err := NewStorage(conf, nil)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	populate(t, ms)
+
+	if err := ms.snapshot(); err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	// only the segment, which follows the snapshot, is left
+	segs, err := segments(conf.WALDestination)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	if len(segs) != 1 || segs[0].first != ms.snapSeq+1 {
+		t.Fatalf("wal wasn't compacted: %v", segs)
+	}
+
+	populate(t, ms)
+
+	restored, err := NewStorage(conf, nil)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	if state(t, ms) != state(t, restored) {
+		t.Fatalf("restored state differs")
+	}
+}
+
+func TestWalTornRecord(t *testing.T) {
+	conf := walConf(t)
+
+	ms, err := NewStorage(conf, nil)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	populate(t, ms)
+
+	segs, err := segments(conf.WALDestination)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	// crash in the middle of a write
+	f, err := os.OpenFile(segs[len(segs)-1].path, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	if _, err := f.WriteString(`{"seq":100,"op":"po`); err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	f.Close()
+
+	restored, err := NewStorage(conf, nil)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	if state(t, ms) != state(t, restored) {
+		t.Fatalf("restored state differs")
+	}
+}
+
+func TestWalGroupCommit(t *testing.T) {
+	conf := walConf(t)
+	conf.WALSyncInterval = 10 * time.Millisecond
+
+	ms, err := NewStorage(conf, nil)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	populate(t, ms)
+
+	if _, err := ms.Purge(context.Background(), time.Now()); err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	restored, err := NewStorage(conf, nil)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	if state(t, ms) != state(t, restored) {
+		t.Fatalf("restored state differs")
+	}
+}
+
+func TestDumpRetry(t *testing.T) {
+	conf := walConf(t)
+	conf.DumpInterval = 10 * time.Millisecond
+	conf.DumpRetryDelay = time.Millisecond
+	conf.DumpMaxFailures = 3
+	// snapshot can't be written into a missing directory
+	conf.DumpDestination = filepath.Join(t.TempDir(), "missing", "dump")
+	conf.RestoreSource = conf.DumpDestination
+
+	errChan := make(chan error, 1)
+
+	ms, err := NewStorage(conf, errChan)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	populate(t, ms)
+
+	select {
+	case <-errChan:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("dump failures weren't escalated")
+	}
+
+	if err := ms.Ready(context.Background()); err == nil {
+		t.Fatalf("storage is ready after dump failures")
+	}
+
+	// wal keeps serving writes
+	populate(t, ms)
+
+	restored, err := NewStorage(conf, nil)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	if state(t, ms) != state(t, restored) {
+		t.Fatalf("restored state differs")
+	}
+}
+
+func TestSnapshotPathMismatch(t *testing.T) {
+	conf := walConf(t)
+	conf.RestoreSource = filepath.Join(t.TempDir(), "dump")
+
+	if _, err := NewStorage(conf, nil); !errors.Is(err, ErrSnapshotPath) {
+		t.Fatalf("error: %v", err)
+	}
+}
+
+func TestDumpDelay(t *testing.T) {
+	ms := &memStorage{conf: config.PostStorage{DumpInterval: 5 * time.Second, DumpRetryDelay: time.Second}}
+
+	for failures, want := range []time.Duration{5, 1, 2, 4, 5, 5} {
+		if got := ms.dumpDelay(failures); got != want*time.Second {
+			t.Fatalf("delay after %v failures: %v, want %vs", failures, got, want)
+		}
+	}
+}
+
+func TestClose(t *testing.T) {
+	conf := walConf(t)
+
+	ms, err := NewStorage(conf, nil)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	populate(t, ms)
+
+	if err := ms.Close(); err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	if _, err := ms.InsertPost(context.Background(), uuid.New(), storage.InPost{}); err != ErrClosed {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// final snapshot covers the whole wal
+	if _, err := os.Stat(conf.DumpDestination); err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	segs, err := segments(conf.WALDestination)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	if len(segs) != 1 || segs[0].first != ms.snapSeq+1 {
+		t.Fatalf("wal wasn't compacted: %v", segs)
+	}
+
+	restored, err := NewStorage(conf, nil)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	if state(t, ms) != state(t, restored) {
+		t.Fatalf("restored state differs")
+	}
+}
+
+func TestCloseStopsPurger(t *testing.T) {
+	conf := walConf(t)
+	conf.Retention = time.Millisecond
+	conf.PurgeInterval = time.Millisecond
+
+	ms, err := NewStorage(conf, nil)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	populate(t, ms)
+
+	closed := make(chan error, 1)
+	go func() {
+		closed <- ms.Close()
+	}()
+
+	select {
+	case err := <-closed:
+		if err != nil {
+			t.Fatalf("error: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("purger wasn't stopped")
+	}
+}
+
+func TestWalSnapshotUnderLoad(t *testing.T) {
+	conf := walConf(t)
+
+	ms, err := NewStorage(conf, nil)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	var (
+		ctx   = context.Background()
+		posts []uuid.UUID
+		wg    sync.WaitGroup
+		stop  = make(chan struct{})
+	)
+
+	for range 4 {
+		post, err := ms.InsertPost(ctx, uuid.New(), storage.InPost{})
+		if err != nil {
+			t.Fatalf("error: %v", err)
+		}
+		posts = append(posts, post.Id)
+	}
+
+	for _, id := range posts {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			var parentId *uuid.UUID
+
+			for i := range 200 {
+				comm, err := ms.InsertComment(ctx, id, parentId, uuid.New(), storage.InComment{Content: "comment"})
+				if err != nil {
+					t.Errorf("error: %v", err)
+					return
+				}
+
+				if _, err := ms.VoteComment(ctx, id, comm.Id, uuid.New(), storage.VoteUp); err != nil {
+					t.Errorf("error: %v", err)
+					return
+				}
+
+				if i%10 == 0 {
+					parentId = &comm.Id
+				}
+			}
+		}()
+	}
+
+	// snapshots are taken while the posts are being written
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+
+			if err := ms.snapshot(); err != nil {
+				t.Errorf("error: %v", err)
+				return
+			}
+		}
+	}()
+
+	wg.Wait()
+	close(stop)
+	<-done
+
+	restored, err := NewStorage(conf, nil)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	if state(t, ms) != state(t, restored) {
+		t.Fatalf("restored state differs")
+	}
+}
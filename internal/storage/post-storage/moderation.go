@@ -0,0 +1,107 @@
+package storage
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type Moderator interface {
+	// applies moderation action to a post and records it in the audit log
+	// version, if provided, should match the version of the post (see Storage)
+	ModeratePost(ctx context.Context, e ModEntry, version *uint64) (*Post, error)
+	// applies moderation action to a comment and records it in the audit log
+	// version, if provided, should match the version of the comment (see Storage)
+	ModerateComment(ctx context.Context, e ModEntry, version *uint64) (*Comment, error)
+	// retrieves audit log of a post and its comments, oldest entries first
+	GetModerationLog(ctx context.Context, postId uuid.UUID) ([]ModEntry, error)
+}
+
+// action, taken by a moderator on someone's content
+type ModAction string
+
+const (
+	ModDelete  ModAction = "delete"
+	ModRestore ModAction = "restore"
+	// locked posts can't be commented on, regardless of the author's will
+	ModLock   ModAction = "lock"
+	ModUnlock ModAction = "unlock"
+	ModPin    ModAction = "pin"
+	ModUnpin  ModAction = "unpin"
+)
+
+func (a ModAction) Valid() bool {
+	switch a {
+	case ModDelete, ModRestore, ModLock, ModUnlock, ModPin, ModUnpin:
+		return true
+	}
+	return false
+}
+
+// audit log entry
+type ModEntry struct {
+	Id     uuid.UUID `json:"id"`
+	PostId uuid.UUID `json:"post_id"`
+	// nil if the action was taken on the post itself
+	CommentId *uuid.UUID `json:"comment_id"`
+	// moderator id
+	ActorId uuid.UUID `json:"actor_id"`
+	Action  ModAction `json:"action"`
+	Reason  string    `json:"reason"`
+
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// applies moderation action to the post
+// fails if the action doesn't make sense in the post's current state
+func (p *Post) Moderate(a ModAction, ts time.Time) error {
+	switch a {
+	case ModDelete:
+		if p.DeletedAt != nil {
+			return ErrPostIsDeleted
+		}
+		p.DeletedAt = &ts
+	case ModRestore:
+		if p.DeletedAt == nil {
+			return ErrPostNotDeleted
+		}
+		p.DeletedAt = nil
+	case ModLock, ModUnlock:
+		p.IsLocked = a == ModLock
+	case ModPin, ModUnpin:
+		p.IsPinned = a == ModPin
+	default:
+		return ErrBadModAction
+	}
+
+	p.Version++
+
+	return nil
+}
+
+// applies moderation action to the comment
+// fails if the action doesn't make sense in the comment's current state
+func (c *Comment) Moderate(a ModAction, ts time.Time) error {
+	switch a {
+	case ModDelete:
+		if c.DeletedAt != nil {
+			return ErrCommIsDeleted
+		}
+		c.DeletedAt = &ts
+	case ModRestore:
+		if c.DeletedAt == nil {
+			return ErrCommNotDeleted
+		}
+		c.DeletedAt = nil
+	case ModPin, ModUnpin:
+		c.IsPinned = a == ModPin
+	default:
+		// comments can't be locked
+		return ErrBadModAction
+	}
+
+	c.Version++
+
+	return nil
+}
@@ -0,0 +1,153 @@
+package storage
+
+import (
+	"bytes"
+	"cmp"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// defines ordering of paginated items
+type SortKey string
+
+const (
+	SortNewest    SortKey = "newest"
+	SortOldest    SortKey = "oldest"
+	SortUpvotes   SortKey = "upvoted"
+	SortDownvotes SortKey = "downvoted"
+	// highest difference between upvotes and downvotes first
+	SortTop SortKey = "top"
+	// highest rank of each kind first (see Ranking)
+	SortHot           SortKey = "hot"
+	SortControversial SortKey = "controversial"
+	SortBest          SortKey = "best"
+)
+
+func (k SortKey) Valid() bool {
+	switch k {
+	case SortNewest, SortOldest, SortUpvotes, SortDownvotes, SortTop, SortHot, SortControversial, SortBest:
+		return true
+	}
+	return false
+}
+
+// limits paginated items to the ones, created within the period
+type Window string
+
+const (
+	WindowAll  Window = "all"
+	WindowDay  Window = "day"
+	WindowWeek Window = "week"
+)
+
+// empty window is the same as WindowAll
+func (w Window) Valid() bool {
+	switch w {
+	case "", WindowAll, WindowDay, WindowWeek:
+		return true
+	}
+	return false
+}
+
+// length of the period (zero if items aren't limited)
+func (w Window) Duration() time.Duration {
+	switch w {
+	case WindowDay:
+		return 24 * time.Hour
+	case WindowWeek:
+		return 7 * 24 * time.Hour
+	}
+	return 0
+}
+
+// position of an item in a sorted sequence
+// only the fields relevant to the sort key are compared, id breaks ties
+type Cursor struct {
+	Id        uuid.UUID `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	Upvotes   uint64    `json:"upvotes"`
+	Downvotes uint64    `json:"downvotes"`
+
+	Ranking
+}
+
+// reports whether a should be placed before (-1) or after (+1) b
+func (k SortKey) Compare(a, b Cursor) int {
+	var (
+		res int
+		// direction
+		dir = -1
+	)
+
+	switch k {
+	case SortNewest:
+		res = a.CreatedAt.Compare(b.CreatedAt)
+	case SortOldest:
+		res, dir = a.CreatedAt.Compare(b.CreatedAt), 1
+	case SortUpvotes:
+		res = cmp.Compare(a.Upvotes, b.Upvotes)
+	case SortDownvotes:
+		res = cmp.Compare(a.Downvotes, b.Downvotes)
+	case SortTop:
+		// a.Upvotes - a.Downvotes vs b.Upvotes - b.Downvotes, rearranged to stay unsigned
+		res = cmp.Compare(a.Upvotes+b.Downvotes, b.Upvotes+a.Downvotes)
+	case SortHot:
+		res = cmp.Compare(a.Hot, b.Hot)
+	case SortControversial:
+		res = cmp.Compare(a.Controversy, b.Controversy)
+	case SortBest:
+		res = cmp.Compare(a.Confidence, b.Confidence)
+	}
+
+	if res == 0 {
+		res = bytes.Compare(a.Id[:], b.Id[:])
+	}
+
+	return dir * res
+}
+
+type PageQuery struct {
+	// max number of items on a page
+	First int
+	// position of the last item on the previous page (nil for the first page)
+	After *Cursor
+
+	SortBy SortKey
+	Window Window
+}
+
+// creation time of the earliest item, which fits the window (zero if any item fits)
+func (q PageQuery) Since(now time.Time) time.Time {
+	if d := q.Window.Duration(); d > 0 {
+		return now.Add(-d)
+	}
+
+	return time.Time{}
+}
+
+type Page[T any] struct {
+	Items []T
+
+	HasNextPage bool
+}
+
+func (p Post) Cursor() Cursor {
+	return Cursor{
+		Id:        p.Id,
+		CreatedAt: p.CreatedAt,
+		Upvotes:   p.Upvotes,
+		Downvotes: p.Downvotes,
+		Ranking:   p.Ranking,
+	}
+}
+
+func (c Comment) Cursor() Cursor {
+	return Cursor{
+		Id:        c.Id,
+		CreatedAt: c.CreatedAt,
+		Upvotes:   c.Upvotes,
+		Downvotes: c.Downvotes,
+		Ranking:   c.Ranking,
+	}
+}
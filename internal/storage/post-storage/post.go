@@ -7,9 +7,8 @@ import (
 )
 
 // input-bound Post
+// holds only the fields, which are editable by the author
 type InPost struct {
-	// creator id
-	UserId uuid.UUID `json:"user_id"`
 	// defines if other users can comment on the post
 	IsMute bool `json:"is_mute"`
 
@@ -22,9 +21,20 @@ type Post struct {
 	InPost `json:"in_post"`
 
 	Id uuid.UUID `json:"id"`
+	// author id
+	UserId uuid.UUID `json:"user_id"`
+
+	// set by moderators only
+	IsLocked bool `json:"is_locked"`
+	IsPinned bool `json:"is_pinned"`
 
 	Upvotes   uint64 `json:"upvotes"`
 	Downvotes uint64 `json:"downvotes"`
+	// derived from votes and age, so it isn't persisted alongside the post
+	Ranking Ranking `json:"-"`
+
+	// incremented on every change of the post, except for votes
+	Version uint64 `json:"version"`
 
 	CreatedAt time.Time  `json:"created_at"`
 	UpdatedAt time.Time  `json:"updated_at"`
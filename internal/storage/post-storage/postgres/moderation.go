@@ -0,0 +1,127 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	storage "github.com/cutlery47/posts/internal/storage/post-storage"
+	"github.com/google/uuid"
+)
+
+func (pg *pgStorage) ModeratePost(ctx context.Context, e storage.ModEntry, version *uint64) (*storage.Post, error) {
+	var (
+		upd *storage.Post
+	)
+
+	err := pg.withTx(ctx, func(tx *sql.Tx) error {
+		post, err := getPost(ctx, tx, getPostForUpdateQuery, e.PostId)
+		if err != nil {
+			return err
+		}
+
+		if err := storage.CheckVersion(version, post.Version); err != nil {
+			return err
+		}
+
+		if err := post.Moderate(e.Action, time.Now()); err != nil {
+			return err
+		}
+
+		row := tx.QueryRowContext(ctx, moderatePostQuery, e.PostId, post.DeletedAt != nil, post.IsLocked, post.IsPinned)
+
+		upd, err = scanPost(row)
+		if err != nil {
+			return err
+		}
+
+		return record(ctx, tx, e)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return pg.withComments(ctx, upd)
+}
+
+func (pg *pgStorage) ModerateComment(ctx context.Context, e storage.ModEntry, version *uint64) (*storage.Comment, error) {
+	var (
+		upd *storage.Comment
+	)
+
+	if e.CommentId == nil {
+		return nil, storage.ErrCommNotFound
+	}
+
+	err := pg.withTx(ctx, func(tx *sql.Tx) error {
+		if _, err := getPost(ctx, tx, getPostForShareQuery, e.PostId); err != nil {
+			return err
+		}
+
+		comm, err := getComment(ctx, tx, getCommentForUpdateQuery, e.PostId, *e.CommentId)
+		if err != nil {
+			return err
+		}
+
+		if err := storage.CheckVersion(version, comm.Version); err != nil {
+			return err
+		}
+
+		if err := comm.Moderate(e.Action, time.Now()); err != nil {
+			return err
+		}
+
+		row := tx.QueryRowContext(ctx, moderateCommentQuery, comm.Id, comm.DeletedAt != nil, comm.IsPinned)
+
+		upd, err = scanComment(row)
+		if err != nil {
+			return err
+		}
+
+		return record(ctx, tx, e)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return pg.withReplies(ctx, upd)
+}
+
+func (pg *pgStorage) GetModerationLog(ctx context.Context, postId uuid.UUID) ([]storage.ModEntry, error) {
+	if _, err := getPost(ctx, pg.db, getPostQuery, postId); err != nil {
+		return nil, err
+	}
+
+	rows, err := pg.db.QueryContext(ctx, getModerationLogQuery, postId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var (
+		log = []storage.ModEntry{}
+	)
+
+	for rows.Next() {
+		var e storage.ModEntry
+
+		err := rows.Scan(&e.Id, &e.PostId, &e.CommentId, &e.ActorId, &e.Action, &e.Reason, &e.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+
+		log = append(log, e)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return log, nil
+}
+
+// appends an entry to the audit log
+func record(ctx context.Context, q querier, e storage.ModEntry) error {
+	_, err := q.ExecContext(ctx, insertModEntryQuery, e.PostId, e.CommentId, e.ActorId, e.Action, e.Reason)
+	return err
+}
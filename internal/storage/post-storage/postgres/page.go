@@ -0,0 +1,111 @@
+package postgres
+
+import (
+	"fmt"
+
+	storage "github.com/cutlery47/posts/internal/storage/post-storage"
+)
+
+// sort column and direction for each of the sort keys
+var orderings = map[storage.SortKey]struct {
+	column string
+	desc   bool
+}{
+	storage.SortNewest:    {"created_at", true},
+	storage.SortOldest:    {"created_at", false},
+	storage.SortUpvotes:   {"upvotes", true},
+	storage.SortDownvotes: {"downvotes", true},
+	storage.SortTop:       {"(upvotes - downvotes)", true},
+	// generated columns, see storage.Rank
+	storage.SortHot:           {"hot", true},
+	storage.SortControversial: {"controversy", true},
+	storage.SortBest:          {"confidence", true},
+}
+
+// builds keyset pagination clause, which is appended to a query with given args
+// the resulting clause is positioned right after WHERE conditions and includes ORDER BY and LIMIT
+func seek(q storage.PageQuery, args []any) (string, []any, error) {
+	ord, ok := orderings[q.SortBy]
+	if !ok {
+		return "", nil, fmt.Errorf("undefined sort key: %v", q.SortBy)
+	}
+
+	var (
+		clause string
+		op     = ">"
+		dir    = "ASC"
+	)
+
+	if ord.desc {
+		op, dir = "<", "DESC"
+	}
+
+	// created_at is stored without time zone, which is UTC for every session (see pgconn.Connect)
+	if d := q.Window.Duration(); d > 0 {
+		args = append(args, d.Seconds())
+		clause = fmt.Sprintf(" AND created_at >= LOCALTIMESTAMP - $%v * INTERVAL '1 second'", len(args))
+	}
+
+	if q.After != nil {
+		var val any
+		switch ord.column {
+		case "created_at":
+			val = q.After.CreatedAt
+		case "upvotes":
+			val = q.After.Upvotes
+		case "downvotes":
+			val = q.After.Downvotes
+		case "(upvotes - downvotes)":
+			val = int64(q.After.Upvotes) - int64(q.After.Downvotes)
+		case "hot":
+			val = q.After.Hot
+		case "controversy":
+			val = q.After.Controversy
+		case "confidence":
+			val = q.After.Confidence
+		}
+
+		args = append(args, val, q.After.Id)
+		clause += fmt.Sprintf(" AND (%v, id) %v ($%v, $%v)", ord.column, op, len(args)-1, len(args))
+	}
+
+	args = append(args, q.First+1)
+	clause += fmt.Sprintf(" ORDER BY %v %v, id %v LIMIT $%v", ord.column, dir, dir, len(args))
+
+	return clause, args, nil
+}
+
+// trims extra item, which was requested in order to find out if there's a next page
+func toPage[T any](items []T, first int) *storage.Page[T] {
+	page := &storage.Page[T]{
+		Items:       items,
+		HasNextPage: len(items) > first,
+	}
+
+	if page.HasNextPage {
+		page.Items = page.Items[:first]
+	}
+
+	if page.Items == nil {
+		page.Items = []T{}
+	}
+
+	return page
+}
+
+// builds keyset pagination clause for search hits, ordered by rank
+func seekHits(q storage.SearchQuery, args []any) (string, []any) {
+	var (
+		clause string
+	)
+
+	if q.After != nil {
+		args = append(args, q.After.Rank, q.After.Id)
+		clause = fmt.Sprintf(" AND (rank < $%v OR (rank = $%v AND id > $%v))", len(args)-1, len(args)-1, len(args))
+	}
+
+	args = append(args, q.Limit+1)
+	clause += fmt.Sprintf(" ORDER BY rank DESC, id ASC LIMIT $%v", len(args))
+
+	return clause, args
+}
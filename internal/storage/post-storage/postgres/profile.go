@@ -0,0 +1,44 @@
+package postgres
+
+import (
+	"context"
+
+	storage "github.com/cutlery47/posts/internal/storage/post-storage"
+	"github.com/google/uuid"
+)
+
+func (pg *pgStorage) GetPostsByAuthor(ctx context.Context, userId uuid.UUID, q storage.PageQuery) (*storage.Page[storage.Post], error) {
+	return pg.postsPage(ctx, getPostsByAuthorQuery, []any{userId}, q)
+}
+
+func (pg *pgStorage) GetCommentsByAuthor(ctx context.Context, userId uuid.UUID, q storage.PageQuery) (*storage.Page[storage.Comment], error) {
+	clause, args, err := seek(q, []any{userId})
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := pg.db.QueryContext(ctx, getCommentsByAuthorQuery+clause, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	page, err := scanCountedComments(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	return toPage(page, q.First), nil
+}
+
+func (pg *pgStorage) GetKarma(ctx context.Context, userId uuid.UUID) (int64, error) {
+	var (
+		karma int64
+	)
+
+	err := pg.db.QueryRowContext(ctx, getKarmaQuery, userId).Scan(&karma)
+	if err != nil {
+		return 0, err
+	}
+
+	return karma, nil
+}
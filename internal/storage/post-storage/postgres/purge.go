@@ -0,0 +1,49 @@
+package postgres
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/cutlery47/posts/internal/metrics"
+	storage "github.com/cutlery47/posts/internal/storage/post-storage"
+)
+
+func (pg *pgStorage) Purge(ctx context.Context, before time.Time) (*storage.PurgeStats, error) {
+	var (
+		stats storage.PurgeStats
+	)
+
+	err := pg.db.QueryRowContext(ctx, purgeQuery, before).Scan(&stats.Posts, &stats.Comments, &stats.Bytes)
+	if err != nil {
+		return nil, err
+	}
+
+	return &stats, nil
+}
+
+// periodically purges content, which has been deleted for longer than the retention period
+// stops once the storage is closed
+func (pg *pgStorage) purge() {
+	defer pg.workers.Done()
+
+	for {
+		select {
+		case <-time.After(pg.conf.PurgeInterval):
+		case <-pg.done:
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), pg.conf.Timeout)
+
+		stats, err := pg.Purge(ctx, time.Now().Add(-pg.conf.Retention))
+		if err != nil {
+			log.Println("[PURGER] couldn't purge deleted content:", err)
+			metrics.ObservePurge(0, 0, 0, err)
+		} else {
+			metrics.ObservePurge(stats.Posts, stats.Comments, stats.Bytes, nil)
+		}
+
+		cancel()
+	}
+}
@@ -0,0 +1,531 @@
+package postgres
+
+const postColumns = `
+		id
+		, user_id
+		, is_mute
+		, is_locked
+		, is_pinned
+		, content
+		, upvotes
+		, downvotes
+		, hot
+		, controversy
+		, confidence
+		, version
+		, created_at
+		, updated_at
+		, deleted_at
+`
+
+const commentColumns = `
+		id
+		, post_id
+		, parent_id
+		, depth
+		, user_id
+		, is_pinned
+		, content
+		, upvotes
+		, downvotes
+		, hot
+		, controversy
+		, confidence
+		, version
+		, created_at
+		, updated_at
+		, deleted_at
+`
+
+const getPostQuery = `
+	SELECT ` + postColumns + `
+	FROM
+		posts.post
+	WHERE
+		id=$1
+`
+
+const getPostForUpdateQuery = getPostQuery + `
+	FOR UPDATE
+`
+
+const getPostsQuery = `
+	SELECT ` + postColumns + `
+	FROM
+		posts.post
+`
+
+const insertPostQuery = `
+	INSERT INTO posts.post (
+		user_id
+		, is_mute
+		, content
+	) VALUES (
+		$1, $2, $3
+	) RETURNING ` + postColumns
+
+const deletePostQuery = `
+	UPDATE
+		posts.post
+	SET
+		deleted_at=CURRENT_TIMESTAMP
+		, version=version+1
+	WHERE
+		id=$1
+`
+
+const updatePostQuery = `
+	UPDATE
+		posts.post
+	SET
+		is_mute=$2
+		, content=$3
+		, updated_at=CURRENT_TIMESTAMP
+		, version=version+1
+	WHERE
+		id=$1
+	RETURNING ` + postColumns
+
+const getCommentQuery = `
+	SELECT ` + commentColumns + `
+	FROM
+		posts.comment
+	WHERE
+		id=$1 AND post_id=$2
+`
+
+const getCommentForUpdateQuery = getCommentQuery + `
+	FOR UPDATE
+`
+
+const getPostCommentsQuery = `
+	SELECT ` + commentColumns + `
+	FROM
+		posts.comment
+	WHERE
+		post_id=$1
+`
+
+const getCommentsQuery = `
+	SELECT ` + commentColumns + `
+	FROM
+		posts.comment
+`
+
+// depth is derived from the parent (top-level comments have no parent)
+const insertCommentQuery = `
+	INSERT INTO posts.comment (
+		post_id
+		, parent_id
+		, depth
+		, user_id
+		, content
+	) VALUES (
+		$1, $2, COALESCE((SELECT depth + 1 FROM posts.comment WHERE id=$2), 1), $3, $4
+	) RETURNING ` + commentColumns
+
+const deleteCommentQuery = `
+	UPDATE
+		posts.comment
+	SET
+		deleted_at=CURRENT_TIMESTAMP
+		, version=version+1
+	WHERE
+		id=$1
+`
+
+const updateCommentQuery = `
+	UPDATE
+		posts.comment
+	SET
+		content=$2
+		, updated_at=CURRENT_TIMESTAMP
+		, version=version+1
+	WHERE
+		id=$1
+	RETURNING ` + commentColumns
+
+const getPostForShareQuery = getPostQuery + `
+	FOR SHARE
+`
+
+const getPostVoteQuery = `
+	SELECT
+		vote
+	FROM
+		posts.post_vote
+	WHERE
+		post_id=$1 AND user_id=$2
+`
+
+const upsertPostVoteQuery = `
+	INSERT INTO posts.post_vote (
+		post_id
+		, user_id
+		, vote
+	) VALUES (
+		$1, $2, $3
+	) ON CONFLICT (post_id, user_id) DO UPDATE SET
+		vote=EXCLUDED.vote
+`
+
+const deletePostVoteQuery = `
+	DELETE FROM
+		posts.post_vote
+	WHERE
+		post_id=$1 AND user_id=$2
+`
+
+const tallyPostQuery = `
+	UPDATE
+		posts.post
+	SET
+		upvotes=upvotes + $2
+		, downvotes=downvotes + $3
+	WHERE
+		id=$1
+	RETURNING ` + postColumns
+
+const getCommentVoteQuery = `
+	SELECT
+		vote
+	FROM
+		posts.comment_vote
+	WHERE
+		comment_id=$1 AND user_id=$2
+`
+
+const upsertCommentVoteQuery = `
+	INSERT INTO posts.comment_vote (
+		comment_id
+		, user_id
+		, vote
+	) VALUES (
+		$1, $2, $3
+	) ON CONFLICT (comment_id, user_id) DO UPDATE SET
+		vote=EXCLUDED.vote
+`
+
+const deleteCommentVoteQuery = `
+	DELETE FROM
+		posts.comment_vote
+	WHERE
+		comment_id=$1 AND user_id=$2
+`
+
+const tallyCommentQuery = `
+	UPDATE
+		posts.comment
+	SET
+		upvotes=upvotes + $2
+		, downvotes=downvotes + $3
+	WHERE
+		id=$1
+	RETURNING ` + commentColumns
+
+const getPostsPageQuery = `
+	SELECT ` + postColumns + `
+	FROM
+		posts.post
+	WHERE
+		TRUE
+`
+
+const getCommentsOfPostsQuery = `
+	SELECT ` + commentColumns + `
+	FROM
+		posts.comment
+	WHERE
+		post_id=ANY($1)
+`
+
+// number of direct replies, which follows comment columns on pages of comments
+const replyCountColumn = `
+		, (SELECT COUNT(*) FROM posts.comment r WHERE r.parent_id = c.id)
+`
+
+// $1 - post id, $2 - parent id
+// parent is compared with = rather than IS NOT DISTINCT FROM, so that the page is read off the index
+const getCommentsPageQuery = `
+	SELECT ` + commentColumns + replyCountColumn + `
+	FROM
+		posts.comment c
+	WHERE
+		post_id=$1 AND parent_id=$2
+`
+
+// $1 - post id
+const getRootCommentsPageQuery = `
+	SELECT ` + commentColumns + replyCountColumn + `
+	FROM
+		posts.comment c
+	WHERE
+		post_id=$1 AND parent_id IS NULL
+`
+
+// $1 - author id
+// should be followed by a seek clause
+const getPostsByAuthorQuery = `
+	SELECT ` + postColumns + `
+	FROM
+		posts.post
+	WHERE
+		user_id=$1 AND deleted_at IS NULL
+`
+
+// $1 - author id
+// should be followed by a seek clause
+const getCommentsByAuthorQuery = `
+	SELECT ` + commentColumns + replyCountColumn + `
+	FROM
+		posts.comment c
+	WHERE
+		user_id=$1 AND deleted_at IS NULL
+`
+
+const getKarmaQuery = `
+	SELECT
+		(
+			SELECT COALESCE(SUM(upvotes - downvotes), 0)::BIGINT FROM posts.post WHERE user_id=$1 AND deleted_at IS NULL
+		) + (
+			SELECT COALESCE(SUM(upvotes - downvotes), 0)::BIGINT FROM posts.comment WHERE user_id=$1 AND deleted_at IS NULL
+		)
+`
+
+// $1 - query, $2 - search kind
+// should be followed by a seek clause
+const searchQuery = `
+	WITH q AS (
+		SELECT plainto_tsquery('simple', $1) AS query
+	), hits AS (
+		SELECT
+			'post' AS kind
+			, p.id
+			, p.id AS post_id
+			, ts_rank(p.tsv, q.query)::FLOAT8 AS rank
+			, p.content
+		FROM
+			posts.post p, q
+		WHERE
+			$2 IN ('all', 'post') AND p.deleted_at IS NULL AND p.tsv @@ q.query
+		UNION ALL
+		SELECT
+			'comment' AS kind
+			, c.id
+			, c.post_id
+			, ts_rank(c.tsv, q.query)::FLOAT8 AS rank
+			, c.content
+		FROM
+			posts.comment c JOIN posts.post p ON p.id = c.post_id, q
+		WHERE
+			$2 IN ('all', 'comment') AND c.deleted_at IS NULL AND p.deleted_at IS NULL AND c.tsv @@ q.query
+	)
+	SELECT
+		kind
+		, id
+		, post_id
+		, rank
+		, content
+	FROM
+		hits
+	WHERE
+		TRUE
+`
+
+// content of a search hit, escaped the same way, as html.EscapeString does it
+const escapedContent = `REPLACE(REPLACE(REPLACE(REPLACE(REPLACE(page.content, '&', '&amp;'), '<', '&lt;'), '>', '&gt;'), '"', '&#34;'), '''', '&#39;')`
+
+// wraps a page of search hits, so that headlines are built only for the returned rows
+// default headline selectors are the same as storage.HighlightStart and storage.HighlightStop
+// content is escaped beforehand, so that the selectors are the only markup in the headline
+const headlineQuery = `
+	SELECT
+		page.kind
+		, page.id
+		, page.post_id
+		, page.rank
+		, ts_headline('simple', ` + escapedContent + `, plainto_tsquery('simple', $1))
+	FROM (%v) page
+	ORDER BY
+		page.rank DESC, page.id ASC
+`
+
+// deleted_at is toggled on the db side, so that all of the timestamps come from the same clock
+const moderatePostQuery = `
+	UPDATE
+		posts.post
+	SET
+		deleted_at=CASE WHEN $2 THEN COALESCE(deleted_at, CURRENT_TIMESTAMP) END
+		, is_locked=$3
+		, is_pinned=$4
+		, version=version+1
+	WHERE
+		id=$1
+	RETURNING ` + postColumns
+
+const moderateCommentQuery = `
+	UPDATE
+		posts.comment
+	SET
+		deleted_at=CASE WHEN $2 THEN COALESCE(deleted_at, CURRENT_TIMESTAMP) END
+		, is_pinned=$3
+		, version=version+1
+	WHERE
+		id=$1
+	RETURNING ` + commentColumns
+
+const modEntryColumns = `
+		id
+		, post_id
+		, comment_id
+		, actor_id
+		, action
+		, reason
+		, created_at
+`
+
+const insertModEntryQuery = `
+	INSERT INTO posts.moderation (
+		post_id
+		, comment_id
+		, actor_id
+		, action
+		, reason
+	) VALUES (
+		$1, $2, $3, $4, $5
+	)
+`
+
+const getModerationLogQuery = `
+	SELECT ` + modEntryColumns + `
+	FROM
+		posts.moderation
+	WHERE
+		post_id=$1
+	ORDER BY
+		created_at ASC, id ASC
+`
+
+const restorePostQuery = `
+	UPDATE
+		posts.post
+	SET
+		deleted_at=NULL
+		, version=version+1
+	WHERE
+		id=$1
+	RETURNING ` + postColumns
+
+const restoreCommentQuery = `
+	UPDATE
+		posts.comment
+	SET
+		deleted_at=NULL
+		, version=version+1
+	WHERE
+		id=$1
+	RETURNING ` + commentColumns
+
+const getTrashPostsQuery = `
+	SELECT ` + postColumns + `
+	FROM
+		posts.post
+	WHERE
+		user_id=$1 AND deleted_at IS NOT NULL
+	ORDER BY
+		deleted_at DESC
+`
+
+const getTrashCommentsQuery = `
+	SELECT ` + commentColumns + `
+	FROM
+		posts.comment
+	WHERE
+		user_id=$1 AND deleted_at IS NOT NULL
+	ORDER BY
+		deleted_at DESC
+`
+
+// $1 - deletion threshold
+// comments of purged posts and replies to purged comments are removed by cascade,
+// yet they're still selected, so that they are accounted for
+const purgeQuery = `
+	WITH RECURSIVE expired_post AS (
+		SELECT
+			id
+			, content
+		FROM
+			posts.post
+		WHERE
+			deleted_at < $1
+	), expired_comment AS (
+		SELECT
+			id
+			, content
+		FROM
+			posts.comment
+		WHERE
+			deleted_at < $1 OR post_id IN (SELECT id FROM expired_post)
+		UNION
+		SELECT
+			c.id
+			, c.content
+		FROM
+			posts.comment c JOIN expired_comment e ON c.parent_id = e.id
+	), purged_post AS (
+		DELETE FROM
+			posts.post
+		WHERE
+			id IN (SELECT id FROM expired_post)
+	), purged_comment AS (
+		DELETE FROM
+			posts.comment
+		WHERE
+			id IN (SELECT id FROM expired_comment)
+	)
+	SELECT
+		(SELECT COUNT(*) FROM expired_post)
+		, (SELECT COUNT(*) FROM expired_comment)
+		, (SELECT COALESCE(SUM(octet_length(content)), 0) FROM expired_post)
+			+ (SELECT COALESCE(SUM(octet_length(content)), 0) FROM expired_comment)
+`
+
+const revisionColumns = `
+		id
+		, post_id
+		, comment_id
+		, editor_id
+		, content
+		, created_at
+`
+
+const insertRevisionQuery = `
+	INSERT INTO posts.revision (
+		id
+		, post_id
+		, comment_id
+		, editor_id
+		, content
+		, created_at
+	) VALUES (
+		$1, $2, $3, $4, $5, $6
+	)
+`
+
+const getRevisionsQuery = `
+	SELECT ` + revisionColumns + `
+	FROM
+		posts.revision
+	WHERE
+		post_id=$1 AND comment_id IS NOT DISTINCT FROM $2
+	ORDER BY
+		created_at ASC, id ASC
+`
+
+const getRevisionQuery = `
+	SELECT ` + revisionColumns + `
+	FROM
+		posts.revision
+	WHERE
+		id=$1 AND post_id=$2 AND comment_id IS NOT DISTINCT FROM $3
+`
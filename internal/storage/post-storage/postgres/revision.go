@@ -0,0 +1,150 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	storage "github.com/cutlery47/posts/internal/storage/post-storage"
+	"github.com/google/uuid"
+)
+
+func (pg *pgStorage) GetRevisions(ctx context.Context, postId uuid.UUID, commentId *uuid.UUID) ([]storage.Revision, error) {
+	if _, err := getPost(ctx, pg.db, getPostQuery, postId); err != nil {
+		return nil, err
+	}
+
+	if commentId != nil {
+		if _, err := getComment(ctx, pg.db, getCommentQuery, postId, *commentId); err != nil {
+			return nil, err
+		}
+	}
+
+	rows, err := pg.db.QueryContext(ctx, getRevisionsQuery, postId, commentId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var (
+		revs = []storage.Revision{}
+	)
+
+	for rows.Next() {
+		rev, err := scanRevision(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		revs = append(revs, *rev)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return revs, nil
+}
+
+func (pg *pgStorage) RevertPost(ctx context.Context, postId, revisionId, editorId uuid.UUID) (*storage.Post, error) {
+	var (
+		upd *storage.Post
+	)
+
+	err := pg.withTx(ctx, func(tx *sql.Tx) error {
+		post, err := getPost(ctx, tx, getPostForUpdateQuery, postId)
+		if err != nil {
+			return err
+		}
+
+		if post.DeletedAt != nil {
+			return storage.ErrPostIsDeleted
+		}
+
+		rev, err := getRevision(ctx, tx, revisionId, postId, nil)
+		if err != nil {
+			return err
+		}
+
+		upd, err = scanPost(tx.QueryRowContext(ctx, updatePostQuery, postId, post.IsMute, rev.Content))
+		if err != nil {
+			return err
+		}
+
+		return revise(ctx, tx, storage.PostRevision(*upd, editorId, upd.UpdatedAt))
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return pg.withComments(ctx, upd)
+}
+
+func (pg *pgStorage) RevertComment(ctx context.Context, postId, commentId, revisionId, editorId uuid.UUID) (*storage.Comment, error) {
+	var (
+		upd *storage.Comment
+	)
+
+	err := pg.withTx(ctx, func(tx *sql.Tx) error {
+		if _, err := getPost(ctx, tx, getPostQuery, postId); err != nil {
+			return err
+		}
+
+		comm, err := getComment(ctx, tx, getCommentForUpdateQuery, postId, commentId)
+		if err != nil {
+			return err
+		}
+
+		if comm.DeletedAt != nil {
+			return storage.ErrCommIsDeleted
+		}
+
+		rev, err := getRevision(ctx, tx, revisionId, postId, &commentId)
+		if err != nil {
+			return err
+		}
+
+		upd, err = scanComment(tx.QueryRowContext(ctx, updateCommentQuery, commentId, rev.Content))
+		if err != nil {
+			return err
+		}
+
+		return revise(ctx, tx, storage.CommentRevision(*upd, editorId, upd.UpdatedAt))
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return pg.withReplies(ctx, upd)
+}
+
+func getRevision(ctx context.Context, q querier, id, postId uuid.UUID, commentId *uuid.UUID) (*storage.Revision, error) {
+	rev, err := scanRevision(q.QueryRowContext(ctx, getRevisionQuery, id, postId, commentId))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, storage.ErrRevNotFound
+		}
+		return nil, err
+	}
+
+	return rev, nil
+}
+
+func scanRevision(s scanner) (*storage.Revision, error) {
+	var (
+		rev storage.Revision
+	)
+
+	err := s.Scan(&rev.Id, &rev.PostId, &rev.CommentId, &rev.EditorId, &rev.Content, &rev.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	return &rev, nil
+}
+
+// appends a revision to the edit history of its item
+func revise(ctx context.Context, q querier, r storage.Revision) error {
+	_, err := q.ExecContext(ctx, insertRevisionQuery, r.Id, r.PostId, r.CommentId, r.EditorId, r.Content, r.CreatedAt)
+	return err
+}
@@ -1,7 +1,616 @@
 package postgres
 
-import storage "github.com/cutlery47/posts/internal/storage/post-storage"
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync"
 
-func NewStorage() (storage.Storage, error) {
-	return nil, storage.ErrNotImplemented
+	"github.com/cutlery47/posts/config"
+	storage "github.com/cutlery47/posts/internal/storage/post-storage"
+	"github.com/cutlery47/posts/pkg/pgconn"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+type pgStorage struct {
+	db *sql.DB
+
+	// stops background purges
+	done      chan struct{}
+	closeOnce sync.Once
+	// running purger, which is waited for on close
+	workers sync.WaitGroup
+
+	conf config.PostStorage
+}
+
+func NewStorage(conf config.PostStorage) (*pgStorage, error) {
+	db, err := pgconn.Connect(conf.Postgres)
+	if err != nil {
+		return nil, err
+	}
+
+	pg := &pgStorage{
+		db:   db,
+		done: make(chan struct{}),
+		conf: conf,
+	}
+
+	if conf.Retention > 0 && conf.PurgeInterval > 0 {
+		pg.workers.Add(1)
+		go pg.purge()
+	}
+
+	return pg, nil
+}
+
+func (pg *pgStorage) Ready(ctx context.Context) error {
+	return pg.db.PingContext(ctx)
+}
+
+// stops the purger, letting the running purge finish, and closes the connection
+func (pg *pgStorage) Close() error {
+	pg.closeOnce.Do(func() {
+		close(pg.done)
+	})
+
+	pg.workers.Wait()
+
+	return pg.db.Close()
+}
+
+func (pg *pgStorage) GetPost(ctx context.Context, id uuid.UUID) (*storage.Post, error) {
+	post, err := getPost(ctx, pg.db, getPostQuery, id)
+	if err != nil {
+		return nil, err
+	}
+
+	return pg.withComments(ctx, post)
+}
+
+func (pg *pgStorage) GetPosts(ctx context.Context) ([]storage.Post, error) {
+	rows, err := pg.db.QueryContext(ctx, getPostsQuery)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var (
+		posts = []storage.Post{}
+	)
+
+	for rows.Next() {
+		post, err := scanPost(rows)
+		if err != nil {
+			return nil, err
+		}
+		posts = append(posts, *post)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rows, err = pg.db.QueryContext(ctx, getCommentsQuery)
+	if err != nil {
+		return nil, err
+	}
+
+	comms, err := scanComments(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	plantTrees(posts, comms)
+
+	return posts, nil
+}
+
+func (pg *pgStorage) GetPostsPage(ctx context.Context, q storage.PageQuery) (*storage.Page[storage.Post], error) {
+	return pg.postsPage(ctx, getPostsPageQuery, nil, q)
+}
+
+// selects a page of posts alongside their comment trees
+// query is followed by a seek clause, args are the ones of the query itself
+func (pg *pgStorage) postsPage(ctx context.Context, query string, args []any, q storage.PageQuery) (*storage.Page[storage.Post], error) {
+	clause, args, err := seek(q, args)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := pg.db.QueryContext(ctx, query+clause, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var (
+		posts []storage.Post
+		ids   []uuid.UUID
+	)
+
+	for rows.Next() {
+		post, err := scanPost(rows)
+		if err != nil {
+			return nil, err
+		}
+		posts = append(posts, *post)
+		ids = append(ids, post.Id)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rows, err = pg.db.QueryContext(ctx, getCommentsOfPostsQuery, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+
+	comms, err := scanComments(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	plantTrees(posts, comms)
+
+	return toPage(posts, q.First), nil
+}
+
+func (pg *pgStorage) InsertPost(ctx context.Context, userId uuid.UUID, in storage.InPost) (*storage.Post, error) {
+	var (
+		post *storage.Post
+	)
+
+	err := pg.withTx(ctx, func(tx *sql.Tx) error {
+		var err error
+
+		post, err = scanPost(tx.QueryRowContext(ctx, insertPostQuery, userId, in.IsMute, in.Content))
+		if err != nil {
+			return err
+		}
+
+		return revise(ctx, tx, storage.PostRevision(*post, userId, post.CreatedAt))
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return post, nil
+}
+
+func (pg *pgStorage) DeletePost(ctx context.Context, id uuid.UUID, version *uint64) (*uuid.UUID, error) {
+	err := pg.withTx(ctx, func(tx *sql.Tx) error {
+		post, err := getPost(ctx, tx, getPostForUpdateQuery, id)
+		if err != nil {
+			return err
+		}
+
+		if err := storage.CheckVersion(version, post.Version); err != nil {
+			return err
+		}
+
+		if post.DeletedAt != nil {
+			return storage.ErrPostIsDeleted
+		}
+
+		_, err = tx.ExecContext(ctx, deletePostQuery, id)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &id, nil
+}
+
+func (pg *pgStorage) UpdatePost(ctx context.Context, id uuid.UUID, in storage.InPost, version *uint64) (*storage.Post, error) {
+	var (
+		upd *storage.Post
+	)
+
+	err := pg.withTx(ctx, func(tx *sql.Tx) error {
+		post, err := getPost(ctx, tx, getPostForUpdateQuery, id)
+		if err != nil {
+			return err
+		}
+
+		if err := storage.CheckVersion(version, post.Version); err != nil {
+			return err
+		}
+
+		if post.DeletedAt != nil {
+			return storage.ErrPostIsDeleted
+		}
+
+		upd, err = scanPost(tx.QueryRowContext(ctx, updatePostQuery, id, in.IsMute, in.Content))
+		if err != nil || upd.Content == post.Content {
+			return err
+		}
+
+		// only the author edits the post
+		return revise(ctx, tx, storage.PostRevision(*upd, upd.UserId, upd.UpdatedAt))
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return pg.withComments(ctx, upd)
+}
+
+func (pg *pgStorage) VotePost(ctx context.Context, id, userId uuid.UUID, vote storage.Vote) (*storage.Post, error) {
+	var (
+		upd *storage.Post
+	)
+
+	err := pg.withTx(ctx, func(tx *sql.Tx) error {
+		post, err := getPost(ctx, tx, getPostForUpdateQuery, id)
+		if err != nil {
+			return err
+		}
+
+		if post.DeletedAt != nil {
+			return storage.ErrPostIsDeleted
+		}
+
+		prev, err := cast(ctx, tx, postBallot, id, userId, vote)
+		if err != nil {
+			return err
+		}
+
+		up, down := storage.Tally(prev, vote)
+
+		upd, err = scanPost(tx.QueryRowContext(ctx, tallyPostQuery, id, up, down))
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return pg.withComments(ctx, upd)
+}
+
+func (pg *pgStorage) GetComment(ctx context.Context, postId, commentId uuid.UUID) (*storage.Comment, error) {
+	if _, err := getPost(ctx, pg.db, getPostQuery, postId); err != nil {
+		return nil, err
+	}
+
+	comm, err := getComment(ctx, pg.db, getCommentQuery, postId, commentId)
+	if err != nil {
+		return nil, err
+	}
+
+	return pg.withReplies(ctx, comm)
+}
+
+func (pg *pgStorage) GetCommentsPage(ctx context.Context, postId uuid.UUID, parentId *uuid.UUID, q storage.PageQuery) (*storage.Page[storage.Comment], error) {
+	if _, err := getPost(ctx, pg.db, getPostQuery, postId); err != nil {
+		return nil, err
+	}
+
+	var (
+		query = getRootCommentsPageQuery
+		args  = []any{postId}
+	)
+
+	if parentId != nil {
+		if _, err := getComment(ctx, pg.db, getCommentQuery, postId, *parentId); err != nil {
+			return nil, err
+		}
+
+		query = getCommentsPageQuery
+		args = append(args, *parentId)
+	}
+
+	clause, args, err := seek(q, args)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := pg.db.QueryContext(ctx, query+clause, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	page, err := scanCountedComments(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	return toPage(page, q.First), nil
+}
+
+func (pg *pgStorage) InsertComment(ctx context.Context, postId uuid.UUID, parentId *uuid.UUID, userId uuid.UUID, in storage.InComment) (*storage.Comment, error) {
+	var (
+		comm *storage.Comment
+	)
+
+	err := pg.withTx(ctx, func(tx *sql.Tx) error {
+		post, err := getPost(ctx, tx, getPostForShareQuery, postId)
+		if err != nil {
+			return err
+		}
+
+		if post.DeletedAt != nil {
+			return storage.ErrPostIsDeleted
+		}
+
+		if post.IsMute {
+			return storage.ErrPostIsMute
+		}
+
+		if post.IsLocked {
+			return storage.ErrPostIsLocked
+		}
+
+		if parentId != nil {
+			parent, err := getComment(ctx, tx, getCommentForUpdateQuery, postId, *parentId)
+			if err != nil {
+				return err
+			}
+
+			if parent.DeletedAt != nil {
+				return storage.ErrCommIsDeleted
+			}
+
+			if pg.conf.MaxCommentDepth > 0 && parent.Depth >= pg.conf.MaxCommentDepth {
+				return storage.ErrCommTooDeep
+			}
+		}
+
+		comm, err = scanComment(tx.QueryRowContext(ctx, insertCommentQuery, postId, parentId, userId, in.Content))
+		if err != nil {
+			return err
+		}
+
+		return revise(ctx, tx, storage.CommentRevision(*comm, userId, comm.CreatedAt))
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return comm, nil
+}
+
+func (pg *pgStorage) DeleteComment(ctx context.Context, postId, commentId uuid.UUID, version *uint64) (*uuid.UUID, error) {
+	err := pg.withTx(ctx, func(tx *sql.Tx) error {
+		if _, err := getPost(ctx, tx, getPostQuery, postId); err != nil {
+			return err
+		}
+
+		comm, err := getComment(ctx, tx, getCommentForUpdateQuery, postId, commentId)
+		if err != nil {
+			return err
+		}
+
+		if err := storage.CheckVersion(version, comm.Version); err != nil {
+			return err
+		}
+
+		if comm.DeletedAt != nil {
+			return storage.ErrCommIsDeleted
+		}
+
+		_, err = tx.ExecContext(ctx, deleteCommentQuery, commentId)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &commentId, nil
+}
+
+func (pg *pgStorage) UpdateComment(ctx context.Context, postId, commentId uuid.UUID, in storage.InComment, version *uint64) (*storage.Comment, error) {
+	var (
+		upd *storage.Comment
+	)
+
+	err := pg.withTx(ctx, func(tx *sql.Tx) error {
+		if _, err := getPost(ctx, tx, getPostQuery, postId); err != nil {
+			return err
+		}
+
+		comm, err := getComment(ctx, tx, getCommentForUpdateQuery, postId, commentId)
+		if err != nil {
+			return err
+		}
+
+		if err := storage.CheckVersion(version, comm.Version); err != nil {
+			return err
+		}
+
+		if comm.DeletedAt != nil {
+			return storage.ErrCommIsDeleted
+		}
+
+		upd, err = scanComment(tx.QueryRowContext(ctx, updateCommentQuery, commentId, in.Content))
+		if err != nil || upd.Content == comm.Content {
+			return err
+		}
+
+		// only the author edits the comment
+		return revise(ctx, tx, storage.CommentRevision(*upd, upd.UserId, upd.UpdatedAt))
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return pg.withReplies(ctx, upd)
+}
+
+func (pg *pgStorage) VoteComment(ctx context.Context, postId, commentId, userId uuid.UUID, vote storage.Vote) (*storage.Comment, error) {
+	var (
+		upd *storage.Comment
+	)
+
+	err := pg.withTx(ctx, func(tx *sql.Tx) error {
+		if _, err := getPost(ctx, tx, getPostQuery, postId); err != nil {
+			return err
+		}
+
+		comm, err := getComment(ctx, tx, getCommentForUpdateQuery, postId, commentId)
+		if err != nil {
+			return err
+		}
+
+		if comm.DeletedAt != nil {
+			return storage.ErrCommIsDeleted
+		}
+
+		prev, err := cast(ctx, tx, commentBallot, commentId, userId, vote)
+		if err != nil {
+			return err
+		}
+
+		up, down := storage.Tally(prev, vote)
+
+		upd, err = scanComment(tx.QueryRowContext(ctx, tallyCommentQuery, commentId, up, down))
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return pg.withReplies(ctx, upd)
+}
+
+func (pg *pgStorage) Search(ctx context.Context, q storage.SearchQuery) (*storage.Page[storage.SearchHit], error) {
+	clause, args := seekHits(q, []any{q.Query, q.Kind})
+
+	rows, err := pg.db.QueryContext(ctx, fmt.Sprintf(headlineQuery, searchQuery+clause), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var (
+		hits []storage.SearchHit
+	)
+
+	for rows.Next() {
+		var hit storage.SearchHit
+
+		err := rows.Scan(&hit.Kind, &hit.Id, &hit.PostId, &hit.Rank, &hit.Snippet)
+		if err != nil {
+			return nil, err
+		}
+
+		hits = append(hits, hit)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return toPage(hits, q.Limit), nil
+}
+
+// runs fn inside of a transaction, which is commited only if fn succeeds
+func (pg *pgStorage) withTx(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	tx, err := pg.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// fills in comment tree of a given post
+func (pg *pgStorage) withComments(ctx context.Context, post *storage.Post) (*storage.Post, error) {
+	rows, err := pg.db.QueryContext(ctx, getPostCommentsQuery, post.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	comms, err := scanComments(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	post.Comments = buildTree(groupByParent(comms), uuid.Nil)
+
+	return post, nil
+}
+
+// fills in reply tree of a given comment
+func (pg *pgStorage) withReplies(ctx context.Context, comm *storage.Comment) (*storage.Comment, error) {
+	rows, err := pg.db.QueryContext(ctx, getPostCommentsQuery, comm.PostId)
+	if err != nil {
+		return nil, err
+	}
+
+	comms, err := scanComments(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	comm.Replies = buildTree(groupByParent(comms), comm.Id)
+	comm.ReplyCount = len(comm.Replies)
+
+	return comm, nil
+}
+
+func getPost(ctx context.Context, q querier, query string, id uuid.UUID) (*storage.Post, error) {
+	post, err := scanPost(q.QueryRowContext(ctx, query, id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, storage.ErrPostNotFound
+		}
+		return nil, err
+	}
+
+	return post, nil
+}
+
+func getComment(ctx context.Context, q querier, query string, postId, commentId uuid.UUID) (*storage.Comment, error) {
+	comm, err := scanComment(q.QueryRowContext(ctx, query, commentId, postId))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, storage.ErrCommNotFound
+		}
+		return nil, err
+	}
+
+	return comm, nil
+}
+
+// set of queries for accessing ballots of a single kind of items
+type ballotQueries struct {
+	get, upsert, delete string
+}
+
+var (
+	postBallot    = ballotQueries{getPostVoteQuery, upsertPostVoteQuery, deletePostVoteQuery}
+	commentBallot = ballotQueries{getCommentVoteQuery, upsertCommentVoteQuery, deleteCommentVoteQuery}
+)
+
+// records user's ballot on a post or a comment, returning the previous one
+func cast(ctx context.Context, q querier, bq ballotQueries, itemId, userId uuid.UUID, vote storage.Vote) (storage.Vote, error) {
+	var (
+		prev storage.Vote
+	)
+
+	err := q.QueryRowContext(ctx, bq.get, itemId, userId).Scan(&prev)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return 0, err
+	}
+
+	if vote == storage.VoteNone {
+		_, err = q.ExecContext(ctx, bq.delete, itemId, userId)
+	} else {
+		_, err = q.ExecContext(ctx, bq.upsert, itemId, userId, vote)
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	return prev, nil
 }
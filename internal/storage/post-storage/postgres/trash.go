@@ -0,0 +1,129 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	storage "github.com/cutlery47/posts/internal/storage/post-storage"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+func (pg *pgStorage) RestorePost(ctx context.Context, id uuid.UUID) (*storage.Post, error) {
+	var (
+		upd *storage.Post
+	)
+
+	err := pg.withTx(ctx, func(tx *sql.Tx) error {
+		post, err := getPost(ctx, tx, getPostForUpdateQuery, id)
+		if err != nil {
+			return err
+		}
+
+		if err := post.Moderate(storage.ModRestore, time.Now()); err != nil {
+			return err
+		}
+
+		upd, err = scanPost(tx.QueryRowContext(ctx, restorePostQuery, id))
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return pg.withComments(ctx, upd)
+}
+
+func (pg *pgStorage) RestoreComment(ctx context.Context, postId, commentId uuid.UUID) (*storage.Comment, error) {
+	var (
+		upd *storage.Comment
+	)
+
+	err := pg.withTx(ctx, func(tx *sql.Tx) error {
+		if _, err := getPost(ctx, tx, getPostForShareQuery, postId); err != nil {
+			return err
+		}
+
+		comm, err := getComment(ctx, tx, getCommentForUpdateQuery, postId, commentId)
+		if err != nil {
+			return err
+		}
+
+		if err := comm.Moderate(storage.ModRestore, time.Now()); err != nil {
+			return err
+		}
+
+		upd, err = scanComment(tx.QueryRowContext(ctx, restoreCommentQuery, commentId))
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return pg.withReplies(ctx, upd)
+}
+
+func (pg *pgStorage) GetTrash(ctx context.Context, userId uuid.UUID) (*storage.Trash, error) {
+	rows, err := pg.db.QueryContext(ctx, getTrashPostsQuery, userId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var (
+		trash = &storage.Trash{
+			Posts:    []storage.Post{},
+			Comments: []storage.Comment{},
+		}
+		// posts, which trees have to be fetched
+		ids []uuid.UUID
+	)
+
+	for rows.Next() {
+		post, err := scanPost(rows)
+		if err != nil {
+			return nil, err
+		}
+		trash.Posts = append(trash.Posts, *post)
+		ids = append(ids, post.Id)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rows, err = pg.db.QueryContext(ctx, getTrashCommentsQuery, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	comms, err := scanComments(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, c := range comms {
+		ids = append(ids, c.PostId)
+	}
+
+	rows, err = pg.db.QueryContext(ctx, getCommentsOfPostsQuery, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+
+	all, err := scanComments(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	plantTrees(trash.Posts, all)
+
+	children := groupByParent(all)
+	for _, c := range comms {
+		c.Replies = buildTree(children, c.Id)
+		trash.Comments = append(trash.Comments, c)
+	}
+
+	return trash, nil
+}
@@ -0,0 +1,169 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+
+	storage "github.com/cutlery47/posts/internal/storage/post-storage"
+	"github.com/google/uuid"
+)
+
+// common interface for *sql.DB and *sql.Tx
+type querier interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// common interface for *sql.Row and *sql.Rows
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func scanPost(s scanner) (*storage.Post, error) {
+	var (
+		post = storage.Post{
+			Comments: make(map[uuid.UUID]storage.Comment),
+		}
+	)
+
+	err := s.Scan(
+		&post.Id,
+		&post.UserId,
+		&post.IsMute,
+		&post.IsLocked,
+		&post.IsPinned,
+		&post.Content,
+		&post.Upvotes,
+		&post.Downvotes,
+		&post.Ranking.Hot,
+		&post.Ranking.Controversy,
+		&post.Ranking.Confidence,
+		&post.Version,
+		&post.CreatedAt,
+		&post.UpdatedAt,
+		&post.DeletedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &post, nil
+}
+
+// extra destinations are scanned from the columns, following comment columns
+func scanComment(s scanner, extra ...any) (*storage.Comment, error) {
+	var (
+		comm = storage.Comment{
+			Replies: make(map[uuid.UUID]storage.Comment),
+		}
+	)
+
+	dest := []any{
+		&comm.Id,
+		&comm.PostId,
+		&comm.ParentId,
+		&comm.Depth,
+		&comm.UserId,
+		&comm.IsPinned,
+		&comm.Content,
+		&comm.Upvotes,
+		&comm.Downvotes,
+		&comm.Ranking.Hot,
+		&comm.Ranking.Controversy,
+		&comm.Ranking.Confidence,
+		&comm.Version,
+		&comm.CreatedAt,
+		&comm.UpdatedAt,
+		&comm.DeletedAt,
+	}
+
+	err := s.Scan(append(dest, extra...)...)
+	if err != nil {
+		return nil, err
+	}
+
+	return &comm, nil
+}
+
+func scanComments(rows *sql.Rows) ([]storage.Comment, error) {
+	defer rows.Close()
+
+	var (
+		comms []storage.Comment
+	)
+
+	for rows.Next() {
+		comm, err := scanComment(rows)
+		if err != nil {
+			return nil, err
+		}
+		comms = append(comms, *comm)
+	}
+
+	return comms, rows.Err()
+}
+
+// scans comments of a page, each followed by the number of its direct replies
+func scanCountedComments(rows *sql.Rows) ([]storage.Comment, error) {
+	defer rows.Close()
+
+	var (
+		comms []storage.Comment
+	)
+
+	for rows.Next() {
+		var count int
+
+		comm, err := scanComment(rows, &count)
+		if err != nil {
+			return nil, err
+		}
+		comm.ReplyCount = count
+
+		comms = append(comms, *comm)
+	}
+
+	return comms, rows.Err()
+}
+
+// groups comments by their parent id (uuid.Nil for top-level comments)
+func groupByParent(comms []storage.Comment) map[uuid.UUID][]storage.Comment {
+	children := make(map[uuid.UUID][]storage.Comment)
+
+	for _, c := range comms {
+		var parentId uuid.UUID
+		if c.ParentId != nil {
+			parentId = *c.ParentId
+		}
+		children[parentId] = append(children[parentId], c)
+	}
+
+	return children
+}
+
+// recursively assembles reply tree under given parent
+func buildTree(children map[uuid.UUID][]storage.Comment, parentId uuid.UUID) map[uuid.UUID]storage.Comment {
+	tree := make(map[uuid.UUID]storage.Comment, len(children[parentId]))
+
+	for _, c := range children[parentId] {
+		c.Replies = buildTree(children, c.Id)
+		c.ReplyCount = len(c.Replies)
+		tree[c.Id] = c
+	}
+
+	return tree
+}
+
+// distributes comments between given posts and assembles their trees
+func plantTrees(posts []storage.Post, comms []storage.Comment) {
+	// PostId -> Comments
+	byPost := make(map[uuid.UUID][]storage.Comment)
+	for _, c := range comms {
+		byPost[c.PostId] = append(byPost[c.PostId], c)
+	}
+
+	for i := range posts {
+		posts[i].Comments = buildTree(groupByParent(byPost[posts[i].Id]), uuid.Nil)
+	}
+}
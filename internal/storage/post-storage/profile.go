@@ -0,0 +1,22 @@
+package storage
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+)
+
+// per-user read paths, deleted content is left out of all of them
+type Profiler interface {
+	// retrieves a single page of posts of a user, sorted by provided key
+	GetPostsByAuthor(ctx context.Context, userId uuid.UUID, q PageQuery) (*Page[Post], error)
+	// retrieves a single page of comments and replies of a user across all posts, sorted by provided key
+	GetCommentsByAuthor(ctx context.Context, userId uuid.UUID, q PageQuery) (*Page[Comment], error)
+	// sums vote scores (upvotes - downvotes) of the user's posts and comments
+	GetKarma(ctx context.Context, userId uuid.UUID) (int64, error)
+}
+
+// vote score of a post or a comment
+func Score(upvotes, downvotes uint64) int64 {
+	return int64(upvotes) - int64(downvotes)
+}
@@ -0,0 +1,9 @@
+package storage
+
+// amount of content, which was permanently removed by a single purge
+type PurgeStats struct {
+	Posts    int `json:"posts"`
+	Comments int `json:"comments"`
+	// total size of removed content
+	Bytes int64 `json:"bytes"`
+}
@@ -0,0 +1,82 @@
+package storage
+
+import (
+	"math"
+	"time"
+)
+
+const (
+	// moment, which hot ranks are counted from (2024-01-01 00:00:00 UTC)
+	hotEpoch = 1704067200
+	// an item gains as much hot rank in 12.5 hours, as it gains by getting tenfold score
+	hotPeriod = 45000
+	// z-score of 80% confidence, which lower bound of the wilson score interval is taken at
+	wilsonZ = 1.281551565545
+)
+
+// ranks of an item, derived from its votes and age
+// none of them depend on the current time, so they only change along with votes
+type Ranking struct {
+	// order of magnitude of the score, shifted by the creation time
+	// newer items need fewer votes to outrank the older ones
+	Hot float64 `json:"hot"`
+	// grows with the number of votes, as long as they're split evenly
+	Controversy float64 `json:"controversy"`
+	// lower bound of the share of upvotes, so that items with few votes aren't overrated
+	Confidence float64 `json:"confidence"`
+}
+
+func Rank(upvotes, downvotes uint64, createdAt time.Time) Ranking {
+	return Ranking{
+		Hot:         Hot(upvotes, downvotes, createdAt),
+		Controversy: Controversy(upvotes, downvotes),
+		Confidence:  Confidence(upvotes, downvotes),
+	}
+}
+
+func Hot(upvotes, downvotes uint64, createdAt time.Time) float64 {
+	var (
+		// converted beforehand, so that the difference never overflows
+		score = float64(upvotes) - float64(downvotes)
+		order = math.Log10(max(math.Abs(score), 1))
+		sign  float64
+	)
+
+	switch {
+	case score > 0:
+		sign = 1
+	case score < 0:
+		sign = -1
+	}
+
+	age := float64(createdAt.UnixMicro())/1e6 - hotEpoch
+
+	return sign*order + age/hotPeriod
+}
+
+func Controversy(upvotes, downvotes uint64) float64 {
+	if upvotes == 0 || downvotes == 0 {
+		return 0
+	}
+
+	var (
+		magnitude = float64(upvotes) + float64(downvotes)
+		balance   = float64(min(upvotes, downvotes)) / float64(max(upvotes, downvotes))
+	)
+
+	return math.Pow(magnitude, balance)
+}
+
+func Confidence(upvotes, downvotes uint64) float64 {
+	if upvotes == 0 && downvotes == 0 {
+		return 0
+	}
+
+	var (
+		n    = float64(upvotes) + float64(downvotes)
+		phat = float64(upvotes) / n
+		z2   = wilsonZ * wilsonZ
+	)
+
+	return (phat + z2/(2*n) - wilsonZ*math.Sqrt((phat*(1-phat)+z2/(4*n))/n)) / (1 + z2/n)
+}
@@ -0,0 +1,64 @@
+package storage
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// edit history of posts and comments
+// every insert, update and revert of the content appends a revision, revisions themselves never change
+type Reviser interface {
+	// retrieves revisions of a post (or of its comment if comment id isn't nil), oldest first
+	GetRevisions(ctx context.Context, postId uuid.UUID, commentId *uuid.UUID) ([]Revision, error)
+	// restores content of a post from its revision, recording it as a new revision made by the editor
+	RevertPost(ctx context.Context, postId, revisionId, editorId uuid.UUID) (*Post, error)
+	// restores content of a comment from its revision, recording it as a new revision made by the editor
+	RevertComment(ctx context.Context, postId, commentId, revisionId, editorId uuid.UUID) (*Comment, error)
+}
+
+// content of a post or a comment at some point of time
+type Revision struct {
+	Id     uuid.UUID `json:"id"`
+	PostId uuid.UUID `json:"post_id"`
+	// nil if the revision is of the post itself
+	CommentId *uuid.UUID `json:"comment_id"`
+	Content   string     `json:"content"`
+	// user, who made the change
+	EditorId uuid.UUID `json:"editor_id"`
+
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// id of the post or the comment, the revision is of
+func (r Revision) ItemId() uuid.UUID {
+	if r.CommentId != nil {
+		return *r.CommentId
+	}
+
+	return r.PostId
+}
+
+// revision, which holds current content of the post
+func PostRevision(p Post, editorId uuid.UUID, ts time.Time) Revision {
+	return Revision{
+		Id:        uuid.New(),
+		PostId:    p.Id,
+		Content:   p.Content,
+		EditorId:  editorId,
+		CreatedAt: ts,
+	}
+}
+
+// revision, which holds current content of the comment
+func CommentRevision(c Comment, editorId uuid.UUID, ts time.Time) Revision {
+	return Revision{
+		Id:        uuid.New(),
+		PostId:    c.PostId,
+		CommentId: &c.Id,
+		Content:   c.Content,
+		EditorId:  editorId,
+		CreatedAt: ts,
+	}
+}
@@ -0,0 +1,84 @@
+package storage
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+
+	"github.com/google/uuid"
+)
+
+type Searcher interface {
+	// retrieves a single page of posts and comments matching the query, ordered by relevance
+	Search(ctx context.Context, q SearchQuery) (*Page[SearchHit], error)
+}
+
+// defines what kind of content is searched
+type SearchKind string
+
+const (
+	SearchAll      SearchKind = "all"
+	SearchPosts    SearchKind = "post"
+	SearchComments SearchKind = "comment"
+)
+
+func (k SearchKind) Valid() bool {
+	return k == SearchAll || k == SearchPosts || k == SearchComments
+}
+
+func (k SearchKind) Includes(other SearchKind) bool {
+	return k == SearchAll || k == other
+}
+
+// markers, which surround matched terms in snippets
+const (
+	HighlightStart = "<b>"
+	HighlightStop  = "</b>"
+)
+
+type SearchQuery struct {
+	// free-form text, all of its terms should be present in the content
+	Query string
+
+	Kind SearchKind
+	// max number of hits on a page
+	Limit int
+	// position of the last hit on the previous page (nil for the first page)
+	After *SearchCursor
+}
+
+// position of a hit in ranked results
+type SearchCursor struct {
+	Rank float64   `json:"rank"`
+	Id   uuid.UUID `json:"id"`
+}
+
+// reports whether a should be placed before (-1) or after (+1) b
+// hits are ordered by rank descending, id breaks ties
+func (a SearchCursor) Compare(b SearchCursor) int {
+	if res := cmp.Compare(b.Rank, a.Rank); res != 0 {
+		return res
+	}
+
+	return bytes.Compare(a.Id[:], b.Id[:])
+}
+
+type SearchHit struct {
+	// either post or comment
+	Kind SearchKind `json:"kind"`
+	// matched post or comment id
+	Id uuid.UUID `json:"id"`
+	// post, which the hit belongs to
+	PostId uuid.UUID `json:"post_id"`
+	// relevance of the hit
+	Rank float64 `json:"rank"`
+	// html-escaped fragment of matched content with highlighted terms
+	Snippet string `json:"snippet"`
+}
+
+func (h SearchHit) Cursor() SearchCursor {
+	return SearchCursor{
+		Rank: h.Rank,
+		Id:   h.Id,
+	}
+}
@@ -2,27 +2,58 @@ package storage
 
 import (
 	"context"
+	"time"
 
 	"github.com/google/uuid"
 )
 
+// methods, which accept expected version of an item, fail with ConflictError,
+// unless it's nil or matches the version of the item at the moment of the change
 type Storage interface {
 	// retrieves a single post by provided id
 	GetPost(ctx context.Context, id uuid.UUID) (*Post, error)
 	// retrieves all posts
 	GetPosts(ctx context.Context) ([]Post, error)
+	// retrieves a single page of posts, sorted by provided key
+	GetPostsPage(ctx context.Context, q PageQuery) (*Page[Post], error)
 	// inserts a single post
-	InsertPost(ctx context.Context, in InPost) (*Post, error)
+	InsertPost(ctx context.Context, userId uuid.UUID, in InPost) (*Post, error)
 	// deletes a single post by provided id
-	DeletePost(ctx context.Context, id uuid.UUID) (*uuid.UUID, error)
+	DeletePost(ctx context.Context, id uuid.UUID, version *uint64) (*uuid.UUID, error)
+	// restores a single deleted post by provided id
+	RestorePost(ctx context.Context, id uuid.UUID) (*Post, error)
 	// updates a single post by provided id
-	UpdatePost(ctx context.Context, id uuid.UUID, in InPost) (*Post, error)
+	UpdatePost(ctx context.Context, id uuid.UUID, in InPost, version *uint64) (*Post, error)
+	// sets user's vote on a single post by provided id (VoteNone retracts it)
+	VotePost(ctx context.Context, id, userId uuid.UUID, vote Vote) (*Post, error)
 
 	GetComment(ctx context.Context, postId, commentId uuid.UUID) (*Comment, error)
+	// retrieves a single page of replies to a comment by provided parent id
+	// (top-level comments of a post if parent id is nil), sorted by provided key
+	GetCommentsPage(ctx context.Context, postId uuid.UUID, parentId *uuid.UUID, q PageQuery) (*Page[Comment], error)
 	// inserts a single comment for a post by provided id
-	InsertComment(ctx context.Context, postId uuid.UUID, parentId *uuid.UUID, in InComment) (*Comment, error)
+	InsertComment(ctx context.Context, postId uuid.UUID, parentId *uuid.UUID, userId uuid.UUID, in InComment) (*Comment, error)
 	// deletes a single comment for a post by provided id
-	DeleteComment(ctx context.Context, postId, commentId uuid.UUID) (*uuid.UUID, error)
+	DeleteComment(ctx context.Context, postId, commentId uuid.UUID, version *uint64) (*uuid.UUID, error)
+	// restores a single deleted comment for a post by provided id
+	RestoreComment(ctx context.Context, postId, commentId uuid.UUID) (*Comment, error)
 	// updates a single comment for a post by provided id
-	UpdateComment(ctx context.Context, postId, commentId uuid.UUID, in InComment) (*Comment, error)
+	UpdateComment(ctx context.Context, postId, commentId uuid.UUID, in InComment, version *uint64) (*Comment, error)
+	// sets user's vote on a single comment for a post by provided id (VoteNone retracts it)
+	VoteComment(ctx context.Context, postId, commentId, userId uuid.UUID, vote Vote) (*Comment, error)
+
+	// retrieves deleted posts and comments of a user, most recently deleted first
+	GetTrash(ctx context.Context, userId uuid.UUID) (*Trash, error)
+	// permanently removes posts and comment subtrees, which were deleted before provided time
+	Purge(ctx context.Context, before time.Time) (*PurgeStats, error)
+
+	// reports an error if the storage is unable to serve requests
+	Ready(ctx context.Context) error
+	// flushes pending state and releases resources
+	Close() error
+
+	Searcher
+	Moderator
+	Profiler
+	Reviser
 }
@@ -0,0 +1,44 @@
+package storage
+
+import "github.com/google/uuid"
+
+// deleted posts and comments of a single user
+type Trash struct {
+	Posts    []Post    `json:"posts"`
+	Comments []Comment `json:"comments"`
+}
+
+// returns a copy of the post, which is safe to be shown publicly:
+// content of the post and its comments is redacted if they were deleted,
+// while the comment tree itself is kept intact, so that replies remain reachable
+func (p Post) Tombstone() Post {
+	if p.DeletedAt != nil {
+		p.Content = ""
+	}
+
+	p.Comments = tombstones(p.Comments)
+
+	return p
+}
+
+// returns a copy of the comment, which is safe to be shown publicly
+func (c Comment) Tombstone() Comment {
+	if c.DeletedAt != nil {
+		c.Content = ""
+	}
+
+	c.Replies = tombstones(c.Replies)
+
+	return c
+}
+
+// copies comment tree, redacting deleted comments along the way
+func tombstones(comms map[uuid.UUID]Comment) map[uuid.UUID]Comment {
+	tree := make(map[uuid.UUID]Comment, len(comms))
+
+	for id, c := range comms {
+		tree[id] = c.Tombstone()
+	}
+
+	return tree
+}
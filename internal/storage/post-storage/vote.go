@@ -0,0 +1,34 @@
+package storage
+
+// user's ballot on a post or a comment
+type Vote int8
+
+const (
+	VoteDown Vote = -1
+	VoteNone Vote = 0
+	VoteUp   Vote = 1
+)
+
+func (v Vote) Valid() bool {
+	return v == VoteDown || v == VoteNone || v == VoteUp
+}
+
+// calculates how upvote and downvote counters change
+// when user's ballot is switched from prev to next
+func Tally(prev, next Vote) (up, down int64) {
+	switch prev {
+	case VoteUp:
+		up--
+	case VoteDown:
+		down--
+	}
+
+	switch next {
+	case VoteUp:
+		up++
+	case VoteDown:
+		down++
+	}
+
+	return up, down
+}
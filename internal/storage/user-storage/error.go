@@ -6,6 +6,10 @@ var (
 	ErrUserAlreadyExists = errors.New("user already exists")
 	ErrUserNotFound      = errors.New("user not found")
 	ErrSessionNotFound   = errors.New("session not found")
+	ErrSessionExpired    = errors.New("session expired")
 	ErrNotImplemented    = errors.New("not implemented")
 	ErrRoleNotFound      = errors.New("role not found")
+	ErrBadPassword       = errors.New("password should be 8 to 72 bytes long")
+	// returned both for unknown users and wrong passwords
+	ErrBadCredentials = errors.New("wrong username or password")
 )
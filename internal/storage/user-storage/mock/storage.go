@@ -16,15 +16,39 @@ type mockStorage struct {
 
 	mu *sync.RWMutex
 
+	// closed once the storage is, so that the reaper stops
+	done      chan struct{}
+	closeOnce sync.Once
+	workers   sync.WaitGroup
+
 	conf config.UserStorage
 }
 
-func NewStorage() *mockStorage {
-	return &mockStorage{
+func NewStorage(conf config.UserStorage) *mockStorage {
+	ms := &mockStorage{
 		users:    make(map[uuid.UUID]storage.User),
 		sessions: make(map[uuid.UUID]storage.Session),
 		mu:       &sync.RWMutex{},
+		done:     make(chan struct{}),
+		conf:     conf,
+	}
+
+	if conf.ReapInterval > 0 {
+		ms.workers.Add(1)
+		go ms.reap()
 	}
+
+	return ms
+}
+
+func (ms *mockStorage) Close() error {
+	ms.closeOnce.Do(func() {
+		close(ms.done)
+	})
+
+	ms.workers.Wait()
+
+	return nil
 }
 
 func (ms *mockStorage) Register(ctx context.Context, in storage.InUser) (*storage.User, error) {
@@ -32,10 +56,16 @@ func (ms *mockStorage) Register(ctx context.Context, in storage.InUser) (*storag
 		return nil, err
 	}
 
-	if in.Role != storage.AdminRole && in.Role != storage.UserRole {
+	if !storage.ValidRole(in.Role) {
 		return nil, storage.ErrRoleNotFound
 	}
 
+	// hashing is slow, so it's done before taking the lock
+	hash, err := storage.HashPassword(in.Password)
+	if err != nil {
+		return nil, err
+	}
+
 	ms.mu.Lock()
 	defer ms.mu.Unlock()
 
@@ -45,9 +75,9 @@ func (ms *mockStorage) Register(ctx context.Context, in storage.InUser) (*storag
 		}
 	}
 
-	user := toUser(in)
+	user := toUser(in, hash)
 	for _, ok := ms.users[user.Id]; ok; _, ok = ms.users[user.Id] {
-		user = toUser(in)
+		user = toUser(in, hash)
 	}
 
 	ms.users[user.Id] = user
@@ -60,26 +90,31 @@ func (ms *mockStorage) Login(ctx context.Context, in storage.InUser) (*storage.S
 		return nil, err
 	}
 
-	ms.mu.Lock()
-	defer ms.mu.Unlock()
-
 	var (
 		user *storage.User
+		hash []byte
 	)
 
+	ms.mu.RLock()
+
 	// searching if user with provided name exists
 	for _, v := range ms.users {
 		if v.Name == in.Name {
 			user = &v
+			hash = v.PasswordHash
 			break
 		}
 	}
 
-	if user == nil {
-		return nil, storage.ErrUserNotFound
+	ms.mu.RUnlock()
+
+	// unknown users are checked as well, so that they can't be told apart by timing
+	if err := storage.CheckPassword(hash, in.Password); err != nil {
+		return nil, err
 	}
 
-	// imagine some password validation here, etc...
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
 
 	sesh := newSession(user.Id, time.Now().Add(ms.conf.SessionDuration))
 	ms.sessions[sesh.Id] = *sesh
@@ -119,3 +154,141 @@ func (ms *mockStorage) GetSession(ctx context.Context, id uuid.UUID) (*storage.S
 
 	return &s, nil
 }
+
+func (ms *mockStorage) GetUser(ctx context.Context, id uuid.UUID) (*storage.User, error) {
+	if err := ctxDone(ctx); err != nil {
+		return nil, err
+	}
+
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+
+	u, ok := ms.users[id]
+	if !ok {
+		return nil, storage.ErrUserNotFound
+	}
+
+	return &u, nil
+}
+
+func (ms *mockStorage) SetRole(ctx context.Context, userId uuid.UUID, role string) (*storage.User, error) {
+	if err := ctxDone(ctx); err != nil {
+		return nil, err
+	}
+
+	if !storage.ValidRole(role) {
+		return nil, storage.ErrRoleNotFound
+	}
+
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	u, ok := ms.users[userId]
+	if !ok {
+		return nil, storage.ErrUserNotFound
+	}
+
+	u.Role = role
+	ms.users[userId] = u
+
+	return &u, nil
+}
+
+func (ms *mockStorage) GetUserByName(ctx context.Context, name string) (*storage.User, error) {
+	if err := ctxDone(ctx); err != nil {
+		return nil, err
+	}
+
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+
+	for _, u := range ms.users {
+		if u.Name == name {
+			return &u, nil
+		}
+	}
+
+	return nil, storage.ErrUserNotFound
+}
+
+func (ms *mockStorage) GetUsers(ctx context.Context, ids []uuid.UUID) ([]storage.User, error) {
+	if err := ctxDone(ctx); err != nil {
+		return nil, err
+	}
+
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+
+	users := make([]storage.User, 0, len(ids))
+
+	for _, id := range ids {
+		if u, ok := ms.users[id]; ok {
+			users = append(users, u)
+		}
+	}
+
+	return users, nil
+}
+
+func (ms *mockStorage) RefreshSession(ctx context.Context, id uuid.UUID) (*storage.Session, error) {
+	if err := ctxDone(ctx); err != nil {
+		return nil, err
+	}
+
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	old, ok := ms.sessions[id]
+	if !ok || old.Expired(time.Now()) {
+		return nil, storage.ErrSessionNotFound
+	}
+
+	delete(ms.sessions, id)
+
+	sesh := newSession(old.UserId, time.Now().Add(ms.conf.SessionDuration))
+	ms.sessions[sesh.Id] = *sesh
+
+	return sesh, nil
+}
+
+func (ms *mockStorage) RevokeSessions(ctx context.Context, userId uuid.UUID) error {
+	if err := ctxDone(ctx); err != nil {
+		return err
+	}
+
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	for id, s := range ms.sessions {
+		if s.UserId == userId {
+			delete(ms.sessions, id)
+		}
+	}
+
+	return nil
+}
+
+// periodically deletes expired sessions
+// stops once the storage is closed
+func (ms *mockStorage) reap() {
+	defer ms.workers.Done()
+
+	for {
+		select {
+		case <-time.After(ms.conf.ReapInterval):
+		case <-ms.done:
+			return
+		}
+
+		ms.mu.Lock()
+
+		now := time.Now()
+		for id, s := range ms.sessions {
+			if s.Expired(now) {
+				delete(ms.sessions, id)
+			}
+		}
+
+		ms.mu.Unlock()
+	}
+}
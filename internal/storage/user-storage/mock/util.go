@@ -18,11 +18,14 @@ func ctxDone(ctx context.Context) error {
 	return nil
 }
 
-func toUser(in storage.InUser) storage.User {
+func toUser(in storage.InUser, hash []byte) storage.User {
+	in.Password = ""
+
 	return storage.User{
-		InUser:    in,
-		Id:        uuid.New(),
-		CreatedAt: time.Now(),
+		InUser:       in,
+		Id:           uuid.New(),
+		CreatedAt:    time.Now(),
+		PasswordHash: hash,
 	}
 }
 
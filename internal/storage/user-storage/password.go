@@ -0,0 +1,52 @@
+package storage
+
+import (
+	"errors"
+	"sync"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	// bcrypt work factor
+	passwordCost = bcrypt.DefaultCost
+
+	minPasswordLength = 8
+	// bcrypt ignores everything past 72 bytes
+	maxPasswordLength = 72
+)
+
+// hash, which is compared against when user doesn't exist,
+// so that unknown users take as long to check as the existing ones
+var dummyHash = sync.OnceValue(func() []byte {
+	hash, _ := bcrypt.GenerateFromPassword([]byte("dummy password"), passwordCost)
+	return hash
+})
+
+// validates password and derives its hash
+func HashPassword(password string) ([]byte, error) {
+	if len(password) < minPasswordLength || len(password) > maxPasswordLength {
+		return nil, ErrBadPassword
+	}
+
+	return bcrypt.GenerateFromPassword([]byte(password), passwordCost)
+}
+
+// checks password against the hash in constant time
+// nil hash stands for unknown user (or user without a password)
+func CheckPassword(hash []byte, password string) error {
+	if hash == nil {
+		bcrypt.CompareHashAndPassword(dummyHash(), []byte(password))
+		return ErrBadCredentials
+	}
+
+	err := bcrypt.CompareHashAndPassword(hash, []byte(password))
+	if err != nil {
+		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+			return ErrBadCredentials
+		}
+		return err
+	}
+
+	return nil
+}
@@ -4,8 +4,9 @@ const insertUserQuery = `
 	INSERT INTO posts.user (
 		name
 		, role
+		, password_hash
 	) VALUES (
-		$1, $2
+		$1, $2, $3
 	) RETURNING 
 		id
 		, name
@@ -35,9 +36,10 @@ const getSessionById = `
 		id=$1
 `
 
-const getUserIdQuery = `
+const getCredentialsQuery = `
 	SELECT 
 		id
+		, password_hash
 	FROM
 		posts.user
 	WHERE
@@ -50,3 +52,77 @@ const deleteSessionById = `
 	WHERE
 		id=$1
 `
+
+const deleteLiveSessionById = `
+	DELETE FROM
+		posts.session
+	WHERE
+		id=$1
+		AND expires_at > $2
+	RETURNING
+		user_id
+`
+
+const deleteSessionsByUser = `
+	DELETE FROM
+		posts.session
+	WHERE
+		user_id=$1
+`
+
+const deleteExpiredSessions = `
+	DELETE FROM
+		posts.session
+	WHERE
+		expires_at <= $1
+`
+
+const getUserQuery = `
+	SELECT
+		id
+		, name
+		, role
+		, created_at
+	FROM
+		posts.user
+	WHERE
+		id=$1
+`
+
+const getUserByNameQuery = `
+	SELECT
+		id
+		, name
+		, role
+		, created_at
+	FROM
+		posts.user
+	WHERE
+		name=$1
+`
+
+const getUsersQuery = `
+	SELECT
+		id
+		, name
+		, role
+		, created_at
+	FROM
+		posts.user
+	WHERE
+		id=ANY($1)
+`
+
+const setRoleQuery = `
+	UPDATE
+		posts.user
+	SET
+		role=$2
+	WHERE
+		id=$1
+	RETURNING
+		id
+		, name
+		, role
+		, created_at
+`
@@ -4,78 +4,57 @@ import (
 	"context"
 	"database/sql"
 	"errors"
-	"fmt"
 	"log"
+	"sync"
 	"time"
 
 	"github.com/cutlery47/posts/config"
 	storage "github.com/cutlery47/posts/internal/storage/user-storage"
+	"github.com/cutlery47/posts/pkg/pgconn"
 	"github.com/google/uuid"
 
-	"github.com/golang-migrate/migrate"
-	"github.com/golang-migrate/migrate/database/postgres"
-	_ "github.com/golang-migrate/migrate/database/postgres"
-	_ "github.com/golang-migrate/migrate/source/file"
-
 	"github.com/lib/pq"
-	_ "github.com/lib/pq"
 )
 
 type pgStorage struct {
 	db *sql.DB
 
+	// closed once the storage is, so that the reaper stops
+	done      chan struct{}
+	closeOnce sync.Once
+	workers   sync.WaitGroup
+
 	conf config.UserStorage
 }
 
 func NewStorage(conf config.UserStorage) (*pgStorage, error) {
-	dsn := fmt.Sprintf(
-		"postgresql://%v:%v@%v:%v/%v?sslmode=disable",
-		conf.User,
-		conf.Pass,
-		conf.Host,
-		conf.Port,
-		conf.DB,
-	)
-
-	db, err := sql.Open("postgres", dsn)
+	db, err := pgconn.Connect(conf.Postgres)
 	if err != nil {
 		return nil, err
 	}
 
-	timeoutCtx, cancel := context.WithTimeout(context.Background(), conf.Timeout)
-	defer cancel()
-
-	err = db.PingContext(timeoutCtx)
-	if err != nil {
-		return nil, fmt.Errorf("couldn't establish connection with postgres: %v", err)
+	pg := &pgStorage{
+		db:   db,
+		done: make(chan struct{}),
+		conf: conf,
 	}
-	log.Println("[SETUP] successfully established postgres connection!")
 
-	driver, err := postgres.WithInstance(db, &postgres.Config{})
-	if err != nil {
-		return nil, fmt.Errorf("postgres.WithInstance: %v", err)
+	if conf.ReapInterval > 0 {
+		pg.workers.Add(1)
+		go pg.reap()
 	}
 
-	migrations := fmt.Sprintf("file://%v", conf.Migrations)
-	m, err := migrate.NewWithDatabaseInstance(migrations, conf.DB, driver)
-	if err != nil {
-		return nil, fmt.Errorf("migrate.NewWithDatabaseInstance: %v", err)
-	}
+	return pg, nil
+}
 
-	if err := m.Up(); err != nil {
-		if errors.Is(err, migrate.ErrNoChange) {
-			log.Println("[SETUP] nothing to migrate")
-		} else {
-			return nil, fmt.Errorf("error when migrating: %v", err)
-		}
-	} else {
-		log.Println("[SETUP] migrated successfully!")
-	}
+func (pg *pgStorage) Close() error {
+	pg.closeOnce.Do(func() {
+		close(pg.done)
+	})
 
-	return &pgStorage{
-		db:   db,
-		conf: conf,
-	}, nil
+	pg.workers.Wait()
+
+	return pg.db.Close()
 }
 
 func (pg *pgStorage) Register(ctx context.Context, in storage.InUser) (*storage.User, error) {
@@ -83,8 +62,13 @@ func (pg *pgStorage) Register(ctx context.Context, in storage.InUser) (*storage.
 		user storage.User
 	)
 
-	row := pg.db.QueryRowContext(ctx, insertUserQuery, in.Name, in.Role)
-	err := row.Scan(&user.Id, &user.Name, &user.Role, &user.CreatedAt)
+	hash, err := storage.HashPassword(in.Password)
+	if err != nil {
+		return nil, err
+	}
+
+	row := pg.db.QueryRowContext(ctx, insertUserQuery, in.Name, in.Role, hash)
+	err = row.Scan(&user.Id, &user.Name, &user.Role, &user.CreatedAt)
 	if err != nil {
 		if err.(*pq.Error).Code == "23505" {
 			return nil, storage.ErrUserAlreadyExists
@@ -92,19 +76,24 @@ func (pg *pgStorage) Register(ctx context.Context, in storage.InUser) (*storage.
 		return nil, err
 	}
 
+	user.PasswordHash = hash
+
 	return &user, nil
 }
 
 func (pg *pgStorage) Login(ctx context.Context, in storage.InUser) (*storage.Session, error) {
 	var (
-		id uuid.UUID
+		id   uuid.UUID
+		hash []byte
 	)
 
-	err := pg.db.QueryRowContext(ctx, getUserIdQuery, in.Name).Scan(&id)
-	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			return nil, storage.ErrUserNotFound
-		}
+	err := pg.db.QueryRowContext(ctx, getCredentialsQuery, in.Name).Scan(&id, &hash)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+
+	// unknown users are checked as well, so that they can't be told apart by timing
+	if err := storage.CheckPassword(hash, in.Password); err != nil {
 		return nil, err
 	}
 
@@ -151,3 +140,127 @@ func (pg *pgStorage) GetSession(ctx context.Context, id uuid.UUID) (*storage.Ses
 
 	return &sesh, nil
 }
+
+func (pg *pgStorage) GetUser(ctx context.Context, id uuid.UUID) (*storage.User, error) {
+	return pg.getUser(ctx, getUserQuery, id)
+}
+
+func (pg *pgStorage) GetUserByName(ctx context.Context, name string) (*storage.User, error) {
+	return pg.getUser(ctx, getUserByNameQuery, name)
+}
+
+func (pg *pgStorage) SetRole(ctx context.Context, userId uuid.UUID, role string) (*storage.User, error) {
+	if !storage.ValidRole(role) {
+		return nil, storage.ErrRoleNotFound
+	}
+
+	return pg.getUser(ctx, setRoleQuery, userId, role)
+}
+
+// retrieves a single user, matching the query
+func (pg *pgStorage) getUser(ctx context.Context, query string, args ...any) (*storage.User, error) {
+	var (
+		user storage.User
+	)
+
+	row := pg.db.QueryRowContext(ctx, query, args...)
+	err := row.Scan(&user.Id, &user.Name, &user.Role, &user.CreatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, storage.ErrUserNotFound
+		}
+		return nil, err
+	}
+
+	return &user, nil
+}
+
+func (pg *pgStorage) GetUsers(ctx context.Context, ids []uuid.UUID) ([]storage.User, error) {
+	rows, err := pg.db.QueryContext(ctx, getUsersQuery, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := make([]storage.User, 0, len(ids))
+
+	for rows.Next() {
+		var (
+			user storage.User
+		)
+
+		if err := rows.Scan(&user.Id, &user.Name, &user.Role, &user.CreatedAt); err != nil {
+			return nil, err
+		}
+
+		users = append(users, user)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return users, nil
+}
+
+func (pg *pgStorage) RefreshSession(ctx context.Context, id uuid.UUID) (*storage.Session, error) {
+	tx, err := pg.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var (
+		userId uuid.UUID
+		sesh   storage.Session
+		now    = time.Now()
+	)
+
+	err = tx.QueryRowContext(ctx, deleteLiveSessionById, id, now).Scan(&userId)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, storage.ErrSessionNotFound
+		}
+		return nil, err
+	}
+
+	row := tx.QueryRowContext(ctx, insertSessionQuery, userId, now.Add(pg.conf.SessionDuration))
+	err = row.Scan(&sesh.Id, &sesh.UserId, &sesh.CreatedAt, &sesh.ExpiresAt)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return &sesh, nil
+}
+
+func (pg *pgStorage) RevokeSessions(ctx context.Context, userId uuid.UUID) error {
+	_, err := pg.db.ExecContext(ctx, deleteSessionsByUser, userId)
+	return err
+}
+
+// periodically deletes expired sessions
+// stops once the storage is closed
+func (pg *pgStorage) reap() {
+	defer pg.workers.Done()
+
+	for {
+		select {
+		case <-time.After(pg.conf.ReapInterval):
+		case <-pg.done:
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), pg.conf.Timeout)
+
+		_, err := pg.db.ExecContext(ctx, deleteExpiredSessions, time.Now())
+		if err != nil {
+			log.Println("[REAPER] couldn't delete expired sessions:", err)
+		}
+
+		cancel()
+	}
+}
@@ -13,3 +13,7 @@ type Session struct {
 	CreatedAt time.Time `json:"created_at"`
 	ExpiresAt time.Time `json:"expires_at"`
 }
+
+func (s Session) Expired(now time.Time) bool {
+	return !now.Before(s.ExpiresAt)
+}
@@ -15,4 +15,19 @@ type Storage interface {
 	Logout(ctx context.Context, sesh Session) error
 
 	GetSession(ctx context.Context, id uuid.UUID) (*Session, error)
+	// retrieves a single user by provided id
+	GetUser(ctx context.Context, id uuid.UUID) (*User, error)
+	// retrieves a single user by provided name
+	GetUserByName(ctx context.Context, name string) (*User, error)
+	// retrieves users by provided ids in a single lookup, unknown ids are skipped
+	GetUsers(ctx context.Context, ids []uuid.UUID) ([]User, error)
+	// replaces given session with a new one, which expires later
+	RefreshSession(ctx context.Context, id uuid.UUID) (*Session, error)
+	// logs given user out of every session
+	RevokeSessions(ctx context.Context, userId uuid.UUID) error
+	// grants given role to the user
+	SetRole(ctx context.Context, userId uuid.UUID, role string) (*User, error)
+
+	// stops background work and releases resources
+	Close() error
 }
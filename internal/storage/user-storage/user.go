@@ -9,6 +9,8 @@ import (
 type InUser struct {
 	Name string `json:"name"` // unique
 	Role string `json:"role"`
+	// plain password, never stored or returned
+	Password string `json:"password,omitempty"`
 }
 
 type User struct {
@@ -16,9 +18,16 @@ type User struct {
 
 	Id        uuid.UUID `json:"id"`
 	CreatedAt time.Time `json:"created_at"`
+
+	PasswordHash []byte `json:"-"`
 }
 
 var (
-	AdminRole = "admin"
-	UserRole  = "user"
+	AdminRole     = "admin"
+	ModeratorRole = "moderator"
+	UserRole      = "user"
 )
+
+func ValidRole(role string) bool {
+	return role == AdminRole || role == ModeratorRole || role == UserRole
+}
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS posts.comment;
+DROP TABLE IF EXISTS posts.post;
\ No newline at end of file
@@ -0,0 +1,27 @@
+CREATE TABLE IF NOT EXISTS posts.post (
+    id              UUID            NOT NULL        DEFAULT uuid_generate_v4() PRIMARY KEY,
+    user_id         UUID            NOT NULL        REFERENCES posts.user(id),
+    is_mute         BOOLEAN         NOT NULL        DEFAULT FALSE,
+    content         TEXT            NOT NULL,
+    upvotes         BIGINT          NOT NULL        DEFAULT 0,
+    downvotes       BIGINT          NOT NULL        DEFAULT 0,
+    created_at      TIMESTAMP       NOT NULL        DEFAULT CURRENT_TIMESTAMP,
+    updated_at      TIMESTAMP       NOT NULL        DEFAULT CURRENT_TIMESTAMP,
+    deleted_at      TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS posts.comment (
+    id              UUID            NOT NULL        DEFAULT uuid_generate_v4() PRIMARY KEY,
+    post_id         UUID            NOT NULL        REFERENCES posts.post(id) ON DELETE CASCADE,
+    parent_id       UUID                            REFERENCES posts.comment(id) ON DELETE CASCADE,
+    user_id         UUID            NOT NULL        REFERENCES posts.user(id),
+    content         TEXT            NOT NULL,
+    upvotes         BIGINT          NOT NULL        DEFAULT 0,
+    downvotes       BIGINT          NOT NULL        DEFAULT 0,
+    created_at      TIMESTAMP       NOT NULL        DEFAULT CURRENT_TIMESTAMP,
+    updated_at      TIMESTAMP       NOT NULL        DEFAULT CURRENT_TIMESTAMP,
+    deleted_at      TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS comment_post_id_idx ON posts.comment(post_id);
+CREATE INDEX IF NOT EXISTS comment_parent_id_idx ON posts.comment(parent_id);
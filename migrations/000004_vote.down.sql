@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS posts.comment_vote;
+DROP TABLE IF EXISTS posts.post_vote;
\ No newline at end of file
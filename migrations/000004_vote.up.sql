@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS posts.post_vote (
+    post_id         UUID            NOT NULL        REFERENCES posts.post(id) ON DELETE CASCADE,
+    user_id         UUID            NOT NULL        REFERENCES posts.user(id),
+    vote            SMALLINT        NOT NULL        CHECK (vote IN (-1, 1)),
+    PRIMARY KEY (post_id, user_id)
+);
+
+CREATE TABLE IF NOT EXISTS posts.comment_vote (
+    comment_id      UUID            NOT NULL        REFERENCES posts.comment(id) ON DELETE CASCADE,
+    user_id         UUID            NOT NULL        REFERENCES posts.user(id),
+    vote            SMALLINT        NOT NULL        CHECK (vote IN (-1, 1)),
+    PRIMARY KEY (comment_id, user_id)
+);
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS posts.comment_tsv_idx;
+DROP INDEX IF EXISTS posts.post_tsv_idx;
+
+ALTER TABLE posts.comment DROP COLUMN IF EXISTS tsv;
+ALTER TABLE posts.post DROP COLUMN IF EXISTS tsv;
\ No newline at end of file
@@ -0,0 +1,8 @@
+ALTER TABLE posts.post 
+    ADD COLUMN IF NOT EXISTS tsv TSVECTOR GENERATED ALWAYS AS (to_tsvector('simple', content)) STORED;
+
+ALTER TABLE posts.comment 
+    ADD COLUMN IF NOT EXISTS tsv TSVECTOR GENERATED ALWAYS AS (to_tsvector('simple', content)) STORED;
+
+CREATE INDEX IF NOT EXISTS post_tsv_idx ON posts.post USING GIN (tsv);
+CREATE INDEX IF NOT EXISTS comment_tsv_idx ON posts.comment USING GIN (tsv);
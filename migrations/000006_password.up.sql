@@ -0,0 +1,2 @@
+-- users, registered before passwords were introduced, are left without one and can't log in
+ALTER TABLE posts.user ADD COLUMN IF NOT EXISTS password_hash BYTEA;
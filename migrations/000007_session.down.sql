@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS posts.session_expires_at_idx;
+DROP INDEX IF EXISTS posts.session_user_id_idx;
\ No newline at end of file
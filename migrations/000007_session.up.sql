@@ -0,0 +1,2 @@
+CREATE INDEX IF NOT EXISTS session_user_id_idx ON posts.session (user_id);
+CREATE INDEX IF NOT EXISTS session_expires_at_idx ON posts.session (expires_at);
@@ -0,0 +1,7 @@
+DROP TABLE IF EXISTS posts.moderation;
+
+ALTER TABLE posts.comment DROP COLUMN IF EXISTS is_pinned;
+ALTER TABLE posts.post DROP COLUMN IF EXISTS is_pinned;
+ALTER TABLE posts.post DROP COLUMN IF EXISTS is_locked;
+
+-- enum values can't be dropped, so 'moderator' role is left in place
@@ -0,0 +1,17 @@
+ALTER TYPE posts.role ADD VALUE IF NOT EXISTS 'moderator';
+
+ALTER TABLE posts.post ADD COLUMN IF NOT EXISTS is_locked BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE posts.post ADD COLUMN IF NOT EXISTS is_pinned BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE posts.comment ADD COLUMN IF NOT EXISTS is_pinned BOOLEAN NOT NULL DEFAULT FALSE;
+
+CREATE TABLE IF NOT EXISTS posts.moderation (
+    id              UUID            NOT NULL        DEFAULT uuid_generate_v4() PRIMARY KEY,
+    post_id         UUID            NOT NULL        REFERENCES posts.post(id) ON DELETE CASCADE,
+    comment_id      UUID                            REFERENCES posts.comment(id) ON DELETE CASCADE,
+    actor_id        UUID            NOT NULL        REFERENCES posts.user(id),
+    action          VARCHAR(16)     NOT NULL,
+    reason          TEXT            NOT NULL,
+    created_at      TIMESTAMP       NOT NULL        DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS moderation_post_id_idx ON posts.moderation (post_id, created_at);
@@ -0,0 +1 @@
+ALTER TABLE posts.comment DROP COLUMN IF EXISTS depth;
@@ -0,0 +1,9 @@
+ALTER TABLE posts.comment ADD COLUMN IF NOT EXISTS depth INTEGER NOT NULL DEFAULT 1;
+
+-- existing replies get their depth from the chain of their parents
+WITH RECURSIVE tree AS (
+    SELECT id, 1 AS depth FROM posts.comment WHERE parent_id IS NULL
+    UNION ALL
+    SELECT c.id, t.depth + 1 FROM posts.comment c JOIN tree t ON c.parent_id = t.id
+)
+UPDATE posts.comment c SET depth = tree.depth FROM tree WHERE c.id = tree.id AND c.depth <> tree.depth;
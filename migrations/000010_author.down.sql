@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS posts.comment_user_id_idx;
+DROP INDEX IF EXISTS posts.post_user_id_idx;
\ No newline at end of file
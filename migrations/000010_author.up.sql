@@ -0,0 +1,2 @@
+CREATE INDEX IF NOT EXISTS post_user_id_idx ON posts.post (user_id, created_at);
+CREATE INDEX IF NOT EXISTS comment_user_id_idx ON posts.comment (user_id, created_at);
\ No newline at end of file
@@ -0,0 +1 @@
+DROP TABLE IF EXISTS posts.revision;
\ No newline at end of file
@@ -0,0 +1,17 @@
+CREATE TABLE IF NOT EXISTS posts.revision (
+    id              UUID            NOT NULL        DEFAULT uuid_generate_v4() PRIMARY KEY,
+    post_id         UUID            NOT NULL        REFERENCES posts.post(id) ON DELETE CASCADE,
+    comment_id      UUID                            REFERENCES posts.comment(id) ON DELETE CASCADE,
+    editor_id       UUID            NOT NULL        REFERENCES posts.user(id),
+    content         TEXT            NOT NULL,
+    created_at      TIMESTAMP       NOT NULL        DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS revision_post_id_idx ON posts.revision (post_id, comment_id, created_at);
+
+-- current content of the existing posts and comments becomes their first revision
+INSERT INTO posts.revision (post_id, editor_id, content, created_at)
+SELECT id, user_id, content, updated_at FROM posts.post;
+
+INSERT INTO posts.revision (post_id, comment_id, editor_id, content, created_at)
+SELECT post_id, id, user_id, content, updated_at FROM posts.comment;
@@ -0,0 +1,2 @@
+ALTER TABLE posts.comment DROP COLUMN IF EXISTS version;
+ALTER TABLE posts.post DROP COLUMN IF EXISTS version;
\ No newline at end of file
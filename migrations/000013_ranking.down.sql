@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS posts.post_created_at_idx;
+DROP INDEX IF EXISTS posts.post_confidence_idx;
+DROP INDEX IF EXISTS posts.post_controversy_idx;
+DROP INDEX IF EXISTS posts.post_hot_idx;
+
+ALTER TABLE posts.comment DROP COLUMN IF EXISTS hot, DROP COLUMN IF EXISTS controversy, DROP COLUMN IF EXISTS confidence;
+ALTER TABLE posts.post DROP COLUMN IF EXISTS hot, DROP COLUMN IF EXISTS controversy, DROP COLUMN IF EXISTS confidence;
\ No newline at end of file
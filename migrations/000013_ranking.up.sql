@@ -0,0 +1,29 @@
+-- ranks mirror storage.Rank (hot rank is counted from 2024-01-01 and grows by 1 every 12.5 hours)
+-- created_at is stored without time zone and is read as UTC here, as it is by the app, which is why every session
+-- of the app runs in UTC (see pgconn.Connect): CURRENT_TIMESTAMP and LOCALTIMESTAMP are UTC then as well
+ALTER TABLE posts.post
+    ADD COLUMN IF NOT EXISTS hot FLOAT8 GENERATED ALWAYS AS (
+        SIGN((upvotes - downvotes)::FLOAT8) * LOG(GREATEST(ABS(upvotes - downvotes), 1)::FLOAT8) + (EXTRACT(EPOCH FROM created_at)::FLOAT8 - 1704067200) / 45000
+    ) STORED,
+    ADD COLUMN IF NOT EXISTS controversy FLOAT8 GENERATED ALWAYS AS (
+        CASE WHEN upvotes = 0 OR downvotes = 0 THEN 0 ELSE POWER((upvotes + downvotes)::FLOAT8, LEAST(upvotes, downvotes)::FLOAT8 / GREATEST(upvotes, downvotes)) END
+    ) STORED,
+    ADD COLUMN IF NOT EXISTS confidence FLOAT8 GENERATED ALWAYS AS (
+        CASE WHEN upvotes + downvotes = 0 THEN 0 ELSE ((upvotes / (upvotes + downvotes)::FLOAT8) + 1.281551565545 ^ 2 / (2 * (upvotes + downvotes)::FLOAT8) - 1.281551565545 * SQRT(((upvotes / (upvotes + downvotes)::FLOAT8) * (1 - (upvotes / (upvotes + downvotes)::FLOAT8)) + 1.281551565545 ^ 2 / (4 * (upvotes + downvotes)::FLOAT8)) / (upvotes + downvotes)::FLOAT8)) / (1 + 1.281551565545 ^ 2 / (upvotes + downvotes)::FLOAT8) END
+    ) STORED;
+
+ALTER TABLE posts.comment
+    ADD COLUMN IF NOT EXISTS hot FLOAT8 GENERATED ALWAYS AS (
+        SIGN((upvotes - downvotes)::FLOAT8) * LOG(GREATEST(ABS(upvotes - downvotes), 1)::FLOAT8) + (EXTRACT(EPOCH FROM created_at)::FLOAT8 - 1704067200) / 45000
+    ) STORED,
+    ADD COLUMN IF NOT EXISTS controversy FLOAT8 GENERATED ALWAYS AS (
+        CASE WHEN upvotes = 0 OR downvotes = 0 THEN 0 ELSE POWER((upvotes + downvotes)::FLOAT8, LEAST(upvotes, downvotes)::FLOAT8 / GREATEST(upvotes, downvotes)) END
+    ) STORED,
+    ADD COLUMN IF NOT EXISTS confidence FLOAT8 GENERATED ALWAYS AS (
+        CASE WHEN upvotes + downvotes = 0 THEN 0 ELSE ((upvotes / (upvotes + downvotes)::FLOAT8) + 1.281551565545 ^ 2 / (2 * (upvotes + downvotes)::FLOAT8) - 1.281551565545 * SQRT(((upvotes / (upvotes + downvotes)::FLOAT8) * (1 - (upvotes / (upvotes + downvotes)::FLOAT8)) + 1.281551565545 ^ 2 / (4 * (upvotes + downvotes)::FLOAT8)) / (upvotes + downvotes)::FLOAT8)) / (1 + 1.281551565545 ^ 2 / (upvotes + downvotes)::FLOAT8) END
+    ) STORED;
+
+CREATE INDEX IF NOT EXISTS post_hot_idx ON posts.post (hot, id);
+CREATE INDEX IF NOT EXISTS post_controversy_idx ON posts.post (controversy, id);
+CREATE INDEX IF NOT EXISTS post_confidence_idx ON posts.post (confidence, id);
+CREATE INDEX IF NOT EXISTS post_created_at_idx ON posts.post (created_at);
\ No newline at end of file
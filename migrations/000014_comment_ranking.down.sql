@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS posts.comment_confidence_idx;
+DROP INDEX IF EXISTS posts.comment_controversy_idx;
+DROP INDEX IF EXISTS posts.comment_hot_idx;
+DROP INDEX IF EXISTS posts.comment_created_at_idx;
\ No newline at end of file
@@ -0,0 +1,5 @@
+-- comment pages are filtered by post and parent before they're sorted, so each of the sort columns is indexed after them
+CREATE INDEX IF NOT EXISTS comment_created_at_idx ON posts.comment (post_id, parent_id, created_at, id);
+CREATE INDEX IF NOT EXISTS comment_hot_idx ON posts.comment (post_id, parent_id, hot, id);
+CREATE INDEX IF NOT EXISTS comment_controversy_idx ON posts.comment (post_id, parent_id, controversy, id);
+CREATE INDEX IF NOT EXISTS comment_confidence_idx ON posts.comment (post_id, parent_id, confidence, id);
\ No newline at end of file
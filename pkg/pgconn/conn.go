@@ -0,0 +1,67 @@
+package pgconn
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/cutlery47/posts/config"
+
+	"github.com/golang-migrate/migrate"
+	"github.com/golang-migrate/migrate/database/postgres"
+	_ "github.com/golang-migrate/migrate/source/file"
+
+	_ "github.com/lib/pq"
+)
+
+// opens a postgres connection and applies all pending migrations
+func Connect(conf config.Postgres) (*sql.DB, error) {
+	// timestamps are stored without time zone, so every session should agree on it
+	dsn := fmt.Sprintf(
+		"postgresql://%v:%v@%v:%v/%v?sslmode=disable&timezone=UTC",
+		conf.User,
+		conf.Pass,
+		conf.Host,
+		conf.Port,
+		conf.DB,
+	)
+
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	timeoutCtx, cancel := context.WithTimeout(context.Background(), conf.Timeout)
+	defer cancel()
+
+	err = db.PingContext(timeoutCtx)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't establish connection with postgres: %v", err)
+	}
+	log.Println("[SETUP] successfully established postgres connection!")
+
+	driver, err := postgres.WithInstance(db, &postgres.Config{})
+	if err != nil {
+		return nil, fmt.Errorf("postgres.WithInstance: %v", err)
+	}
+
+	migrations := fmt.Sprintf("file://%v", conf.Migrations)
+	m, err := migrate.NewWithDatabaseInstance(migrations, conf.DB, driver)
+	if err != nil {
+		return nil, fmt.Errorf("migrate.NewWithDatabaseInstance: %v", err)
+	}
+
+	if err := m.Up(); err != nil {
+		if errors.Is(err, migrate.ErrNoChange) {
+			log.Println("[SETUP] nothing to migrate")
+		} else {
+			return nil, fmt.Errorf("error when migrating: %v", err)
+		}
+	} else {
+		log.Println("[SETUP] migrated successfully!")
+	}
+
+	return db, nil
+}